@@ -0,0 +1,361 @@
+package main
+
+import (
+	"MCPGen/core/arazzo-parser"
+	"MCPGen/core/code-generator"
+	"MCPGen/core/diagnostics"
+	"MCPGen/core/flow-compiler"
+	"MCPGen/core/open-api-loader"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// generateOptions holds the parsed flags of the generate command.
+type generateOptions struct {
+	specOptions
+	OutputDir     string
+	ModuleName    string
+	Transport     string
+	Validation    string
+	Auth          codegenerator.InboundAuth
+	Middleware    string
+	OpenAIKeyFile string
+	OpenAIModel   string
+	RAGEndpoint   string
+}
+
+// specOptions holds the flags naming the specs and Arazzo files, how they are
+// loaded and how their problems are reported, shared by generate and lint.
+type specOptions struct {
+	Specs         []string
+	Arazzo        []string
+	Collisions    string
+	OperationIDs  string
+	RemoteRefs    []string
+	SpecHeaders   http.Header
+	SpecTimeout   time.Duration
+	CacheDir      string
+	Offline       bool
+	Diagnostics   diagnostics.Format
+	DiagnosticsTo string
+
+	specs, arazzo, remoteRefs, format string
+}
+
+// register defines the flags of o on fs.
+func (o *specOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.specs, "specs", "", "comma-separated list of OpenAPI/Swagger spec files, URLs, directories or globs, optionally prefixed with service=; - reads stdin")
+	fs.StringVar(&o.arazzo, "arazzo", "", "comma-separated list of Arazzo workflow files")
+	fs.StringVar(&o.Collisions, "collisions", "error", "handling of operations of one service sharing an operationId or route: error, first or rename")
+	fs.StringVar(&o.OperationIDs, "operation-ids", "", "YAML or JSON file mapping 'METHOD /path' to the operationId of that operation")
+	fs.StringVar(&o.remoteRefs, "allow-remote-refs", "", "comma-separated URL prefixes remote $refs of the specs may be fetched from")
+	o.SpecHeaders = http.Header{}
+	fs.Var(headerFlag(o.SpecHeaders), "spec-header", "'Name: value' header sent when fetching spec URLs, e.g. Authorization; repeatable")
+	fs.DurationVar(&o.SpecTimeout, "spec-timeout", 30*time.Second, "timeout of every spec and $ref download")
+	fs.StringVar(&o.CacheDir, "cache-dir", "", "directory caching downloaded specs and $refs, revalidated with their ETag")
+	fs.BoolVar(&o.Offline, "offline", false, "only read remote specs and $refs from --cache-dir")
+	fs.StringVar(&o.format, "diagnostics", "text", "format of the problems found in the specs and Arazzo files: text, json or sarif")
+	fs.StringVar(&o.DiagnosticsTo, "diagnostics-file", "", "file the diagnostics are written to instead of stderr")
+}
+
+// finish splits and checks the flags of o once fs has been parsed.
+func (o *specOptions) finish(fs *flag.FlagSet) error {
+	o.Specs = splitList(o.specs)
+	// "--specs a.yaml, b.yaml" leaves b.yaml as a positional argument.
+	o.Specs = append(o.Specs, splitList(strings.Join(fs.Args(), ","))...)
+	o.Arazzo = splitList(o.arazzo)
+	o.RemoteRefs = splitList(o.remoteRefs)
+
+	if len(o.Specs) == 0 {
+		return errors.New("at least one spec must be given with --specs")
+	}
+	switch flowcompiler.CollisionPolicy(o.Collisions) {
+	case flowcompiler.CollisionFail, flowcompiler.CollisionKeepFirst, flowcompiler.CollisionRename:
+	default:
+		return fmt.Errorf("unsupported --collisions %q, expected error, first or rename", o.Collisions)
+	}
+	if o.Offline && o.CacheDir == "" {
+		return errors.New("--offline requires --cache-dir")
+	}
+	var err error
+	if o.Diagnostics, err = diagnostics.ParseFormat(o.format); err != nil {
+		return fmt.Errorf("--diagnostics: %w", err)
+	}
+	return nil
+}
+
+// loader returns the spec loader configured by o.
+func (o *specOptions) loader() (*open_api_loader.SpecLoader, error) {
+	loader := open_api_loader.NewSpecLoader()
+	loader.AllowedRemoteRefs = o.RemoteRefs
+	loader.Headers = o.SpecHeaders
+	loader.Timeout = o.SpecTimeout
+	loader.CacheDir = o.CacheDir
+	loader.Offline = o.Offline
+	if o.OperationIDs != "" {
+		var err error
+		if loader.OperationIDs, err = open_api_loader.LoadOperationIDs(o.OperationIDs); err != nil {
+			return nil, fmt.Errorf("loading operationIds: %w", err)
+		}
+	}
+	return loader, nil
+}
+
+func parseGenerateFlags(args []string, stderr io.Writer) (*generateOptions, error) {
+	var opts generateOptions
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	opts.register(fs)
+	fs.StringVar(&opts.OutputDir, "output", "./mcp-server", "directory the generated server is written to")
+	fs.StringVar(&opts.ModuleName, "module", "mcp-server", "Go module name of the generated server")
+	fs.StringVar(&opts.Transport, "transport", "stdio", "MCP transport of the generated server: stdio or http")
+	fs.StringVar(&opts.Validation, "validation", "warn", "schema validation of downstream calls in the generated server: off, warn or enforce")
+	fs.BoolVar(&opts.Auth.APIKey, "auth-api-key", false, "require clients of the generated HTTP endpoints to send an X-API-Key from MCP_API_KEYS_FILE")
+	fs.StringVar(&opts.Auth.JWKS, "auth-jwks", "", "file or URL of the JWKS verifying JWT bearer tokens of clients; enables JWT authentication")
+	fs.StringVar(&opts.Auth.Issuer, "auth-issuer", "", "required iss claim of client JWTs")
+	fs.StringVar(&opts.Auth.Audience, "auth-audience", "", "required aud claim of client JWTs")
+	fs.BoolVar(&opts.Auth.MTLS, "auth-mtls", false, "require client certificates signed by MCP_TLS_CLIENT_CA")
+	opts.Auth.Scopes = map[string][]string{}
+	fs.Var(scopesFlag(opts.Auth.Scopes), "auth-scopes", "'workflow=scope1,scope2' scopes a client needs to run a workflow; repeatable")
+	fs.StringVar(&opts.Middleware, "middleware", "", "YAML or JSON file listing the HTTP and tool middleware of the generated server")
+	fs.StringVar(&opts.OpenAIKeyFile, "openai-key-file", "", "file containing an OpenAI API key used to refine the generated code")
+	fs.StringVar(&opts.OpenAIModel, "openai-model", "gpt-4-1106-preview", "OpenAI model used for code generation")
+	fs.StringVar(&opts.RAGEndpoint, "rag-endpoint", "", "URL of the RAG service /generate endpoint used to refine the generated code")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if err := opts.finish(fs); err != nil {
+		return nil, err
+	}
+	if opts.OutputDir == "" {
+		return nil, errors.New("--output must not be empty")
+	}
+	if opts.Transport != string(codegenerator.TransportStdio) && opts.Transport != string(codegenerator.TransportHTTP) {
+		return nil, fmt.Errorf("unsupported --transport %q, expected stdio or http", opts.Transport)
+	}
+	switch codegenerator.ValidationMode(opts.Validation) {
+	case codegenerator.ValidationOff, codegenerator.ValidationWarn, codegenerator.ValidationEnforce:
+	default:
+		return nil, fmt.Errorf("unsupported --validation %q, expected off, warn or enforce", opts.Validation)
+	}
+	if (opts.Auth.Issuer != "" || opts.Auth.Audience != "") && opts.Auth.JWKS == "" {
+		return nil, errors.New("--auth-issuer and --auth-audience require --auth-jwks")
+	}
+	if opts.OpenAIKeyFile != "" && opts.RAGEndpoint != "" {
+		return nil, errors.New("--openai-key-file and --rag-endpoint are mutually exclusive")
+	}
+	return &opts, nil
+}
+
+// runGenerate runs loader -> Arazzo parser -> flow compiler -> code generator.
+func runGenerate(args []string, stdout, stderr io.Writer) int {
+	opts, err := parseGenerateFlags(args, stderr)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitUsage
+	}
+	var middleware *codegenerator.MiddlewareConfig
+	if opts.Middleware != "" {
+		if middleware, err = codegenerator.LoadMiddlewareConfig(opts.Middleware); err != nil {
+			fmt.Fprintf(stderr, "error: loading middleware config: %v\n", err)
+			return exitUsage
+		}
+	}
+
+	loader, err := opts.loader()
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitSpecs
+	}
+
+	// Every spec and Arazzo file is read before giving up, so that all their
+	// problems are reported at once.
+	var (
+		diags        diagnostics.List
+		services     []flowcompiler.Service
+		endpoints    []flowcompiler.Endpoint
+		specsFailed  bool
+		arazzoFailed bool
+	)
+	for _, entry := range opts.Specs {
+		name, path := splitServiceName(entry)
+		specs, err := loader.LoadSpecs(path)
+		if err != nil {
+			diags = append(diags, diagnostics.FromError(path, err)...)
+			specsFailed = true
+			continue
+		}
+		for _, spec := range specs {
+			services = append(services, flowcompiler.Service{Name: name, Spec: spec})
+		}
+	}
+	if !specsFailed {
+		var notes []string
+		endpoints, notes, err = flowcompiler.MergeServices(services, flowcompiler.CollisionPolicy(opts.Collisions))
+		if err != nil {
+			diags = append(diags, diagnostics.Diagnostic{Severity: diagnostics.SeverityError, Rule: ruleCollision, Message: err.Error()})
+			specsFailed = true
+		}
+		for _, note := range notes {
+			diags = append(diags, diagnostics.Diagnostic{Severity: diagnostics.SeverityWarning, Rule: ruleCollision, Message: note})
+		}
+	}
+
+	var flows []flowcompiler.FlowDefinition
+	for _, path := range opts.Arazzo {
+		defs, err := arazzo_parser.ParseFlows(path)
+		if err != nil {
+			diags = append(diags, diagnostics.FromError(path, err)...)
+			arazzoFailed = true
+			continue
+		}
+		flows = append(flows, defs...)
+	}
+
+	if err := writeDiagnostics(&opts.specOptions, diags, stderr); err != nil {
+		fmt.Fprintf(stderr, "error: writing diagnostics: %v\n", err)
+		return exitUsage
+	}
+	switch {
+	case specsFailed:
+		return exitSpecs
+	case arazzoFailed:
+		return exitArazzo
+	}
+
+	compiled, err := flowcompiler.NewFlowCompiler(endpoints, flows).Compile()
+	if err != nil {
+		fmt.Fprintf(stderr, "error: compiling flows: %v\n", err)
+		return exitCompile
+	}
+
+	generator := &codegenerator.CodeGenerator{
+		Flows:      compiled,
+		Endpoints:  endpoints,
+		OutputDir:  opts.OutputDir,
+		ModuleName: opts.ModuleName,
+		Transport:  codegenerator.Transport(opts.Transport),
+		Validation: codegenerator.ValidationMode(opts.Validation),
+		Auth:       &opts.Auth,
+		Middleware: middleware,
+	}
+	switch {
+	case opts.OpenAIKeyFile != "":
+		generator.LLM = &codegenerator.OpenAIProvider{KeyPath: opts.OpenAIKeyFile, Model: opts.OpenAIModel}
+	case opts.RAGEndpoint != "":
+		generator.LLM = &codegenerator.RAGProvider{Endpoint: opts.RAGEndpoint}
+	}
+	if err := generator.GenerateServerCode(); err != nil {
+		if !errors.Is(err, codegenerator.ErrEnhancementFailed) {
+			fmt.Fprintf(stderr, "error: generating server: %v\n", err)
+			return exitGenerate
+		}
+		fmt.Fprintf(stderr, "warning: %v\n", err)
+	}
+
+	fmt.Fprintf(stdout, "Generated MCP server for %d endpoints and %d workflows in %s\n", len(endpoints), len(compiled), opts.OutputDir)
+	return exitOK
+}
+
+// ruleCollision is the rule of the diagnostics about operations of one
+// service sharing an operationId or route.
+const ruleCollision = "operation-collision"
+
+// writeDiagnostics reports diags in the format of opts, to the diagnostics
+// file or stderr. The diagnostics file and JSON or SARIF output are written
+// even when there are no diagnostics, so that CI always finds a report.
+func writeDiagnostics(opts *specOptions, diags diagnostics.List, stderr io.Writer) error {
+	if opts.DiagnosticsTo == "" {
+		if len(diags) == 0 && opts.Diagnostics == diagnostics.FormatText {
+			return nil
+		}
+		return diagnostics.Write(stderr, opts.Diagnostics, "mcpgen", diags)
+	}
+	f, err := os.Create(opts.DiagnosticsTo)
+	if err != nil {
+		return err
+	}
+	if err := diagnostics.Write(f, opts.Diagnostics, "mcpgen", diags); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// headerFlag collects repeated "Name: value" flags into a header.
+type headerFlag http.Header
+
+func (h headerFlag) String() string {
+	var out []string
+	for name, values := range h {
+		for _, v := range values {
+			out = append(out, name+": "+v)
+		}
+	}
+	sort.Strings(out)
+	return strings.Join(out, ", ")
+}
+
+func (h headerFlag) Set(value string) error {
+	name, v, ok := strings.Cut(value, ":")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("header %q must have the form 'Name: value'", value)
+	}
+	http.Header(h).Add(strings.TrimSpace(name), strings.TrimSpace(v))
+	return nil
+}
+
+// scopesFlag collects repeated "workflow=scope1,scope2" flags.
+type scopesFlag map[string][]string
+
+func (s scopesFlag) String() string {
+	var out []string
+	for id, scopes := range s {
+		out = append(out, id+"="+strings.Join(scopes, ","))
+	}
+	sort.Strings(out)
+	return strings.Join(out, " ")
+}
+
+func (s scopesFlag) Set(value string) error {
+	id, scopes, ok := strings.Cut(value, "=")
+	if !ok || strings.TrimSpace(id) == "" || len(splitList(scopes)) == 0 {
+		return fmt.Errorf("scopes %q must have the form 'workflow=scope1,scope2'", value)
+	}
+	id = strings.TrimSpace(id)
+	s[id] = append(s[id], splitList(scopes)...)
+	return nil
+}
+
+// splitServiceName splits a "name=path" spec entry. Entries whose prefix looks
+// like a path or URL, such as "./a=b.yaml" or "https://host/spec?v=1", are
+// taken as a path with no explicit name.
+func splitServiceName(entry string) (name, path string) {
+	name, path, ok := strings.Cut(entry, "=")
+	if !ok || name == "" || strings.ContainsAny(name, `/\:.`) {
+		return "", entry
+	}
+	return name, path
+}
+
+// splitList splits a comma-separated flag value, dropping blanks around entries.
+func splitList(value string) []string {
+	var out []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"MCPGen/core/diagnostics"
+	"MCPGen/core/flow-compiler"
+	"MCPGen/core/linter"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+)
+
+// lintOptions holds the parsed flags of the lint command.
+type lintOptions struct {
+	specOptions
+	Config string
+}
+
+func parseLintFlags(args []string, stderr io.Writer) (*lintOptions, error) {
+	var opts lintOptions
+	fs := flag.NewFlagSet("lint", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	opts.register(fs)
+	fs.StringVar(&opts.Config, "config", "", "YAML or JSON file setting the severity of lint rules: off, info, warning or error")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if err := opts.finish(fs); err != nil {
+		return nil, err
+	}
+	return &opts, nil
+}
+
+// runLint checks the specs and Arazzo files and reports what it finds. It
+// fails when a diagnostic is an error.
+func runLint(args []string, stdout, stderr io.Writer) int {
+	opts, err := parseLintFlags(args, stderr)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitUsage
+	}
+	l := &linter.Linter{Collisions: flowcompiler.CollisionPolicy(opts.Collisions)}
+	if opts.Config != "" {
+		if l.Config, err = linter.LoadConfig(opts.Config); err != nil {
+			fmt.Fprintf(stderr, "error: loading lint config: %v\n", err)
+			return exitUsage
+		}
+	}
+	if l.Loader, err = opts.loader(); err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitSpecs
+	}
+
+	var specs []linter.Spec
+	for _, entry := range opts.Specs {
+		name, path := splitServiceName(entry)
+		specs = append(specs, linter.Spec{Service: name, Path: path})
+	}
+	diags := l.Lint(specs, opts.Arazzo)
+	if err := writeDiagnostics(&opts.specOptions, diags, stderr); err != nil {
+		fmt.Fprintf(stderr, "error: writing diagnostics: %v\n", err)
+		return exitUsage
+	}
+	if diags.HasErrors() {
+		return exitLint
+	}
+	if len(diags) == 0 && opts.Diagnostics == diagnostics.FormatText {
+		fmt.Fprintln(stdout, "No problems found")
+	}
+	return exitOK
+}
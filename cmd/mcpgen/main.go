@@ -2,12 +2,57 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
 )
 
+// Exit codes returned by mcpgen, one per failure class.
+const (
+	exitOK       = 0
+	exitUsage    = 2
+	exitSpecs    = 3
+	exitArazzo   = 4
+	exitCompile  = 5
+	exitGenerate = 6
+	exitLint     = 7
+)
+
+const usage = `mcpgen CLI - Modular Code Pipeline Generator
+
+Usage:
+  mcpgen generate --specs <spec>[,<spec>...] [--arazzo <file>[,<file>...]] --output <dir> [options]
+  mcpgen --specs <spec>[,<spec>...] [--arazzo <file>[,<file>...]] --output <dir> [options]
+  mcpgen lint --specs <spec>[,<spec>...] [--arazzo <file>[,<file>...]] [--config <file>] [options]
+
+A spec is a file, an http(s) URL, a directory, a glob or - for stdin, optionally
+prefixed with name= to set the service its operations are qualified with.
+Run 'mcpgen generate --help' or 'mcpgen lint --help' for the list of options.
+`
+
 func main() {
-	fmt.Println("mcpgen CLI - Modular Code Pipeline Generator")
-	fmt.Println("Usage: mcpgen <openapi-file> <arazzo-file> [options]")
-	// TODO: Wire up OpenAPILoader, ArazzoParser, FlowCompiler, CodeGenerator, PluginManager
-	os.Exit(0)
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run dispatches the command line to a subcommand and returns the process exit code.
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+	switch args[0] {
+	case "generate":
+		return runGenerate(args[1:], stdout, stderr)
+	case "lint":
+		return runLint(args[1:], stdout, stderr)
+	case "help", "-h", "--help", "-help":
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	default:
+		// The README documents flags without a subcommand; treat that as generate.
+		if len(args[0]) > 0 && args[0][0] == '-' {
+			return runGenerate(args, stdout, stderr)
+		}
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", args[0], usage)
+		return exitUsage
+	}
 }
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runCLI(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun_NoArguments(t *testing.T) {
+	code, _, stderr := runCLI()
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr, "Usage:")
+}
+
+func TestRun_UnknownCommand(t *testing.T) {
+	code, _, stderr := runCLI("deploy")
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr, `unknown command "deploy"`)
+}
+
+func TestGenerate_MissingSpecs(t *testing.T) {
+	code, _, stderr := runCLI("generate", "--output", t.TempDir())
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr, "--specs")
+}
+
+func TestGenerate_SpecLoadFailure(t *testing.T) {
+	code, _, stderr := runCLI("generate", "--specs", "testdata/missing.yaml", "--output", t.TempDir())
+	assert.Equal(t, exitSpecs, code)
+	assert.Contains(t, stderr, "testdata/missing.yaml")
+}
+
+func TestGenerate_ArazzoFailure(t *testing.T) {
+	code, _, _ := runCLI("generate", "--specs", "testdata/petstore.yaml", "--arazzo", "testdata/missing.yaml", "--output", t.TempDir())
+	assert.Equal(t, exitArazzo, code)
+}
+
+func TestGenerate_CompileFailure(t *testing.T) {
+	code, _, stderr := runCLI("generate", "--specs", "testdata/petstore.yaml", "--arazzo", "testdata/unresolved.arazzo.yaml", "--output", t.TempDir())
+	assert.Equal(t, exitCompile, code)
+	assert.Contains(t, stderr, "testdata/unresolved.arazzo.yaml:13:22: step 'missing' in workflow 'broken': operationId 'deletePet' not found")
+}
+
+func TestGenerate_DuplicateWorkflowIDs(t *testing.T) {
+	code, _, stderr := runCLI("generate", "--specs", "testdata/petstore.yaml",
+		"--arazzo", "testdata/petstore.arazzo.yaml,testdata/duplicate.arazzo.yaml", "--output", t.TempDir())
+	assert.Equal(t, exitCompile, code)
+	assert.Contains(t, stderr, "testdata/duplicate.arazzo.yaml:10:17: workflow 'refresh-pet': workflowId is already defined in testdata/petstore.arazzo.yaml")
+}
+
+func TestGenerate_EndToEnd(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "server")
+	code, stdout, stderr := runCLI("generate", "--specs", "testdata/petstore.yaml", "--arazzo", "testdata/petstore.arazzo.yaml",
+		"--output", out, "--module", "example.com/petstore-mcp")
+	require.Equal(t, exitOK, code, stderr)
+	assert.Contains(t, stdout, "1 workflows")
+
+	gomod, err := os.ReadFile(filepath.Join(out, "go.mod"))
+	require.NoError(t, err)
+	assert.Contains(t, string(gomod), "module example.com/petstore-mcp")
+
+	flows, err := os.ReadFile(filepath.Join(out, "flows.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(flows), `"refresh-pet"`)
+	assert.Contains(t, string(flows), `"hooks/validate_user.go"`)
+
+	hooks, err := os.ReadFile(filepath.Join(out, "hook_files.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(hooks), `"example.com/petstore-mcp/hooks"`)
+	assert.FileExists(t, filepath.Join(out, "hooks", "validate_user.go"))
+	assert.FileExists(t, filepath.Join(out, "hooks", "log_result.go"))
+}
+
+func TestGenerate_SpecsFromDirectoryAndURL(t *testing.T) {
+	spec, err := os.ReadFile("testdata/petstore.yaml")
+	require.NoError(t, err)
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		_, _ = w.Write(spec)
+	}))
+	defer srv.Close()
+
+	code, stdout, stderr := runCLI("generate", "--specs", "testdata/", "--output", filepath.Join(t.TempDir(), "dir"))
+	require.Equal(t, exitOK, code, stderr)
+	assert.Contains(t, stdout, "Generated MCP server for 2 endpoints")
+
+	code, stdout, stderr = runCLI("generate", "--specs", srv.URL+"/petstore.yaml", "--spec-header", "Authorization: Bearer t0ken",
+		"--output", filepath.Join(t.TempDir(), "url"))
+	require.Equal(t, exitOK, code, stderr)
+	assert.Contains(t, stdout, "Generated MCP server for 2 endpoints")
+	assert.Equal(t, "Bearer t0ken", auth)
+}
+
+func TestGenerate_ServiceNamespaces(t *testing.T) {
+	code, _, stderr := runCLI("generate", "--specs", "testdata/petstore.yaml,testdata/petstore.yaml", "--output", t.TempDir())
+	assert.Equal(t, exitSpecs, code)
+	assert.Contains(t, stderr, "is defined by testdata/petstore.yaml and testdata/petstore.yaml")
+
+	code, stdout, stderr := runCLI("generate", "--specs", "testdata/petstore.yaml,testdata/petstore.yaml", "--collisions", "first",
+		"--output", t.TempDir())
+	require.Equal(t, exitOK, code, stderr)
+	assert.Contains(t, stderr, "warning: service 'Petstore'")
+	assert.Contains(t, stdout, "Generated MCP server for 2 endpoints")
+
+	out := filepath.Join(t.TempDir(), "server")
+	code, stdout, stderr = runCLI("generate", "--specs", "pets=testdata/petstore.yaml,store=testdata/petstore.yaml", "--output", out)
+	require.Equal(t, exitOK, code, stderr)
+	assert.Contains(t, stdout, "Generated MCP server for 4 endpoints")
+	tools, err := os.ReadFile(filepath.Join(out, "tools.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(tools), `"pets_`)
+	assert.Contains(t, string(tools), `"store_`)
+}
+
+func TestGenerate_OperationIDOverrides(t *testing.T) {
+	ids := filepath.Join(t.TempDir(), "operation-ids.yaml")
+	require.NoError(t, os.WriteFile(ids, []byte("GET /pets/{petId}: fetchPet\n"), 0o644))
+	out := filepath.Join(t.TempDir(), "server")
+	code, _, stderr := runCLI("generate", "--specs", "testdata/petstore.yaml", "--operation-ids", ids, "--output", out)
+	require.Equal(t, exitOK, code, stderr)
+	tools, err := os.ReadFile(filepath.Join(out, "tools.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(tools), `"fetchPet"`)
+	assert.NotContains(t, string(tools), `"getPet"`)
+
+	require.NoError(t, os.WriteFile(ids, []byte("/pets: listPets\n"), 0o644))
+	code, _, stderr = runCLI("generate", "--specs", "testdata/petstore.yaml", "--operation-ids", ids, "--output", out)
+	assert.Equal(t, exitSpecs, code)
+	assert.Contains(t, stderr, "must have the form 'METHOD /path'")
+}
+
+func TestGenerate_InboundAuth(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "server")
+	code, _, stderr := runCLI("generate", "--specs", "testdata/petstore.yaml", "--arazzo", "testdata/petstore.arazzo.yaml",
+		"--auth-jwks", "https://issuer.example.com/jwks.json", "--auth-audience", "mcp", "--auth-scopes", "refresh-pet=pets:write",
+		"--output", out)
+	require.Equal(t, exitOK, code, stderr)
+	flows, err := os.ReadFile(filepath.Join(out, "flows.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(flows), `JWKS:     "https://issuer.example.com/jwks.json"`)
+	assert.Contains(t, string(flows), `Scopes: []string{"pets:write"}`)
+
+	code, _, stderr = runCLI("generate", "--specs", "testdata/petstore.yaml", "--arazzo", "testdata/petstore.arazzo.yaml",
+		"--auth-scopes", "refresh-pet=pets:write", "--output", out)
+	assert.Equal(t, exitGenerate, code)
+	assert.Contains(t, stderr, "workflow scopes require")
+
+	code, _, stderr = runCLI("generate", "--specs", "testdata/petstore.yaml", "--auth-issuer", "me", "--output", out)
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr, "--auth-jwks")
+}
+
+func TestGenerate_Middleware(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "server")
+	code, _, stderr := runCLI("generate", "--specs", "testdata/petstore.yaml", "--middleware", "testdata/middleware/middleware.yaml",
+		"--transport", "http", "--output", out)
+	require.Equal(t, exitOK, code, stderr)
+	config, err := os.ReadFile(filepath.Join(out, "middleware_config.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(config), `plugins.InjectMiddleware("served_by.go", 3, middleware.ServedBy)`)
+	assert.Contains(t, string(config), `plugins.InjectToolMiddleware("recovery", 0, RecoverToolPanics())`)
+	assert.FileExists(t, filepath.Join(out, "middleware", "served_by.go"))
+
+	code, _, stderr = runCLI("generate", "--specs", "testdata/petstore.yaml", "--middleware", "testdata/missing.yaml", "--output", out)
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr, "loading middleware config")
+}
+
+func TestGenerate_Diagnostics(t *testing.T) {
+	code, _, stderr := runCLI("generate", "--specs", "testdata/diagnostics/broken.yaml,testdata/missing.yaml",
+		"--arazzo", "testdata/diagnostics/invalid.arazzo.yaml", "--output", t.TempDir())
+	assert.Equal(t, exitSpecs, code)
+	assert.Contains(t, stderr, "testdata/diagnostics/broken.yaml:7:5: error: operation GET /users has no responses (at /paths/~1users/get)\n")
+	assert.Contains(t, stderr, "testdata/diagnostics/broken.yaml:16:19: error: schema type 'obj' is not one of")
+	assert.Contains(t, stderr, "testdata/missing.yaml: error: cannot read file")
+	assert.Contains(t, stderr, "testdata/diagnostics/invalid.arazzo.yaml:11:9: error: field stepId is missing (at /workflows/0/steps/0)\n")
+
+	report := filepath.Join(t.TempDir(), "report.json")
+	code, _, _ = runCLI("generate", "--specs", "testdata/petstore.yaml", "--arazzo", "testdata/diagnostics/invalid.arazzo.yaml",
+		"--diagnostics", "json", "--diagnostics-file", report, "--output", t.TempDir())
+	assert.Equal(t, exitArazzo, code)
+	data, err := os.ReadFile(report)
+	require.NoError(t, err)
+	var diags []map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &diags))
+	require.NotEmpty(t, diags)
+	assert.Equal(t, "testdata/diagnostics/invalid.arazzo.yaml", diags[0]["file"])
+	assert.Equal(t, "arazzo-validation", diags[0]["rule"])
+
+	sarif := filepath.Join(t.TempDir(), "report.sarif")
+	code, _, stderr = runCLI("generate", "--specs", "testdata/petstore.yaml,testdata/petstore.yaml", "--collisions", "first",
+		"--diagnostics", "sarif", "--diagnostics-file", sarif, "--output", t.TempDir())
+	require.Equal(t, exitOK, code, stderr)
+	data, err = os.ReadFile(sarif)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"version": "2.1.0"`)
+	assert.Contains(t, string(data), `"level": "warning"`)
+
+	code, _, stderr = runCLI("generate", "--specs", "testdata/petstore.yaml", "--diagnostics", "xml", "--output", t.TempDir())
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr, "xml")
+}
+
+func TestGenerate_EnhancementFailureIsAWarning(t *testing.T) {
+	var prompt string
+	rag := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		prompt = body["prompt"]
+		_ = json.NewEncoder(w).Encode(map[string]string{"code": "package main\n"})
+	}))
+	defer rag.Close()
+
+	out := filepath.Join(t.TempDir(), "server")
+	code, _, stderr := runCLI("generate", "--specs", "testdata/petstore.yaml", "--arazzo", "testdata/petstore.arazzo.yaml",
+		"--output", out, "--rag-endpoint", rag.URL)
+	require.Equal(t, exitOK, code, stderr)
+	assert.Contains(t, stderr, "warning: LLM enhancement failed")
+	assert.Contains(t, prompt, "refresh-pet")
+
+	handlers, err := os.ReadFile(filepath.Join(out, "handlers.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(handlers), "func registerTaskHandlers")
+}
+
+func TestGenerate_UnsupportedTransport(t *testing.T) {
+	code, _, stderr := runCLI("generate", "--specs", "testdata/petstore.yaml", "--transport", "grpc", "--output", t.TempDir())
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr, "grpc")
+}
+
+func TestGenerate_UnsupportedValidation(t *testing.T) {
+	code, _, stderr := runCLI("generate", "--specs", "testdata/petstore.yaml", "--validation", "strict", "--output", t.TempDir())
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr, "strict")
+}
+
+func TestGenerate_UnsupportedCollisionPolicy(t *testing.T) {
+	code, _, stderr := runCLI("generate", "--specs", "testdata/petstore.yaml", "--collisions", "overwrite", "--output", t.TempDir())
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr, "overwrite")
+}
+
+func TestGenerate_OfflineRequiresRefCache(t *testing.T) {
+	code, _, stderr := runCLI("generate", "--specs", "testdata/petstore.yaml", "--offline", "--output", t.TempDir())
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr, "--cache-dir")
+}
+
+func TestLint_CleanSpecs(t *testing.T) {
+	code, stdout, stderr := runCLI("lint", "--specs", "testdata/petstore.yaml", "--arazzo", "testdata/petstore.arazzo.yaml")
+	assert.Equal(t, exitOK, code, stderr)
+	assert.Equal(t, "No problems found\n", stdout)
+}
+
+func TestLint_Warnings(t *testing.T) {
+	code, _, stderr := runCLI("lint", "--specs", "testdata/lint/untyped.yaml")
+	assert.Equal(t, exitOK, code)
+	assert.Contains(t, stderr, "testdata/lint/untyped.yaml:7:5: warning: operation GET /notes has no operationId; its tool is named get_notes (at /paths/~1notes/get)\n")
+	assert.Contains(t, stderr, "testdata/lint/untyped.yaml:15:24: warning: schema has no type")
+}
+
+func TestLint_Config(t *testing.T) {
+	sarif := filepath.Join(t.TempDir(), "lint.sarif")
+	code, _, stderr := runCLI("lint", "--specs", "testdata/lint/untyped.yaml", "--config", "testdata/lint/strict.yaml",
+		"--diagnostics", "sarif", "--diagnostics-file", sarif)
+	assert.Equal(t, exitLint, code, stderr)
+	data, err := os.ReadFile(sarif)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"ruleId": "untyped-schema"`)
+	assert.Contains(t, string(data), `"level": "error"`)
+	assert.NotContains(t, string(data), "missing-operation-id")
+
+	code, _, stderr = runCLI("lint", "--specs", "testdata/lint/untyped.yaml", "--config", "testdata/missing.yaml")
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr, "loading lint config")
+
+	// A loader that cannot be set up fails as it does in generate.
+	ids := filepath.Join(t.TempDir(), "operation-ids.yaml")
+	require.NoError(t, os.WriteFile(ids, []byte("/pets: listPets\n"), 0o644))
+	code, _, stderr = runCLI("lint", "--specs", "testdata/lint/untyped.yaml", "--operation-ids", ids)
+	assert.Equal(t, exitSpecs, code)
+	assert.Contains(t, stderr, "must have the form 'METHOD /path'")
+}
+
+func TestLint_UnresolvedStep(t *testing.T) {
+	code, _, stderr := runCLI("lint", "--specs", "testdata/petstore.yaml", "--arazzo", "testdata/unresolved.arazzo.yaml")
+	assert.Equal(t, exitLint, code)
+	assert.Contains(t, stderr, "testdata/unresolved.arazzo.yaml:13:22: error: step 'missing' in workflow 'broken': operationId 'deletePet' not found")
+}
@@ -0,0 +1,14 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+)
+
+// ValidateUser rejects user ids that cannot exist before they are looked up.
+func ValidateUser(ctx context.Context, sc *StepContext) error {
+	if id, _ := sc.Inputs["id"].(string); id == "" || id == "0" {
+		return fmt.Errorf("invalid user id %q: %w", id, ErrAbort)
+	}
+	return nil
+}
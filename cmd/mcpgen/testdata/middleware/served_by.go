@@ -0,0 +1,11 @@
+package middleware
+
+import "net/http"
+
+// ServedBy names the server in the X-Served-By header of every response.
+func ServedBy(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Served-By", "mcpgen")
+		next.ServeHTTP(w, r)
+	})
+}
@@ -1,32 +1,74 @@
 package arazzo_parser
 
 import (
+	"MCPGen/core/diagnostics"
+	"MCPGen/core/flow-compiler"
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 
 	"github.com/speakeasy-api/openapi/arazzo"
+	"github.com/speakeasy-api/openapi/validation"
 )
 
-func Read(filePath string) (string, []error, error) {
-	ctx := context.Background()
-	r, err := os.Open(filePath)
+// RuleValidation is the rule of the diagnostics reported for documents that
+// break the Arazzo specification.
+const RuleValidation = "arazzo-validation"
+
+// ArazzoParser parses an Arazzo document into flow definitions.
+type ArazzoParser struct {
+	FilePath string
+	Flows    []flowcompiler.FlowDefinition
+	// Document is the parsed Arazzo model, set by Parse.
+	Document *arazzo.Arazzo
+}
+
+// NewArazzoParser returns a parser for the Arazzo document at filePath.
+func NewArazzoParser(filePath string) *ArazzoParser {
+	return &ArazzoParser{FilePath: filePath}
+}
+
+// Parse reads and validates the document and converts every workflow into a
+// FlowDefinition. The error of an invalid document carries a
+// *diagnostics.Error.
+func (a *ArazzoParser) Parse() error {
+	doc, validationErrs, err := unmarshalFile(a.FilePath)
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to open file: %w", err)
+		return err
 	}
-	defer r.Close()
-
-	a, validationErrs, err := arazzo.Unmarshal(ctx, r)
+	if len(validationErrs) > 0 {
+		return fmt.Errorf("invalid Arazzo document: %w", validationDiagnostics(a.FilePath, doc, validationErrs).Err())
+	}
+	flows, err := convertDocument(doc, a.FilePath)
 	if err != nil {
-		return "", nil, fmt.Errorf("unmarshal error: %w", err)
+		return err
 	}
+	a.Document = doc
+	a.Flows = flows
+	return nil
+}
 
-	// Mutate the document
-	a.Info.Title = "Speakeasy Bar Workflows"
+// ParseFlows parses the Arazzo document at filePath and returns its workflows.
+func ParseFlows(filePath string) ([]flowcompiler.FlowDefinition, error) {
+	p := NewArazzoParser(filePath)
+	if err := p.Parse(); err != nil {
+		return nil, err
+	}
+	return p.Flows, nil
+}
+
+// Read parses the document and returns it re-serialized together with its
+// validation errors.
+func Read(filePath string) (string, []error, error) {
+	a, validationErrs, err := unmarshalFile(filePath)
+	if err != nil {
+		return "", nil, err
+	}
 
 	buf := bytes.NewBuffer([]byte{})
-	if err := arazzo.Marshal(ctx, a, buf); err != nil {
+	if err := arazzo.Marshal(context.Background(), a, buf); err != nil {
 		return "", nil, fmt.Errorf("marshal error: %w", err)
 	}
 
@@ -35,15 +77,9 @@ func Read(filePath string) (string, []error, error) {
 
 func Walk(filePath string) ([]string, error) {
 	ctx := context.Background()
-	f, err := os.Open(filePath)
+	a, _, err := unmarshalFile(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open file: %w", err)
-	}
-	defer f.Close()
-
-	a, _, err := arazzo.Unmarshal(ctx, f)
-	if err != nil {
-		return nil, fmt.Errorf("unmarshal error: %w", err)
+		return nil, err
 	}
 
 	var workflowIDs []string
@@ -63,17 +99,51 @@ func Walk(filePath string) ([]string, error) {
 }
 
 func Validate(filePath string) (bool, []error, error) {
-	ctx := context.Background()
+	a, validationErrs, err := unmarshalFile(filePath)
+	if err != nil {
+		return false, nil, err
+	}
+
+	return a.Valid, validationErrs, nil
+}
+
+// Diagnose validates the document at filePath and returns its validation
+// errors as diagnostics.
+func Diagnose(filePath string) (diagnostics.List, error) {
+	doc, validationErrs, err := unmarshalFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return validationDiagnostics(filePath, doc, validationErrs), nil
+}
+
+// validationDiagnostics locates validation errors in the document.
+func validationDiagnostics(filePath string, doc *arazzo.Arazzo, validationErrs []error) diagnostics.List {
+	var out diagnostics.List
+	for _, err := range validationErrs {
+		d := diagnostics.Diagnostic{Severity: diagnostics.SeverityError, Rule: RuleValidation, File: filePath, Message: err.Error()}
+		var verr *validation.Error
+		if errors.As(err, &verr) {
+			d.Line, d.Column, d.Message = verr.Line, verr.Column, verr.Message
+			if core := doc.GetCore(); core != nil && d.Line > 0 {
+				d.Pointer = diagnostics.Locate(core.RootNode, d.Line, d.Column)
+			}
+		}
+		out = append(out, d)
+	}
+	return out
+}
+
+func unmarshalFile(filePath string) (*arazzo.Arazzo, []error, error) {
 	r, err := os.Open(filePath)
 	if err != nil {
-		return false, nil, fmt.Errorf("failed to open file: %w", err)
+		return nil, nil, fmt.Errorf("failed to open file: %w", err)
 	}
 	defer r.Close()
 
-	a, validationErrs, err := arazzo.Unmarshal(ctx, r)
+	a, validationErrs, err := arazzo.Unmarshal(context.Background(), r)
 	if err != nil {
-		return false, nil, fmt.Errorf("unmarshal error: %w", err)
+		return nil, nil, fmt.Errorf("unmarshal error: %w", err)
 	}
-
-	return a.Valid, validationErrs, nil
+	return a, validationErrs, nil
 }
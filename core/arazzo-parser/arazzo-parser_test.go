@@ -1,6 +1,8 @@
 package arazzo_parser
 
 import (
+	"MCPGen/core/diagnostics"
+	"MCPGen/core/flow-compiler"
 	"os"
 	"path/filepath"
 	"strings"
@@ -10,14 +12,19 @@ import (
 )
 
 const validSpec = `
+arazzo: 1.0.1
 info:
   title: "Test"
   version: "1.0"
+sourceDescriptions:
+  - name: api
+    url: ./openapi.yaml
+    type: openapi
 workflows:
-  - workflowID: "wf1"
+  - workflowId: "wf1"
     steps:
-      - id: "step1"
-        type: "task"
+      - stepId: "step1"
+        operationId: getUser
 `
 
 const invalidSpec = `
@@ -44,7 +51,7 @@ func TestRead_ValidSpec(t *testing.T) {
 	output, validationErrs, err := Read(file)
 	require.NoError(t, err)
 	require.Empty(t, validationErrs)
-	require.Contains(t, output, "Speakeasy Bar Workflows")
+	require.Contains(t, output, "title: \"Test\"")
 }
 
 func TestRead_InvalidPath(t *testing.T) {
@@ -101,5 +108,183 @@ func TestRead_OutputFormat(t *testing.T) {
 	file := createTempSpec(t, validSpec)
 	output, _, err := Read(file)
 	require.NoError(t, err)
-	require.True(t, strings.Contains(output, "Speakeasy Bar Workflows"))
+	require.True(t, strings.Contains(output, "workflowId: \"wf1\""))
+	require.False(t, strings.Contains(output, "Speakeasy Bar Workflows"))
+}
+
+const fullSpec = `
+arazzo: 1.0.1
+info:
+  title: Pets
+  version: 1.0.0
+sourceDescriptions:
+  - name: petstore
+    url: ./petstore.yaml
+    type: openapi
+workflows:
+  - workflowId: adopt-pet
+    summary: Adopt a pet
+    description: Finds a pet and places an order.
+    inputs:
+      type: object
+      required: [petId]
+      properties:
+        petId:
+          type: integer
+    parameters:
+      - name: X-Trace
+        in: header
+        value: $inputs.traceId
+    failureActions:
+      - reference: $components.failureActions.retryTwice
+    steps:
+      - stepId: find
+        description: Looks up the pet.
+        operationId: $sourceDescriptions.petstore.getPet
+        x-pre-hook: hooks/validate_user.go
+        parameters:
+          - name: petId
+            in: path
+            value: $inputs.petId
+          - reference: $components.parameters.verbose
+            value: true
+        successCriteria:
+          - condition: $statusCode == 200
+          - context: $response.body
+            condition: $[?(@.status == 'available')]
+            type: jsonpath
+        onSuccess:
+          - name: done
+            type: goto
+            stepId: order
+            criteria:
+              - condition: $statusCode == 200
+        outputs:
+          status: $response.body#/status
+      - stepId: order
+        operationPath: '{$sourceDescriptions.petstore.url}#/paths/~1orders/post'
+        x-post-hook: hooks/log_result.go
+        x-depends-on: find
+        requestBody:
+          contentType: application/json
+          payload:
+            petId: 0
+            quantity: 1
+          replacements:
+            - target: /petId
+              value: $inputs.petId
+        onFailure:
+          - name: retry
+            type: retry
+            retryAfter: 1.5
+            retryLimit: 3
+    outputs:
+      status: $steps.find.outputs.status
+components:
+  parameters:
+    verbose:
+      name: verbose
+      in: query
+      value: false
+  failureActions:
+    retryTwice:
+      name: retryTwice
+      type: retry
+      retryLimit: 2
+`
+
+func TestParse_PreservesArazzoFields(t *testing.T) {
+	p := NewArazzoParser(createTempSpec(t, fullSpec))
+	require.NoError(t, p.Parse())
+	require.NotNil(t, p.Document)
+	require.Len(t, p.Flows, 1)
+
+	flow := p.Flows[0]
+	require.Equal(t, "adopt-pet", flow.WorkflowID)
+	require.Equal(t, "Adopt a pet", flow.Summary)
+	require.Equal(t, "Finds a pet and places an order.", flow.Description)
+	require.Equal(t, []flowcompiler.SourceDescription{{Name: "petstore", URL: "./petstore.yaml", Type: "openapi"}}, flow.SourceDescriptions)
+	require.Equal(t, "object", flow.Inputs["type"])
+	require.Equal(t, []interface{}{"petId"}, flow.Inputs["required"])
+	require.Equal(t, []flowcompiler.StepParameter{{Name: "X-Trace", In: "header", Value: "$inputs.traceId"}}, flow.Parameters)
+	require.Equal(t, []flowcompiler.Action{{Reference: "$components.failureActions.retryTwice"}}, flow.FailureActions)
+	require.Equal(t, map[string]string{"status": "$steps.find.outputs.status"}, flow.Outputs)
+
+	require.Len(t, flow.Steps, 2)
+	find := flow.Steps[0]
+	require.Equal(t, "find", find.ID)
+	require.Equal(t, "Looks up the pet.", find.Description)
+	require.Equal(t, "$sourceDescriptions.petstore.getPet", find.Call)
+	require.Equal(t, "hooks/validate_user.go", find.PreHook)
+	require.Equal(t, []flowcompiler.StepParameter{
+		{Name: "petId", In: "path", Value: "$inputs.petId"},
+		{Reference: "$components.parameters.verbose", Value: true},
+	}, find.Parameters)
+	require.Equal(t, []flowcompiler.Criterion{
+		{Condition: "$statusCode == 200", Type: "simple"},
+		{Context: "$response.body", Condition: "$[?(@.status == 'available')]", Type: "jsonpath"},
+	}, find.SuccessCriteria)
+	require.Equal(t, []flowcompiler.Action{{
+		Name: "done", Type: "goto", StepID: "order",
+		Criteria: []flowcompiler.Criterion{{Condition: "$statusCode == 200", Type: "simple"}},
+	}}, find.OnSuccess)
+	require.Equal(t, map[string]string{"status": "$response.body#/status"}, find.Outputs)
+
+	order := flow.Steps[1]
+	require.Empty(t, order.Call)
+	require.Equal(t, "{$sourceDescriptions.petstore.url}#/paths/~1orders/post", order.OperationPath)
+	require.Equal(t, "hooks/log_result.go", order.PostHook)
+	require.Equal(t, []string{"find"}, order.DependsOn)
+	require.Nil(t, find.DependsOn)
+	require.Equal(t, &flowcompiler.StepRequestBody{
+		ContentType:  "application/json",
+		Payload:      map[string]interface{}{"petId": 0, "quantity": 1},
+		Replacements: []flowcompiler.PayloadReplacement{{Target: "/petId", Value: "$inputs.petId"}},
+	}, order.RequestBody)
+	require.Len(t, order.OnFailure, 1)
+	require.Equal(t, "retry", order.OnFailure[0].Type)
+	require.Equal(t, 1.5, *order.OnFailure[0].RetryAfter)
+	require.Equal(t, 3, *order.OnFailure[0].RetryLimit)
+
+	require.NotNil(t, flow.Components)
+	require.Equal(t, flowcompiler.StepParameter{Name: "verbose", In: "query", Value: false}, flow.Components.Parameters["verbose"])
+	require.Equal(t, "retry", flow.Components.FailureActions["retryTwice"].Type)
+	require.Equal(t, 2, *flow.Components.FailureActions["retryTwice"].RetryLimit)
+}
+
+func TestParseFlows_InvalidSpec(t *testing.T) {
+	_, err := ParseFlows(createTempSpec(t, invalidSpec))
+	require.ErrorContains(t, err, "invalid Arazzo document")
+	var de *diagnostics.Error
+	require.ErrorAs(t, err, &de)
+	require.NotEmpty(t, de.Diagnostics)
+}
+
+func TestDiagnose_InvalidSpec(t *testing.T) {
+	file := createTempSpec(t, invalidSpec)
+	diags, err := Diagnose(file)
+	require.NoError(t, err)
+
+	byMessage := map[string]diagnostics.Diagnostic{}
+	for _, d := range diags {
+		require.Equal(t, file, d.File)
+		require.Equal(t, RuleValidation, d.Rule)
+		byMessage[d.Message] = d
+	}
+	missing := byMessage["field arazzo is missing"]
+	require.Equal(t, "", missing.Pointer, "the error is about the document")
+	require.Equal(t, 2, missing.Line)
+	step := byMessage["field stepId is missing"]
+	require.Equal(t, "/workflows/0/steps/0", step.Pointer)
+	require.Equal(t, 8, step.Line)
+	require.Equal(t, 9, step.Column)
+
+	diags, err = Diagnose(createTempSpec(t, validSpec))
+	require.NoError(t, err)
+	require.Empty(t, diags)
+}
+
+func TestParseFlows_InvalidPath(t *testing.T) {
+	_, err := ParseFlows("missing.yaml")
+	require.Error(t, err)
 }
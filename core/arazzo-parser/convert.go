@@ -0,0 +1,386 @@
+package arazzo_parser
+
+import (
+	"MCPGen/core/flow-compiler"
+	"fmt"
+
+	"github.com/speakeasy-api/openapi/arazzo"
+	"github.com/speakeasy-api/openapi/arazzo/criterion"
+	"github.com/speakeasy-api/openapi/arazzo/expression"
+	"github.com/speakeasy-api/openapi/extensions"
+	"github.com/speakeasy-api/openapi/jsonschema/oas31"
+	"gopkg.in/yaml.v3"
+)
+
+// Step extensions used to attach hooks to an Arazzo step.
+const (
+	PreHookExtension  = "x-pre-hook"
+	PostHookExtension = "x-post-hook"
+)
+
+// Step extensions adding explicit edges to the step graph. Each takes a step
+// ID or a list of step IDs.
+const (
+	DependsOnExtension = "x-depends-on"
+	NextExtension      = "x-next"
+)
+
+// convertDocument converts every workflow of doc, read from file. Document-level
+// source descriptions and components are shared by all returned flows.
+func convertDocument(doc *arazzo.Arazzo, file string) ([]flowcompiler.FlowDefinition, error) {
+	var sources []flowcompiler.SourceDescription
+	for _, sd := range doc.SourceDescriptions {
+		sources = append(sources, flowcompiler.SourceDescription{Name: sd.Name, URL: sd.URL, Type: string(sd.Type)})
+	}
+	components, err := convertComponents(doc.Components)
+	if err != nil {
+		return nil, err
+	}
+
+	var flows []flowcompiler.FlowDefinition
+	for _, wf := range doc.Workflows {
+		flow, err := convertWorkflow(wf, file)
+		if err != nil {
+			return nil, fmt.Errorf("workflow '%s': %w", wf.WorkflowID, err)
+		}
+		flow.SourceDescriptions = sources
+		flow.Components = components
+		flows = append(flows, flow)
+	}
+	return flows, nil
+}
+
+func convertWorkflow(wf *arazzo.Workflow, file string) (flowcompiler.FlowDefinition, error) {
+	flow := flowcompiler.FlowDefinition{
+		WorkflowID:  wf.WorkflowID,
+		SourceFile:  file,
+		Location:    workflowLocation(wf, file),
+		Summary:     stringValue(wf.Summary),
+		Description: stringValue(wf.Description),
+		Outputs:     convertOutputs(wf.Outputs),
+	}
+	var err error
+	if flow.Inputs, err = convertSchema(wf.Inputs); err != nil {
+		return flow, fmt.Errorf("inputs: %w", err)
+	}
+	for _, dep := range wf.DependsOn {
+		flow.DependsOn = append(flow.DependsOn, string(dep))
+	}
+	if flow.Parameters, err = convertParameters(wf.Parameters); err != nil {
+		return flow, err
+	}
+	flow.SuccessActions = convertSuccessActions(wf.SuccessActions)
+	flow.FailureActions = convertFailureActions(wf.FailureActions)
+
+	for _, step := range wf.Steps {
+		fs, err := convertStep(step)
+		fs.Location = stepLocation(step, file)
+		if err != nil {
+			return flow, fmt.Errorf("step '%s': %w", step.StepID, err)
+		}
+		flow.Steps = append(flow.Steps, fs)
+	}
+	return flow, nil
+}
+
+func convertStep(step *arazzo.Step) (flowcompiler.FlowStep, error) {
+	fs := flowcompiler.FlowStep{
+		ID:              step.StepID,
+		Description:     stringValue(step.Description),
+		Call:            expressionValue(step.OperationID),
+		OperationPath:   expressionValue(step.OperationPath),
+		WorkflowID:      expressionValue(step.WorkflowID),
+		SuccessCriteria: convertCriteria(step.SuccessCriteria),
+		OnSuccess:       convertSuccessActions(step.OnSuccess),
+		OnFailure:       convertFailureActions(step.OnFailure),
+		Outputs:         convertOutputs(step.Outputs),
+	}
+	var err error
+	if fs.Parameters, err = convertParameters(step.Parameters); err != nil {
+		return fs, err
+	}
+	if step.RequestBody != nil {
+		body := &flowcompiler.StepRequestBody{ContentType: stringValue(step.RequestBody.ContentType)}
+		if body.Payload, err = decodeNode(step.RequestBody.Payload); err != nil {
+			return fs, fmt.Errorf("requestBody payload: %w", err)
+		}
+		for _, r := range step.RequestBody.Replacements {
+			value, err := decodeNode(r.Value)
+			if err != nil {
+				return fs, fmt.Errorf("requestBody replacement %s: %w", r.Target, err)
+			}
+			body.Replacements = append(body.Replacements, flowcompiler.PayloadReplacement{Target: string(r.Target), Value: value})
+		}
+		fs.RequestBody = body
+	}
+	fs.PreHook = extensionString(step.Extensions, PreHookExtension)
+	fs.PostHook = extensionString(step.Extensions, PostHookExtension)
+	if fs.DependsOn, err = extensionList(step.Extensions, DependsOnExtension); err != nil {
+		return fs, err
+	}
+	if fs.Next, err = extensionList(step.Extensions, NextExtension); err != nil {
+		return fs, err
+	}
+	return fs, nil
+}
+
+// stepLocation points at the operation reference of step, or at the step itself
+// when it has none.
+func workflowLocation(wf *arazzo.Workflow, file string) flowcompiler.Location {
+	loc := flowcompiler.Location{File: file}
+	core := wf.GetCore()
+	if core == nil {
+		return loc
+	}
+	node := core.RootNode
+	if core.WorkflowID.ValueNode != nil {
+		node = core.WorkflowID.ValueNode
+	}
+	if node != nil {
+		loc.Line, loc.Column = node.Line, node.Column
+	}
+	return loc
+}
+
+func stepLocation(step *arazzo.Step, file string) flowcompiler.Location {
+	loc := flowcompiler.Location{File: file}
+	core := step.GetCore()
+	if core == nil {
+		return loc
+	}
+	node := core.RootNode
+	for _, n := range []*yaml.Node{core.OperationID.ValueNode, core.OperationPath.ValueNode, core.WorkflowID.ValueNode} {
+		if n != nil {
+			node = n
+			break
+		}
+	}
+	if node != nil {
+		loc.Line, loc.Column = node.Line, node.Column
+	}
+	return loc
+}
+
+func convertParameters(params []*arazzo.ReusableParameter) ([]flowcompiler.StepParameter, error) {
+	var out []flowcompiler.StepParameter
+	for _, p := range params {
+		var param flowcompiler.StepParameter
+		var err error
+		if p.IsReference() {
+			param.Reference = string(*p.Reference)
+			param.Value, err = decodeNode(p.Value)
+		} else if p.Object != nil {
+			param, err = convertParameter(p.Object)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parameter %s: %w", param.Name, err)
+		}
+		out = append(out, param)
+	}
+	return out, nil
+}
+
+func convertParameter(p *arazzo.Parameter) (flowcompiler.StepParameter, error) {
+	param := flowcompiler.StepParameter{Name: p.Name}
+	if p.In != nil {
+		param.In = string(*p.In)
+	}
+	value, err := decodeNode(p.Value)
+	param.Value = value
+	return param, err
+}
+
+func convertSuccessActions(actions []*arazzo.ReusableSuccessAction) []flowcompiler.Action {
+	var out []flowcompiler.Action
+	for _, a := range actions {
+		if a.IsReference() {
+			out = append(out, flowcompiler.Action{Reference: string(*a.Reference)})
+		} else if a.Object != nil {
+			out = append(out, convertSuccessAction(a.Object))
+		}
+	}
+	return out
+}
+
+func convertSuccessAction(a *arazzo.SuccessAction) flowcompiler.Action {
+	return flowcompiler.Action{
+		Name:       a.Name,
+		Type:       string(a.Type),
+		WorkflowID: expressionValue(a.WorkflowID),
+		StepID:     stringValue(a.StepID),
+		Criteria:   convertCriteria(criterionPointers(a.Criteria)),
+	}
+}
+
+func convertFailureActions(actions []*arazzo.ReusableFailureAction) []flowcompiler.Action {
+	var out []flowcompiler.Action
+	for _, a := range actions {
+		if a.IsReference() {
+			out = append(out, flowcompiler.Action{Reference: string(*a.Reference)})
+		} else if a.Object != nil {
+			out = append(out, convertFailureAction(a.Object))
+		}
+	}
+	return out
+}
+
+func convertFailureAction(a *arazzo.FailureAction) flowcompiler.Action {
+	return flowcompiler.Action{
+		Name:       a.Name,
+		Type:       string(a.Type),
+		WorkflowID: expressionValue(a.WorkflowID),
+		StepID:     stringValue(a.StepID),
+		RetryAfter: a.RetryAfter,
+		RetryLimit: a.RetryLimit,
+		Criteria:   convertCriteria(criterionPointers(a.Criteria)),
+	}
+}
+
+func convertCriteria(criteria []*criterion.Criterion) []flowcompiler.Criterion {
+	var out []flowcompiler.Criterion
+	for _, c := range criteria {
+		out = append(out, flowcompiler.Criterion{
+			Context:   expressionValue(c.Context),
+			Condition: c.Condition,
+			Type:      string(c.Type.GetType()),
+			Version:   string(c.Type.GetVersion()),
+		})
+	}
+	return out
+}
+
+func criterionPointers(criteria []criterion.Criterion) []*criterion.Criterion {
+	out := make([]*criterion.Criterion, len(criteria))
+	for i := range criteria {
+		out[i] = &criteria[i]
+	}
+	return out
+}
+
+func convertComponents(c *arazzo.Components) (*flowcompiler.Components, error) {
+	if c == nil {
+		return nil, nil
+	}
+	components := &flowcompiler.Components{}
+	if c.Inputs != nil {
+		components.Inputs = map[string]interface{}{}
+		for name, schema := range c.Inputs.All() {
+			converted, err := convertSchema(schema)
+			if err != nil {
+				return nil, fmt.Errorf("components inputs %s: %w", name, err)
+			}
+			components.Inputs[name] = converted
+		}
+	}
+	if c.Parameters != nil {
+		components.Parameters = map[string]flowcompiler.StepParameter{}
+		for name, p := range c.Parameters.All() {
+			param, err := convertParameter(p)
+			if err != nil {
+				return nil, fmt.Errorf("components parameter %s: %w", name, err)
+			}
+			components.Parameters[name] = param
+		}
+	}
+	if c.SuccessActions != nil {
+		components.SuccessActions = map[string]flowcompiler.Action{}
+		for name, a := range c.SuccessActions.All() {
+			components.SuccessActions[name] = convertSuccessAction(a)
+		}
+	}
+	if c.FailureActions != nil {
+		components.FailureActions = map[string]flowcompiler.Action{}
+		for name, a := range c.FailureActions.All() {
+			components.FailureActions[name] = convertFailureAction(a)
+		}
+	}
+	return components, nil
+}
+
+func convertOutputs(outputs arazzo.Outputs) map[string]string {
+	if outputs == nil || outputs.Len() == 0 {
+		return nil
+	}
+	out := make(map[string]string, outputs.Len())
+	for name, expr := range outputs.All() {
+		out[name] = string(expr)
+	}
+	return out
+}
+
+// convertSchema decodes a JSON Schema as written in the document.
+func convertSchema(schema oas31.JSONSchema) (map[string]interface{}, error) {
+	if schema == nil {
+		return nil, nil
+	}
+	if schema.IsRight() {
+		// Boolean schemas: true accepts anything, false nothing.
+		if schema.GetRight() {
+			return map[string]interface{}{}, nil
+		}
+		return map[string]interface{}{"not": map[string]interface{}{}}, nil
+	}
+	var out map[string]interface{}
+	if node := schema.GetRootNode(); node != nil {
+		if err := node.Decode(&out); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// decodeNode decodes a literal value or runtime expression. Expressions stay strings.
+func decodeNode(node *yaml.Node) (interface{}, error) {
+	if node == nil {
+		return nil, nil
+	}
+	var v interface{}
+	if err := node.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func extensionString(e *extensions.Extensions, key string) string {
+	if e == nil {
+		return ""
+	}
+	value, err := extensions.GetExtensionValue[string](e, key)
+	if err != nil || value == nil {
+		return ""
+	}
+	return *value
+}
+
+// extensionList reads a step ID or a list of step IDs. A present but empty list
+// is returned as a non-nil empty slice.
+func extensionList(e *extensions.Extensions, key string) ([]string, error) {
+	if e == nil || !e.Has(key) {
+		return nil, nil
+	}
+	if list, err := extensions.GetExtensionValue[[]string](e, key); err == nil {
+		if *list == nil {
+			return []string{}, nil
+		}
+		return *list, nil
+	}
+	value, err := extensions.GetExtensionValue[string](e, key)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a step ID or a list of step IDs", key)
+	}
+	return []string{*value}, nil
+}
+
+func expressionValue(e *expression.Expression) string {
+	if e == nil {
+		return ""
+	}
+	return string(*e)
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
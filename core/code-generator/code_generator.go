@@ -1,64 +1,239 @@
 package codegenerator
 
 import (
-	"bytes"
+	"MCPGen/core/flow-compiler"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
 	"io/ioutil"
-	"net/http"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 )
 
-// CompiledFlow is a placeholder for the actual compiled flow structure.
-type CompiledFlow struct {
-	WorkflowID string
-	Steps      []CompiledStep
+// defaultModuleName is the Go module name of the generated server when none is set.
+const defaultModuleName = "mcp-server"
+
+// defaultMCPEndpoint is the path of the Streamable HTTP endpoint when none is set.
+const defaultMCPEndpoint = "/mcp"
+
+// Transport selects how the generated server speaks MCP.
+type Transport string
+
+const (
+	// TransportStdio serves MCP as newline-delimited JSON-RPC on stdin/stdout.
+	TransportStdio Transport = "stdio"
+	// TransportHTTP serves MCP over the Streamable HTTP transport (POST + SSE).
+	TransportHTTP Transport = "http"
+)
+
+// ValidationMode selects how the generated server checks calls to downstream
+// operations against their OpenAPI schemas.
+type ValidationMode string
+
+const (
+	// ValidationOff skips schema validation.
+	ValidationOff ValidationMode = "off"
+	// ValidationWarn logs requests and responses that do not match their
+	// schema but lets them through.
+	ValidationWarn ValidationMode = "warn"
+	// ValidationEnforce rejects invalid requests before they are sent and
+	// fails calls whose response does not match its schema.
+	ValidationEnforce ValidationMode = "enforce"
+)
+
+// InboundAuth selects how the generated server authenticates the clients of
+// its HTTP endpoints: the MCP Streamable HTTP endpoint and /run-task. MCP over
+// stdio is not authenticated. A client must pass every enabled check: a client
+// certificate with MTLS, and an API key or a JWT when either is enabled.
+type InboundAuth struct {
+	// APIKey accepts the keys listed in the server's MCP_API_KEYS_FILE in the
+	// X-API-Key header.
+	APIKey bool
+	// JWKS is the file or http(s) URL of the keys verifying JWT bearer tokens;
+	// setting it enables JWT authentication. Issuer and Audience, when set,
+	// must match the iss and aud claims.
+	JWKS     string
+	Issuer   string
+	Audience string
+	// MTLS requires client certificates; the server then serves TLS.
+	MTLS bool
+	// Scopes maps workflow IDs to the scopes a client needs to run them.
+	Scopes map[string][]string
 }
 
-type CompiledStep struct {
-	StepID   string
-	Type     string
-	Inputs   map[string]interface{}
-	Next     []string
+func (a *InboundAuth) enabled() bool {
+	return a != nil && (a.APIKey || a.JWKS != "" || a.MTLS)
 }
 
-// CodeGenerator generates Go server code using OpenAI API.
+// enhancedFile is the generated file handed to the LLM for refinement.
+const enhancedFile = "handlers.go"
+
+// ErrEnhancementFailed is returned when the LLM could not refine the generated
+// code. The deterministic template output has still been written in that case.
+var ErrEnhancementFailed = errors.New("LLM enhancement failed")
+
+// CodeGenerator renders Go server code from compiled flows. Output is produced
+// by deterministic templates; an LLM provider may optionally refine it.
 type CodeGenerator struct {
-	Flow       *CompiledFlow
-	OutputDir  string
-	LLM        LLMProvider // Strategy Pattern: pluggable provider
+	Flows       []*flowcompiler.CompiledFlow
+	Endpoints   []flowcompiler.Endpoint
+	OutputDir   string
+	ModuleName  string
+	Transport   Transport         // TransportStdio when empty
+	MCPEndpoint string            // Streamable HTTP endpoint path, "/mcp" when empty
+	Validation  ValidationMode    // ValidationWarn when empty
+	Auth        *InboundAuth      // no client authentication when nil
+	Middleware  *MiddlewareConfig // no middleware when nil
+	LLM         LLMProvider       // Strategy Pattern: pluggable provider
 }
 
-// GenerateServerCode constructs a prompt, calls OpenAI API, and writes code to file.
+// GenerateServerCode renders the server from templates and writes it to OutputDir.
+// When an LLM provider is configured, the handlers are passed to it for refinement;
+// the template output is kept whenever the provider fails or returns invalid Go.
 func (cg *CodeGenerator) GenerateServerCode() error {
-	prompt, err := cg.constructPrompt()
+	switch cg.Transport {
+	case "", TransportStdio, TransportHTTP:
+	default:
+		return fmt.Errorf("unsupported transport %q", cg.Transport)
+	}
+	switch cg.Validation {
+	case "", ValidationOff, ValidationWarn, ValidationEnforce:
+	default:
+		return fmt.Errorf("unsupported validation mode %q", cg.Validation)
+	}
+	if err := cg.checkAuth(); err != nil {
+		return err
+	}
+	files, err := cg.renderServer()
 	if err != nil {
 		return err
 	}
 
-	if cg.LLM == nil {
-		return fmt.Errorf("no LLM provider configured")
+	var enhanceErr error
+	if cg.LLM != nil {
+		enhanced, err := cg.enhance(files[enhancedFile])
+		if err != nil {
+			enhanceErr = fmt.Errorf("%w: %v", ErrEnhancementFailed, err)
+		} else {
+			files[enhancedFile] = enhanced
+		}
 	}
 
-	code, err := cg.LLM.GenerateCode(prompt)
-	if err != nil {
+	if err := cg.writeFiles(files); err != nil {
 		return err
 	}
+	return enhanceErr
+}
+
+// checkAuth rejects scopes of unknown workflows, and scopes no client could
+// hold because authentication is disabled.
+func (cg *CodeGenerator) checkAuth() error {
+	if cg.Auth == nil || len(cg.Auth.Scopes) == 0 {
+		return nil
+	}
+	if !cg.Auth.enabled() {
+		return errors.New("workflow scopes require API key, JWT or mTLS authentication")
+	}
+	known := map[string]bool{}
+	for _, flow := range cg.Flows {
+		known[flow.WorkflowID] = true
+	}
+	for id := range cg.Auth.Scopes {
+		if !known[id] {
+			return fmt.Errorf("scopes given for unknown workflow %q", id)
+		}
+	}
+	return nil
+}
 
-	outputFile := cg.OutputDir + "/server.go"
-	if err := ioutil.WriteFile(outputFile, []byte(code), 0644); err != nil {
-		return fmt.Errorf("failed to write generated code: %w", err)
+func (cg *CodeGenerator) writeFiles(files map[string][]byte) error {
+	if err := os.MkdirAll(cg.OutputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		outputFile := filepath.Join(cg.OutputDir, name)
+		if err := os.MkdirAll(filepath.Dir(outputFile), 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+		if err := ioutil.WriteFile(outputFile, files[name], 0644); err != nil {
+			return fmt.Errorf("failed to write generated code: %w", err)
+		}
 	}
 	return nil
 }
 
-func (cg *CodeGenerator) constructPrompt() (string, error) {
-	flowJson, err := json.MarshalIndent(cg.Flow, "", "  ")
+// enhance asks the LLM to refine a rendered file and accepts the answer only if
+// it is a well-formed file of the same package.
+func (cg *CodeGenerator) enhance(source []byte) ([]byte, error) {
+	prompt, err := cg.constructPrompt(source)
+	if err != nil {
+		return nil, err
+	}
+	code, err := cg.LLM.GenerateCode(prompt)
+	if err != nil {
+		return nil, err
+	}
+	code = stripCodeFence(code)
+	file, err := parser.ParseFile(token.NewFileSet(), enhancedFile, code, 0)
+	if err != nil {
+		return nil, fmt.Errorf("provider returned invalid Go: %w", err)
+	}
+	if file.Name.Name != "main" {
+		return nil, fmt.Errorf("provider returned package %s, expected main", file.Name.Name)
+	}
+	original, err := parser.ParseFile(token.NewFileSet(), enhancedFile, source, 0)
+	if err != nil {
+		return nil, err
+	}
+	declared := map[string]bool{}
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil {
+			declared[fn.Name.Name] = true
+		}
+	}
+	for _, decl := range original.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && !declared[fn.Name.Name] {
+			return nil, fmt.Errorf("provider dropped function %s", fn.Name.Name)
+		}
+	}
+	formatted, err := format.Source([]byte(code))
+	if err != nil {
+		return nil, fmt.Errorf("provider returned invalid Go: %w", err)
+	}
+	return formatted, nil
+}
+
+func (cg *CodeGenerator) constructPrompt(source []byte) (string, error) {
+	flowJson, err := json.MarshalIndent(cg.Flows, "", "  ")
 	if err != nil {
 		return "", fmt.Errorf("failed to marshal flow: %w", err)
 	}
-	prompt := "Generate idiomatic Go server code for the following workflow. Include endpoint handlers, orchestration, hooks, and error handling.\n" + string(flowJson)
+	prompt := "Improve the following generated Go file of an MCP server for the workflows below. Keep every exported and unexported identifier, " +
+		"improve error handling and logging, and return only the complete Go file.\nWorkflows:\n" + string(flowJson) +
+		"\n" + enhancedFile + ":\n" + string(source)
 	return prompt, nil
 }
 
-
+// stripCodeFence removes a Markdown code fence around an LLM answer.
+func stripCodeFence(code string) string {
+	code = strings.TrimSpace(code)
+	if !strings.HasPrefix(code, "```") {
+		return code
+	}
+	code = strings.TrimPrefix(code, "```")
+	if nl := strings.IndexByte(code, '\n'); nl >= 0 {
+		code = code[nl+1:]
+	}
+	return strings.TrimSuffix(strings.TrimSpace(code), "```")
+}
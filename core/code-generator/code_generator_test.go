@@ -0,0 +1,166 @@
+package codegenerator
+
+import (
+	"MCPGen/core/flow-compiler"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubLLM struct {
+	code string
+	err  error
+}
+
+func (s *stubLLM) GenerateCode(prompt string) (string, error) {
+	return s.code, s.err
+}
+
+func testGenerator(t *testing.T) *CodeGenerator {
+	t.Helper()
+	endpoints := []flowcompiler.Endpoint{
+		{
+			ID: "getUser", Method: "GET", Path: "/users/{id}", BaseURL: "https://users.example.com",
+			Parameters: []flowcompiler.Parameter{{Name: "id", In: "path", Required: true}},
+		},
+		{
+			ID: "syncData", Method: "POST", Path: "/sync", BaseURL: "https://sync.example.com",
+			RequestBody: &flowcompiler.RequestBody{ContentType: "application/json"},
+		},
+	}
+	flows := []flowcompiler.FlowDefinition{{
+		WorkflowID: "sync-user-data",
+		SourceFile: "testdata/sync.arazzo.yaml",
+		Steps: []flowcompiler.FlowStep{
+			{ID: "getUser", Call: "getUser", PreHook: "hooks/validate_user.go"},
+			{ID: "syncData", Call: "syncData", PostHook: "hooks/log_result.go"},
+		},
+	}}
+	compiled, err := flowcompiler.NewFlowCompiler(endpoints, flows).Compile()
+	require.NoError(t, err)
+	return &CodeGenerator{Flows: compiled, Endpoints: endpoints, OutputDir: t.TempDir()}
+}
+
+func readTree(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	files := map[string]string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		rel, _ := filepath.Rel(dir, path)
+		files[rel] = string(data)
+		return err
+	})
+	require.NoError(t, err)
+	return files
+}
+
+func TestGenerateServerCode_WithoutLLM(t *testing.T) {
+	cg := testGenerator(t)
+	require.NoError(t, cg.GenerateServerCode())
+
+	files := readTree(t, cg.OutputDir)
+	for _, name := range []string{"go.mod", "main.go", "handlers.go", "flows.go", "engine.go", "hooks.go", "hook_files.go", "hooks/step_context.go", "hooks/validate_user.go"} {
+		assert.Contains(t, files, name)
+	}
+	assert.NotContains(t, files, "streamable.go")
+	assert.Contains(t, files["go.mod"], "module mcp-server")
+	assert.Contains(t, files["flows.go"], "var flowSyncUserData = &Flow{")
+	assert.Contains(t, files["handlers.go"], `mux.HandleFunc("/run-task/sync-user-data", handleSyncUserData)`)
+	assert.Contains(t, files["hook_files.go"], `plugins.RegisterPreHook("hooks/validate_user.go", 0, preHookFunc(hooks.ValidateUser))`)
+	assert.Contains(t, files["hook_files.go"], `plugins.RegisterPostHook("hooks/log_result.go", 0, postHookFunc(hooks.LogResult))`)
+	assert.Contains(t, files["hook_files.go"], `"mcp-server/hooks"`)
+}
+
+func TestGenerateServerCode_Deterministic(t *testing.T) {
+	first := testGenerator(t)
+	require.NoError(t, first.GenerateServerCode())
+	second := testGenerator(t)
+	require.NoError(t, second.GenerateServerCode())
+
+	assert.Equal(t, readTree(t, first.OutputDir), readTree(t, second.OutputDir))
+}
+
+// buildServer generates cg into its output directory and builds the binary.
+func buildServer(t *testing.T, cg *CodeGenerator) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("skipping build of generated server in short mode")
+	}
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain not available")
+	}
+	require.NoError(t, cg.GenerateServerCode())
+
+	bin := filepath.Join(t.TempDir(), "server")
+	for _, args := range [][]string{{"vet", "./..."}, {"build", "-o", bin, "."}} {
+		cmd := exec.Command(goBin, args...)
+		cmd.Dir = cg.OutputDir
+		cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off")
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+	return bin
+}
+
+func TestGenerateServerCode_Compiles(t *testing.T) {
+	buildServer(t, testGenerator(t))
+}
+
+func TestGenerateServerCode_NestedWorkflow(t *testing.T) {
+	cg := testGenerator(t)
+	flows := []flowcompiler.FlowDefinition{
+		{WorkflowID: "sync-user-data", Steps: []flowcompiler.FlowStep{{ID: "getUser", Call: "getUser"}}},
+		{WorkflowID: "onboard-user", Steps: []flowcompiler.FlowStep{{ID: "sync", WorkflowID: "sync-user-data"}}},
+	}
+	compiled, err := flowcompiler.NewFlowCompiler(cg.Endpoints, flows).Compile()
+	require.NoError(t, err)
+	cg.Flows = compiled
+
+	data := cg.templateData()
+	require.Len(t, data.Tools, 4)
+	assert.Equal(t, "onboard-user", data.Tools[0].Name)
+	assert.Contains(t, data.Tools[0].InputSchema, `"id"`)
+
+	buildServer(t, cg)
+	files := readTree(t, cg.OutputDir)
+	assert.Contains(t, files["flows.go"], `{ID: "sync", Workflow: "sync-user-data", PreHook: "", PostHook: ""}`)
+}
+
+func TestGenerateServerCode_LLMEnhancement(t *testing.T) {
+	cg := testGenerator(t)
+	rendered, err := cg.renderServer()
+	require.NoError(t, err)
+
+	cg.LLM = &stubLLM{code: "```go\n" + string(rendered["handlers.go"]) + "\n// refined\n```"}
+	require.NoError(t, cg.GenerateServerCode())
+	files := readTree(t, cg.OutputDir)
+	assert.Contains(t, files["handlers.go"], "// refined")
+}
+
+func TestGenerateServerCode_LLMFallback(t *testing.T) {
+	tests := map[string]*stubLLM{
+		"provider error":    {err: errors.New("quota exceeded")},
+		"invalid go":        {code: "this is not go"},
+		"dropped functions": {code: "package main\n"},
+	}
+	for name, llm := range tests {
+		t.Run(name, func(t *testing.T) {
+			cg := testGenerator(t)
+			cg.LLM = llm
+			err := cg.GenerateServerCode()
+			require.ErrorIs(t, err, ErrEnhancementFailed)
+
+			files := readTree(t, cg.OutputDir)
+			assert.Contains(t, files["handlers.go"], "func registerTaskHandlers")
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package codegenerator
+
+import (
+	pluginmanager "MCPGen/core/plugin-manager"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/tetratelabs/wazero"
+)
+
+// hooksPackage is the directory, and package name, of the hook files in the
+// generated module.
+const hooksPackage = "hooks"
+
+// hookContextFile declares StepContext and StepResponse in the hooks package;
+// no hook file may take its name.
+const hookContextFile = "step_context.go"
+
+// hookFileView is a Go or WebAssembly hook file named by an x-pre-hook or
+// x-post-hook extension.
+type hookFileView struct {
+	// Ref is the reference as the steps write it; the hook is registered
+	// under it.
+	Ref string
+	// Name is the file name in the hooks directory.
+	Name string
+	// Func is the hook function a Go file exports.
+	Func string
+	Post bool
+	// WASM marks a WebAssembly module, whose pre_hook and post_hook exports
+	// are registered together.
+	WASM bool
+	path string
+}
+
+// isHookFile reports whether a hook reference names a Go file or a
+// WebAssembly module rather than a hook registered by hand.
+func isHookFile(ref string) bool {
+	return strings.HasSuffix(ref, ".go") || isWASMHook(ref)
+}
+
+func isWASMHook(ref string) bool {
+	return strings.HasSuffix(ref, ".wasm")
+}
+
+// hookFiles locates the hook files the steps name, relative to the Arazzo
+// document of their workflow, and checks that each exports its hook. The
+// files are returned by their path in the generated module.
+func (cg *CodeGenerator) hookFiles() ([]*hookFileView, map[string][]byte, error) {
+	byRef := map[string]*hookFileView{}
+	sources := map[string][]byte{}
+	paths := map[string]string{}
+	add := func(flow, step, ref, source string, post bool) error {
+		stage := "pre-hook"
+		if post {
+			stage = "post-hook"
+		}
+		path := ref
+		if !filepath.IsAbs(path) && source != "" {
+			path = filepath.Join(filepath.Dir(source), path)
+		}
+		key := stage + " " + ref
+		if view, ok := byRef[key]; ok {
+			if filepath.Clean(view.path) != filepath.Clean(path) {
+				return fmt.Errorf("%s %s names both %s and %s", stage, ref, view.path, path)
+			}
+			return nil
+		}
+		name := filepath.Base(path)
+		if name == hookContextFile {
+			return fmt.Errorf("%s %s of step '%s' in workflow '%s': %s is reserved for the hooks package", stage, ref, step, flow, name)
+		}
+		if other, ok := paths[name]; ok && filepath.Clean(other) != filepath.Clean(path) {
+			return fmt.Errorf("hook files %s and %s would both be copied to %s/%s", other, path, hooksPackage, name)
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("cannot read %s %s of step '%s' in workflow '%s': %w", stage, ref, step, flow, err)
+		}
+		view := &hookFileView{Ref: ref, Name: name, Post: post, WASM: isWASMHook(ref), path: path}
+		if view.WASM {
+			err = checkWASMHookFile(src, post)
+		} else {
+			view.Func, err = checkHookFile(path, src, post)
+		}
+		if err != nil {
+			return fmt.Errorf("%s %s of step '%s' in workflow '%s': %w", stage, ref, step, flow, err)
+		}
+		byRef[key] = view
+		paths[name] = path
+		sources[hooksPackage+"/"+name] = src
+		return nil
+	}
+
+	for _, flow := range cg.Flows {
+		for _, step := range flow.Steps {
+			if isHookFile(step.PreHook) {
+				if err := add(flow.WorkflowID, step.StepID, step.PreHook, flow.SourceFile, false); err != nil {
+					return nil, nil, err
+				}
+			}
+			if isHookFile(step.PostHook) {
+				if err := add(flow.WorkflowID, step.StepID, step.PostHook, flow.SourceFile, true); err != nil {
+					return nil, nil, err
+				}
+			}
+		}
+	}
+
+	views := make([]*hookFileView, 0, len(byRef))
+	for _, view := range byRef {
+		// A module registers its pre- and post-hook at once.
+		if view.WASM && view.Post && byRef["pre-hook "+view.Ref] != nil {
+			continue
+		}
+		views = append(views, view)
+	}
+	sort.Slice(views, func(i, j int) bool {
+		if views[i].Ref != views[j].Ref {
+			return views[i].Ref < views[j].Ref
+		}
+		return !views[i].Post
+	})
+	return views, sources, nil
+}
+
+// checkHookFile checks that a hook file belongs to the hooks package, imports
+// only the standard library, which is all the generated module requires, and
+// exports the hook function named after the file. It returns the name of the
+// function.
+func checkHookFile(path string, src []byte, post bool) (string, error) {
+	fn, err := exportedName(path)
+	if err != nil {
+		return "", err
+	}
+	want := exportedFunc{
+		kind:    "hook file",
+		pkg:     hooksPackage,
+		name:    fn,
+		params:  []string{"context.Context", "*StepContext"},
+		results: []string{"error"},
+		display: fmt.Sprintf("func %s(ctx context.Context, sc *StepContext) error", fn),
+	}
+	if post {
+		want.params = append(want.params, "*StepResponse")
+		want.display = fmt.Sprintf("func %s(ctx context.Context, sc *StepContext, resp *StepResponse) error", fn)
+	}
+	if err := checkGoFile(path, src, want); err != nil {
+		return "", err
+	}
+	return fn, nil
+}
+
+// checkWASMHookFile checks that a WebAssembly hook module compiles and has
+// the exports the plugin manager calls for its stage.
+func checkWASMHookFile(src []byte, post bool) error {
+	ctx := context.Background()
+	// The interpreter compiles fastest, and the module is only inspected.
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigInterpreter())
+	defer r.Close(ctx)
+	compiled, err := r.CompileModule(ctx, src)
+	if err != nil {
+		return fmt.Errorf("failed to compile wasm module: %w", err)
+	}
+	export := pluginmanager.WASMPreHookExport
+	if post {
+		export = pluginmanager.WASMPostHookExport
+	}
+	functions := compiled.ExportedFunctions()
+	for _, name := range []string{pluginmanager.WASMAllocExport, export} {
+		if _, ok := functions[name]; !ok {
+			return fmt.Errorf("wasm module must export %s", name)
+		}
+	}
+	if len(compiled.ExportedMemories()) == 0 {
+		return fmt.Errorf("wasm module must export its memory")
+	}
+	return nil
+}
+
+// exportedFunc is the function a Go file copied into a package of the server
+// must export.
+type exportedFunc struct {
+	// kind names the file in errors.
+	kind string
+	pkg  string
+	name string
+	// params and results are the types of the function, with packages named
+	// by import path.
+	params  []string
+	results []string
+	// display is the signature shown in errors.
+	display string
+}
+
+// exportedName returns the name of the function a Go file exports: its file
+// name in camel case.
+func exportedName(path string) (string, error) {
+	fn := goName(strings.TrimSuffix(filepath.Base(path), ".go"))
+	if !unicode.IsUpper([]rune(fn)[0]) {
+		return "", fmt.Errorf("cannot name a function after %s", filepath.Base(path))
+	}
+	return fn, nil
+}
+
+// checkGoFile checks that a Go file belongs to package want.pkg, imports only
+// the standard library, which is all the generated module requires, and
+// exports the function want.
+func checkGoFile(path string, src []byte, want exportedFunc) error {
+	file, err := parser.ParseFile(token.NewFileSet(), path, src, parser.SkipObjectResolution)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", want.kind, err)
+	}
+	if file.Name.Name != want.pkg {
+		return fmt.Errorf("%s must be in package %s, not %s", want.kind, want.pkg, file.Name.Name)
+	}
+	imports := map[string]string{}
+	for _, spec := range file.Imports {
+		importPath, _ := strconv.Unquote(spec.Path.Value)
+		if first, _, _ := strings.Cut(importPath, "/"); strings.Contains(first, ".") {
+			return fmt.Errorf("%s imports %s, which is not in the standard library", want.kind, importPath)
+		}
+		name := pathpkg.Base(importPath)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		imports[name] = importPath
+	}
+
+	for _, decl := range file.Decls {
+		f, ok := decl.(*ast.FuncDecl)
+		if !ok || f.Recv != nil || f.Name.Name != want.name {
+			continue
+		}
+		if !slices.Equal(fieldTypes(f.Type.Params, imports), want.params) || !slices.Equal(fieldTypes(f.Type.Results, imports), want.results) {
+			return fmt.Errorf("%s must have the signature %s", want.name, want.display)
+		}
+		return nil
+	}
+	return fmt.Errorf("%s must export %s", want.kind, want.display)
+}
+
+// fieldTypes returns the type of every parameter or result of a list.
+func fieldTypes(fields *ast.FieldList, imports map[string]string) []string {
+	if fields == nil {
+		return nil
+	}
+	var out []string
+	for _, field := range fields.List {
+		t := qualifiedType(field.Type, imports)
+		for n := max(len(field.Names), 1); n > 0; n-- {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// qualifiedType renders a type expression, naming packages by import path.
+func qualifiedType(expr ast.Expr, imports map[string]string) string {
+	switch e := expr.(type) {
+	case *ast.StarExpr:
+		return "*" + qualifiedType(e.X, imports)
+	case *ast.SelectorExpr:
+		if pkg, ok := e.X.(*ast.Ident); ok && imports[pkg.Name] != "" {
+			return imports[pkg.Name] + "." + e.Sel.Name
+		}
+	}
+	return types.ExprString(expr)
+}
@@ -0,0 +1,120 @@
+package codegenerator
+
+import (
+	"MCPGen/core/flow-compiler"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckHookFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		src     string
+		post    bool
+		wantErr string
+	}{
+		{
+			name: "pre-hook",
+			file: "validate_user.go",
+			src:  "package hooks\n\nimport ctx \"context\"\n\nfunc ValidateUser(c ctx.Context, sc *StepContext) error { return nil }\n",
+		},
+		{
+			name: "post-hook with grouped parameters",
+			file: "log-result.go",
+			src:  "package hooks\n\nimport \"context\"\n\nfunc LogResult(_ context.Context, sc *StepContext, resp *StepResponse) (err error) { return nil }\n",
+			post: true,
+		},
+		{
+			name:    "wrong package",
+			file:    "validate_user.go",
+			src:     "package main\n",
+			wantErr: "hook file must be in package hooks, not main",
+		},
+		{
+			name:    "missing function",
+			file:    "validate_user.go",
+			src:     "package hooks\n\nfunc Validate() {}\n",
+			wantErr: "hook file must export func ValidateUser(ctx context.Context, sc *StepContext) error",
+		},
+		{
+			name:    "post-hook used as pre-hook",
+			file:    "log_result.go",
+			src:     "package hooks\n\nimport \"context\"\n\nfunc LogResult(ctx context.Context, sc *StepContext, resp *StepResponse) error { return nil }\n",
+			wantErr: "LogResult must have the signature func LogResult(ctx context.Context, sc *StepContext) error",
+		},
+		{
+			name:    "method",
+			file:    "validate_user.go",
+			src:     "package hooks\n\nimport \"context\"\n\ntype v struct{}\n\nfunc (v) ValidateUser(ctx context.Context, sc *StepContext) error { return nil }\n",
+			wantErr: "hook file must export",
+		},
+		{
+			name:    "third-party import",
+			file:    "validate_user.go",
+			src:     "package hooks\n\nimport \"github.com/google/uuid\"\n\nvar _ = uuid.New\n",
+			wantErr: "hook file imports github.com/google/uuid, which is not in the standard library",
+		},
+		{
+			name:    "syntax error",
+			file:    "validate_user.go",
+			src:     "package hooks\n\nfunc {",
+			wantErr: "failed to parse hook file",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := checkHookFile(tt.file, []byte(tt.src), tt.post)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestHookFiles_Errors(t *testing.T) {
+	dir := t.TempDir()
+	other := filepath.Join(dir, "other")
+	require.NoError(t, os.MkdirAll(other, 0o755))
+	src := []byte("package hooks\n\nimport \"context\"\n\nfunc ValidateUser(ctx context.Context, sc *StepContext) error { return nil }\n")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "validate_user.go"), src, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(other, "validate_user.go"), src, 0o644))
+
+	generate := func(steps ...flowcompiler.CompiledStep) error {
+		cg := &CodeGenerator{
+			Flows:     []*flowcompiler.CompiledFlow{{WorkflowID: "wf", SourceFile: filepath.Join(dir, "wf.arazzo.yaml"), Steps: steps}},
+			OutputDir: t.TempDir(),
+		}
+		return cg.GenerateServerCode()
+	}
+
+	err := generate(flowcompiler.CompiledStep{StepID: "a", PreHook: "missing.go"})
+	assert.ErrorContains(t, err, "cannot read pre-hook missing.go of step 'a' in workflow 'wf'")
+
+	err = generate(flowcompiler.CompiledStep{StepID: "a", PostHook: "validate_user.go"})
+	assert.ErrorContains(t, err, "post-hook validate_user.go of step 'a' in workflow 'wf': ValidateUser must have the signature")
+
+	err = generate(
+		flowcompiler.CompiledStep{StepID: "a", PreHook: "validate_user.go"},
+		flowcompiler.CompiledStep{StepID: "b", PreHook: "other/validate_user.go"},
+	)
+	assert.ErrorContains(t, err, "would both be copied to hooks/validate_user.go")
+
+	// The smallest valid module exports nothing.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "empty.wasm"), []byte("\x00asm\x01\x00\x00\x00"), 0o644))
+	err = generate(flowcompiler.CompiledStep{StepID: "a", PostHook: "empty.wasm"})
+	assert.ErrorContains(t, err, "post-hook empty.wasm of step 'a' in workflow 'wf': wasm module must export alloc")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.wasm"), []byte("not wasm"), 0o644))
+	err = generate(flowcompiler.CompiledStep{StepID: "a", PreHook: "broken.wasm"})
+	assert.ErrorContains(t, err, "failed to compile wasm module")
+
+	// Names that are not hook files are left for hooks registered by hand.
+	assert.NoError(t, generate(flowcompiler.CompiledStep{StepID: "a", PreHook: "validate_user", PostHook: "log_result"}))
+}
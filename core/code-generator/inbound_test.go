@@ -0,0 +1,246 @@
+package codegenerator
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// signJWT returns a compact JWS of claims signed with key, RS256 for RSA and
+// ES256 for P-256 keys.
+func signJWT(t *testing.T, kid string, key crypto.Signer, claims map[string]any) string {
+	t.Helper()
+	alg := "RS256"
+	if _, ok := key.(*ecdsa.PrivateKey); ok {
+		alg = "ES256"
+	}
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(signed))
+	var sig []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		var err error
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		require.NoError(t, err)
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return signed + "." + b64(sig)
+}
+
+func runTaskRequest(t *testing.T, base string, header http.Header, client *http.Client) int {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, base+"/run-task/sync-user-data", bytes.NewReader([]byte(`{"id":"7"}`)))
+	require.NoError(t, err)
+	req.Header = header
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestGeneratedServer_InboundAPIKeyAndJWT(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	var jwksFetches int32
+	jwksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&jwksFetches, 1)
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{
+			{"kty": "RSA", "kid": "rsa", "use": "sig", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+			{"kty": "EC", "kid": "ec", "crv": "P-256", "x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32)))},
+		}})
+	}))
+	t.Cleanup(jwksServer.Close)
+
+	cg := testGenerator(t)
+	cg.Transport = TransportHTTP
+	cg.Auth = &InboundAuth{
+		APIKey:   true,
+		JWKS:     jwksServer.URL,
+		Issuer:   "https://issuer.example.com",
+		Audience: "mcp",
+		Scopes:   map[string][]string{"sync-user-data": {"users:sync"}},
+	}
+	bin := buildServer(t, cg)
+	keys := filepath.Join(t.TempDir(), "keys.json")
+	require.NoError(t, os.WriteFile(keys, []byte(`{"admin-key": ["*"], "read-key": ["users:read"]}`), 0o600))
+	mcpURL := startHTTPServer(t, bin, "MCP_UPSTREAM_BASE_URL="+upstream(t).URL, "MCP_API_KEYS_FILE="+keys)
+	base := mcpURL[:len(mcpURL)-len("/mcp")]
+
+	claims := func(extra map[string]any) map[string]any {
+		c := map[string]any{"sub": "client-1", "iss": "https://issuer.example.com", "aud": []string{"mcp"},
+			"exp": time.Now().Add(time.Hour).Unix(), "scope": "users:read users:sync"}
+		for k, v := range extra {
+			c[k] = v
+		}
+		return c
+	}
+	bearer := func(token string) http.Header {
+		return http.Header{"Authorization": {"Bearer " + token}, "Content-Type": {"application/json"}}
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, runTaskRequest(t, base, http.Header{}, nil))
+	assert.Equal(t, http.StatusUnauthorized, runTaskRequest(t, base, http.Header{"X-Api-Key": {"nope"}}, nil))
+	assert.Equal(t, http.StatusOK, runTaskRequest(t, base, http.Header{"X-Api-Key": {"admin-key"}}, nil))
+	assert.Equal(t, http.StatusForbidden, runTaskRequest(t, base, http.Header{"X-Api-Key": {"read-key"}}, nil))
+
+	assert.Equal(t, http.StatusOK, runTaskRequest(t, base, bearer(signJWT(t, "rsa", rsaKey, claims(nil))), nil))
+	assert.Equal(t, http.StatusOK, runTaskRequest(t, base, bearer(signJWT(t, "ec", ecKey, claims(map[string]any{"scope": nil, "scp": []string{"users:sync"}}))), nil))
+	assert.Equal(t, http.StatusForbidden, runTaskRequest(t, base, bearer(signJWT(t, "rsa", rsaKey, claims(map[string]any{"scope": "users:read"}))), nil))
+	for name, token := range map[string]string{
+		"expired":     signJWT(t, "rsa", rsaKey, claims(map[string]any{"exp": time.Now().Add(-time.Hour).Unix()})),
+		"issuer":      signJWT(t, "rsa", rsaKey, claims(map[string]any{"iss": "https://evil.example.com"})),
+		"audience":    signJWT(t, "rsa", rsaKey, claims(map[string]any{"aud": "other"})),
+		"wrong key":   signJWT(t, "ec", rsaKey, claims(nil)),
+		"unknown key": signJWT(t, "other", rsaKey, claims(nil)),
+		"garbage":     "not-a-token",
+	} {
+		assert.Equal(t, http.StatusUnauthorized, runTaskRequest(t, base, bearer(token), nil), name)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&jwksFetches), "the JWKS is cached")
+
+	unauthenticated := postMCP(t, mcpURL, "", "application/json, text/event-stream", map[string]any{"jsonrpc": "2.0", "id": 1, "method": "initialize",
+		"params": map[string]any{"protocolVersion": "2025-03-26"}})
+	assert.Equal(t, http.StatusUnauthorized, unauthenticated.StatusCode)
+	health, err := http.Get(base + "/healthz")
+	require.NoError(t, err)
+	health.Body.Close()
+	assert.Equal(t, http.StatusOK, health.StatusCode, "health checks stay open")
+}
+
+// testCert issues a certificate for template signed by parent, or self-signed
+// when parent is nil, and returns it with its key.
+func testCert(t *testing.T, template *x509.Certificate, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+func writePEM(t *testing.T, path, kind string, der []byte) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der}), 0o600))
+}
+
+func TestGeneratedServer_InboundMTLS(t *testing.T) {
+	ca, caKey := testCert(t, &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "test CA"},
+		IsCA: true, BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign}, nil, nil)
+	server, serverKey := testCert(t, &x509.Certificate{SerialNumber: big.NewInt(2), Subject: pkix.Name{CommonName: "server"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")}, ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}}, ca, caKey)
+	client, clientKey := testCert(t, &x509.Certificate{SerialNumber: big.NewInt(3),
+		Subject:     pkix.Name{CommonName: "client", OrganizationalUnit: []string{"users:sync"}},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}, ca, caKey)
+	reader, readerKey := testCert(t, &x509.Certificate{SerialNumber: big.NewInt(4), Subject: pkix.Name{CommonName: "reader"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}, ca, caKey)
+
+	dir := t.TempDir()
+	writePEM(t, filepath.Join(dir, "ca.pem"), "CERTIFICATE", ca.Raw)
+	writePEM(t, filepath.Join(dir, "server.pem"), "CERTIFICATE", server.Raw)
+	serverKeyDER, err := x509.MarshalECPrivateKey(serverKey)
+	require.NoError(t, err)
+	writePEM(t, filepath.Join(dir, "server-key.pem"), "EC PRIVATE KEY", serverKeyDER)
+
+	cg := testGenerator(t)
+	cg.Auth = &InboundAuth{MTLS: true, Scopes: map[string][]string{"sync-user-data": {"users:sync"}}}
+	bin := buildServer(t, cg)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+	cmd := exec.Command(bin, "-addr", addr)
+	cmd.Env = append(os.Environ(),
+		"MCP_UPSTREAM_BASE_URL="+upstream(t).URL,
+		"MCP_TLS_CERT="+filepath.Join(dir, "server.pem"),
+		"MCP_TLS_KEY="+filepath.Join(dir, "server-key.pem"),
+		"MCP_TLS_CLIENT_CA="+filepath.Join(dir, "ca.pem"),
+	)
+	require.NoError(t, cmd.Start())
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	clientFor := func(cert *x509.Certificate, key *ecdsa.PrivateKey) *http.Client {
+		cfg := &tls.Config{RootCAs: roots}
+		if cert != nil {
+			cfg.Certificates = []tls.Certificate{{Certificate: [][]byte{cert.Raw}, PrivateKey: key}}
+		}
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}}
+	}
+	base := "https://" + addr
+	anonymous := clientFor(nil, nil)
+	require.Eventually(t, func() bool {
+		resp, err := anonymous.Get(base + "/healthz")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, 10*time.Second, 50*time.Millisecond)
+
+	header := http.Header{"Content-Type": {"application/json"}}
+	assert.Equal(t, http.StatusUnauthorized, runTaskRequest(t, base, header, anonymous))
+	assert.Equal(t, http.StatusForbidden, runTaskRequest(t, base, header, clientFor(reader, readerKey)))
+	assert.Equal(t, http.StatusOK, runTaskRequest(t, base, header, clientFor(client, clientKey)))
+}
+
+func TestGenerateServerCode_InvalidInboundAuth(t *testing.T) {
+	cg := testGenerator(t)
+	cg.Auth = &InboundAuth{Scopes: map[string][]string{"sync-user-data": {"users:sync"}}}
+	err := cg.GenerateServerCode()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "require API key, JWT or mTLS")
+
+	cg.Auth = &InboundAuth{APIKey: true, Scopes: map[string][]string{"missing": {"users:sync"}}}
+	err = cg.GenerateServerCode()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown workflow "missing"`)
+}
@@ -1,5 +1,14 @@
 package codegenerator
 
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+)
+
 // LLMProvider defines the interface for any large language model provider.
 type LLMProvider interface {
 	GenerateCode(prompt string) (string, error)
@@ -0,0 +1,967 @@
+package codegenerator
+
+import (
+	"MCPGen/core/flow-compiler"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// upstream fakes the user and sync services used by testGenerator.
+func upstream(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/users/"):
+			_ = json.NewEncoder(w).Encode(map[string]string{"id": strings.TrimPrefix(r.URL.Path, "/users/"), "name": "Ada"})
+		case r.Method == http.MethodPost && r.URL.Path == "/sync":
+			body, _ := io.ReadAll(r.Body)
+			_, _ = w.Write([]byte(`{"synced":true,"received":` + string(body) + `}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+type stdioClient struct {
+	t      *testing.T
+	cmd    *exec.Cmd
+	in     io.WriteCloser
+	out    *bufio.Scanner
+	stderr bytes.Buffer
+	id     int
+}
+
+func startStdioServer(t *testing.T, bin string, env ...string) *stdioClient {
+	t.Helper()
+	cmd := exec.Command(bin)
+	cmd.Env = append(os.Environ(), env...)
+	c := &stdioClient{t: t, cmd: cmd}
+	cmd.Stderr = &c.stderr
+	in, err := cmd.StdinPipe()
+	require.NoError(t, err)
+	out, err := cmd.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, cmd.Start())
+	t.Cleanup(func() {
+		_ = in.Close()
+		_ = cmd.Wait()
+	})
+	c.in, c.out = in, bufio.NewScanner(out)
+	return c
+}
+
+// stop ends the server and returns what it logged.
+func (c *stdioClient) stop() string {
+	c.t.Helper()
+	_ = c.in.Close()
+	_ = c.cmd.Wait()
+	return c.stderr.String()
+}
+
+func (c *stdioClient) call(method string, params any) map[string]any {
+	c.t.Helper()
+	c.id++
+	msg, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": c.id, "method": method, "params": params})
+	require.NoError(c.t, err)
+	_, err = c.in.Write(append(msg, '\n'))
+	require.NoError(c.t, err)
+	require.True(c.t, c.out.Scan(), "server closed stdout")
+	var resp map[string]any
+	require.NoError(c.t, json.Unmarshal(c.out.Bytes(), &resp))
+	assert.Equal(c.t, float64(c.id), resp["id"])
+	return resp
+}
+
+func (c *stdioClient) notify(method string) {
+	msg, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "method": method})
+	_, err := c.in.Write(append(msg, '\n'))
+	require.NoError(c.t, err)
+}
+
+func TestGeneratedServer_MCPOverStdio(t *testing.T) {
+	bin := buildServer(t, testGenerator(t))
+	api := upstream(t)
+	client := startStdioServer(t, bin, "MCP_UPSTREAM_BASE_URL="+api.URL)
+
+	init := client.call("initialize", map[string]any{
+		"protocolVersion": "2025-03-26",
+		"capabilities":    map[string]any{},
+		"clientInfo":      map[string]any{"name": "test", "version": "1"},
+	})
+	result := init["result"].(map[string]any)
+	assert.Equal(t, "2025-03-26", result["protocolVersion"])
+	assert.Equal(t, "mcp-server", result["serverInfo"].(map[string]any)["name"])
+	client.notify("notifications/initialized")
+
+	list := client.call("tools/list", map[string]any{})
+	var names []string
+	schemas := map[string]map[string]any{}
+	for _, tool := range list["result"].(map[string]any)["tools"].([]any) {
+		tool := tool.(map[string]any)
+		names = append(names, tool["name"].(string))
+		schemas[tool["name"].(string)] = tool["inputSchema"].(map[string]any)
+	}
+	assert.Equal(t, []string{"sync-user-data", "getUser", "syncData"}, names)
+	assert.Equal(t, []any{"id"}, schemas["getUser"]["required"])
+	assert.Contains(t, schemas["syncData"]["properties"], "body")
+
+	call := client.call("tools/call", map[string]any{"name": "getUser", "arguments": map[string]any{"id": "42"}})
+	res := call["result"].(map[string]any)
+	assert.Nil(t, res["isError"])
+	assert.Contains(t, res["content"].([]any)[0].(map[string]any)["text"], `"name":"Ada"`)
+
+	call = client.call("tools/call", map[string]any{"name": "sync-user-data", "arguments": map[string]any{"id": "7", "body": map[string]any{"x": 1}}})
+	res = call["result"].(map[string]any)
+	assert.Nil(t, res["isError"])
+	steps := res["structuredContent"].(map[string]any)["steps"].(map[string]any)
+	assert.Equal(t, true, steps["syncData"].(map[string]any)["body"].(map[string]any)["synced"])
+
+	missing := client.call("tools/call", map[string]any{"name": "nope"})
+	assert.Equal(t, float64(-32602), missing["error"].(map[string]any)["code"])
+
+	unknown := client.call("resources/list", nil)
+	assert.Equal(t, float64(-32601), unknown["error"].(map[string]any)["code"])
+
+	// Valid JSON of the wrong shape is an invalid request, not a parse error.
+	_, err := client.in.Write([]byte(`{"jsonrpc":"2.0","id":99,"method":5}` + "\n"))
+	require.NoError(t, err)
+	require.True(t, client.out.Scan())
+	assert.Contains(t, client.out.Text(), `"code":-32600`)
+	_, err = client.in.Write([]byte(`{"jsonrpc":` + "\n"))
+	require.NoError(t, err)
+	require.True(t, client.out.Scan())
+	assert.Contains(t, client.out.Text(), `"code":-32700`)
+}
+
+func TestGeneratedServer_Cancellation(t *testing.T) {
+	bin := buildServer(t, testGenerator(t))
+	started := make(chan struct{}, 1)
+	cancelled := make(chan struct{}, 1)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		select {
+		case <-r.Context().Done():
+			cancelled <- struct{}{}
+		case <-time.After(10 * time.Second):
+		}
+	}))
+	t.Cleanup(api.Close)
+	client := startStdioServer(t, bin, "MCP_UPSTREAM_BASE_URL="+api.URL)
+
+	msg, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": "slow", "method": "tools/call",
+		"params": map[string]any{"name": "getUser", "arguments": map[string]any{"id": "1"}}})
+	_, err := client.in.Write(append(msg, '\n'))
+	require.NoError(t, err)
+	<-started
+	msg, _ = json.Marshal(map[string]any{"jsonrpc": "2.0", "method": "notifications/cancelled",
+		"params": map[string]any{"requestId": "slow", "reason": "user"}})
+	_, err = client.in.Write(append(msg, '\n'))
+	require.NoError(t, err)
+
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the downstream call was not cancelled")
+	}
+	// The cancelled request gets no response: the next line answers the ping.
+	ping := client.call("ping", nil)
+	assert.Equal(t, map[string]any{}, ping["result"])
+}
+
+func TestGeneratedServer_ParallelSteps(t *testing.T) {
+	cg := testGenerator(t)
+	flows := []flowcompiler.FlowDefinition{{
+		WorkflowID: "fan-out",
+		Steps: []flowcompiler.FlowStep{
+			{ID: "first", Call: "getUser", DependsOn: []string{}},
+			{ID: "second", Call: "getUser", DependsOn: []string{}},
+			{ID: "after", Call: "getUser"},
+		},
+	}}
+	compiled, err := flowcompiler.NewFlowCompiler(cg.Endpoints, flows).Compile()
+	require.NoError(t, err)
+	cg.Flows = compiled
+	bin := buildServer(t, cg)
+	files := readTree(t, cg.OutputDir)
+	assert.Contains(t, files["flows.go"], `{"first", "second"},`)
+
+	// Both level-0 requests must be in flight at the same time to pass the barrier.
+	var barrier sync.WaitGroup
+	barrier.Add(2)
+	var calls int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= 2 {
+			barrier.Done()
+			done := make(chan struct{})
+			go func() { barrier.Wait(); close(done) }()
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				http.Error(w, "steps did not run concurrently", http.StatusGatewayTimeout)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	t.Cleanup(api.Close)
+
+	client := startStdioServer(t, bin, "MCP_UPSTREAM_BASE_URL="+api.URL)
+	call := client.call("tools/call", map[string]any{"name": "fan-out", "arguments": map[string]any{"id": "1"}})
+	res := call["result"].(map[string]any)
+	assert.Nil(t, res["isError"], res["content"])
+	assert.Len(t, res["structuredContent"].(map[string]any)["steps"], 3)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestGeneratedServer_SuccessCriteria(t *testing.T) {
+	cg := testGenerator(t)
+	flows := []flowcompiler.FlowDefinition{
+		{
+			WorkflowID: "check-status",
+			Steps: []flowcompiler.FlowStep{{
+				ID:   "lookup",
+				Call: "getUser",
+				SuccessCriteria: []flowcompiler.Criterion{
+					{Condition: "$statusCode == 200"},
+					{Condition: "$response.body#/status == 'ok'"},
+				},
+			}},
+		},
+		{
+			WorkflowID: "check-request",
+			Steps: []flowcompiler.FlowStep{{
+				ID:   "lookup",
+				Call: "getUser",
+				SuccessCriteria: []flowcompiler.Criterion{
+					{Condition: "$request.path.id == $inputs.id && $method == 'GET'"},
+					{Context: "$response.body", Condition: "$[?(@.status == 'failed')]", Type: "jsonpath"},
+					{Context: "$response.header.Content-Type", Condition: "^application/json", Type: "regex"},
+				},
+			}},
+		},
+	}
+	compiled, err := flowcompiler.NewFlowCompiler(cg.Endpoints, flows).Compile()
+	require.NoError(t, err)
+	cg.Flows = compiled
+	bin := buildServer(t, cg)
+	files := readTree(t, cg.OutputDir)
+	assert.Contains(t, files["flows.go"], `{Context: "", Condition: "$response.body#/status == 'ok'", Type: "", Version: ""},`)
+	assert.Contains(t, files["criterion.go"], "package main")
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"failed"}`))
+	}))
+	t.Cleanup(api.Close)
+	client := startStdioServer(t, bin, "MCP_UPSTREAM_BASE_URL="+api.URL)
+
+	call := client.call("tools/call", map[string]any{"name": "check-status", "arguments": map[string]any{"id": "7"}})
+	res := call["result"].(map[string]any)
+	assert.Equal(t, true, res["isError"])
+	assert.Contains(t, res["content"].([]any)[0].(map[string]any)["text"], `success criterion \"$response.body#/status == 'ok'\" not met`)
+
+	call = client.call("tools/call", map[string]any{"name": "check-request", "arguments": map[string]any{"id": "7"}})
+	res = call["result"].(map[string]any)
+	assert.Nil(t, res["isError"], res["content"])
+}
+
+func TestGeneratedServer_Actions(t *testing.T) {
+	cg := testGenerator(t)
+	retryAfter, retryLimit, once := 0.01, 3, 1
+	pending := []flowcompiler.Criterion{{Condition: "$response.body#/state == 'pending'"}}
+	unavailable := flowcompiler.Action{
+		Name: "unavailable", Type: "retry", RetryAfter: &retryAfter, RetryLimit: &once,
+		Criteria: []flowcompiler.Criterion{{Condition: "$statusCode == 503"}},
+	}
+	user := func(id string) []flowcompiler.StepParameter {
+		return []flowcompiler.StepParameter{{Name: "id", In: "path", Value: id}}
+	}
+	flows := []flowcompiler.FlowDefinition{
+		{
+			WorkflowID: "retrying",
+			Steps: []flowcompiler.FlowStep{{
+				ID: "lookup", Call: "getUser",
+				OnFailure: []flowcompiler.Action{{Reference: "$components.failureActions.unavailable"}},
+			}},
+			Components: &flowcompiler.Components{FailureActions: map[string]flowcompiler.Action{
+				"unavailable": {
+					Name: "unavailable", Type: "retry", RetryAfter: &retryAfter, RetryLimit: &retryLimit,
+					Criteria: []flowcompiler.Criterion{{Condition: "$statusCode == 503"}},
+				},
+			}},
+		},
+		{
+			WorkflowID: "polling",
+			Steps: []flowcompiler.FlowStep{
+				{ID: "poll", Call: "getUser", OnSuccess: []flowcompiler.Action{{Name: "again", Type: "goto", StepID: "poll", Criteria: pending}}},
+				{ID: "done", Call: "getUser"},
+			},
+		},
+		{
+			WorkflowID: "ending",
+			Steps: []flowcompiler.FlowStep{
+				{ID: "first", Call: "getUser"},
+				{ID: "second", Call: "getUser"},
+			},
+			SuccessActions: []flowcompiler.Action{{Name: "stop", Type: "end"}},
+		},
+		{
+			// loop and sibling share a level; going back to loop must not
+			// call sibling again.
+			WorkflowID: "fanning",
+			Steps: []flowcompiler.FlowStep{
+				{
+					ID: "loop", Call: "getUser", DependsOn: []string{},
+					Parameters: []flowcompiler.StepParameter{{Name: "id", In: "path", Value: "loop"}},
+					OnSuccess:  []flowcompiler.Action{{Name: "again", Type: "goto", StepID: "loop", Criteria: pending}},
+				},
+				{
+					ID: "sibling", Call: "getUser", DependsOn: []string{},
+					Parameters: []flowcompiler.StepParameter{{Name: "id", In: "path", Value: "sibling"}},
+				},
+				{
+					ID: "after", Call: "getUser", DependsOn: []string{"loop"},
+					Parameters: []flowcompiler.StepParameter{{Name: "id", In: "path", Value: "after"}},
+				},
+			},
+		},
+		{
+			// A goto forward skips the steps in between.
+			WorkflowID: "skipping",
+			Steps: []flowcompiler.FlowStep{
+				{ID: "a", Call: "getUser", Parameters: user("a"), OnSuccess: []flowcompiler.Action{{Name: "skip", Type: "goto", StepID: "c"}}},
+				{ID: "b", Call: "getUser", Parameters: user("skipped")},
+				{ID: "c", Call: "getUser", Parameters: user("c")},
+			},
+		},
+		{
+			// A goto taken by one step of a level does not hide the failure
+			// of its sibling.
+			WorkflowID: "failing-sibling",
+			Steps: []flowcompiler.FlowStep{
+				{ID: "jumper", Call: "getUser", DependsOn: []string{}, Parameters: user("jumper"), OnSuccess: []flowcompiler.Action{{Name: "on", Type: "goto", StepID: "last"}}},
+				{ID: "broken", Call: "getUser", DependsOn: []string{}, Parameters: user("down")},
+				{ID: "last", Call: "getUser", DependsOn: []string{"jumper"}, Parameters: user("last")},
+			},
+		},
+		{
+			// Each pass of a loop gets its own retries.
+			WorkflowID: "flipping",
+			Steps: []flowcompiler.FlowStep{{
+				ID: "flip", Call: "getUser", Parameters: user("flip"),
+				OnSuccess: []flowcompiler.Action{{Name: "again", Type: "goto", StepID: "flip", Criteria: pending}},
+				OnFailure: []flowcompiler.Action{unavailable},
+			}},
+		},
+	}
+	compiled, err := flowcompiler.NewFlowCompiler(cg.Endpoints, flows).Compile()
+	require.NoError(t, err)
+	cg.Flows = compiled
+	bin := buildServer(t, cg)
+	files := readTree(t, cg.OutputDir)
+	assert.Contains(t, files["flows.go"], `{Name: "unavailable", Type: "retry", RetryAfter: 0.01, RetryLimit: 3, Criteria: []Criterion{`)
+
+	var mu sync.Mutex
+	calls := map[string]int{}
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/users/")
+		mu.Lock()
+		calls[id]++
+		n := calls[id]
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case id == "flaky" && n <= 2:
+			http.Error(w, `{"error":"busy"}`, http.StatusServiceUnavailable)
+		case id == "down":
+			http.Error(w, `{"error":"down"}`, http.StatusServiceUnavailable)
+		case id == "flip" && n%2 == 1:
+			http.Error(w, `{"error":"busy"}`, http.StatusServiceUnavailable)
+		case (id == "job" || id == "loop" || id == "flip") && n <= 2:
+			_, _ = w.Write([]byte(`{"state":"pending"}`))
+		default:
+			_, _ = w.Write([]byte(`{"state":"done"}`))
+		}
+	}))
+	t.Cleanup(api.Close)
+	client := startStdioServer(t, bin, "MCP_UPSTREAM_BASE_URL="+api.URL)
+	run := func(flow, id string) map[string]any {
+		call := client.call("tools/call", map[string]any{"name": flow, "arguments": map[string]any{"id": id}})
+		return call["result"].(map[string]any)
+	}
+
+	res := run("retrying", "flaky")
+	assert.Nil(t, res["isError"], res["content"])
+	res = run("retrying", "down")
+	assert.Equal(t, true, res["isError"])
+	res = run("polling", "job")
+	assert.Nil(t, res["isError"], res["content"])
+	assert.Len(t, res["structuredContent"].(map[string]any)["steps"], 2)
+	res = run("ending", "user")
+	assert.Nil(t, res["isError"], res["content"])
+	assert.Len(t, res["structuredContent"].(map[string]any)["steps"], 1)
+	res = run("fanning", "unused")
+	assert.Nil(t, res["isError"], res["content"])
+	assert.Len(t, res["structuredContent"].(map[string]any)["steps"], 3)
+	res = run("skipping", "unused")
+	assert.Nil(t, res["isError"], res["content"])
+	assert.Len(t, res["structuredContent"].(map[string]any)["steps"], 2)
+	res = run("failing-sibling", "unused")
+	assert.Equal(t, true, res["isError"])
+	assert.Contains(t, res["content"].([]any)[0].(map[string]any)["text"], "step broken")
+	res = run("flipping", "unused")
+	assert.Nil(t, res["isError"], res["content"])
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 3, calls["flaky"])
+	assert.Equal(t, 5, calls["down"])
+	assert.Equal(t, 4, calls["job"])
+	assert.Equal(t, 1, calls["user"])
+	assert.Equal(t, 3, calls["loop"])
+	assert.Equal(t, 1, calls["sibling"])
+	assert.Equal(t, 1, calls["after"])
+	assert.Equal(t, 1, calls["a"])
+	assert.Equal(t, 0, calls["skipped"])
+	assert.Equal(t, 1, calls["c"])
+	assert.Equal(t, 0, calls["last"])
+	assert.Equal(t, 4, calls["flip"])
+}
+
+func TestGeneratedServer_ThreadsStepValues(t *testing.T) {
+	cg := testGenerator(t)
+	flows := []flowcompiler.FlowDefinition{{
+		WorkflowID: "greet-user",
+		Inputs:     map[string]interface{}{"type": "object", "properties": map[string]interface{}{"userId": map[string]interface{}{"type": "string"}}},
+		Steps: []flowcompiler.FlowStep{
+			{
+				ID: "user", Call: "getUser",
+				Parameters: []flowcompiler.StepParameter{{Name: "id", In: "path", Value: "$inputs.userId"}},
+				Outputs:    map[string]string{"id": "$response.body#/id", "name": "$response.body#/name"},
+			},
+			{
+				ID: "sync", Call: "syncData",
+				RequestBody: &flowcompiler.StepRequestBody{
+					Payload:      map[string]interface{}{"user": "$steps.user.outputs.id", "greeting": "Hello {$steps.user.outputs.name}!"},
+					Replacements: []flowcompiler.PayloadReplacement{{Target: "/source/userId", Value: "$inputs.userId"}},
+				},
+				Outputs: map[string]string{"received": "$response.body#/received"},
+			},
+		},
+		Outputs: map[string]string{"sent": "$steps.sync.outputs.received"},
+	}}
+	compiled, err := flowcompiler.NewFlowCompiler(cg.Endpoints, flows).Compile()
+	require.NoError(t, err)
+	cg.Flows = compiled
+	bin := buildServer(t, cg)
+	files := readTree(t, cg.OutputDir)
+	assert.Contains(t, files["flows.go"], "{Name: \"id\", In: \"path\", Value: jsonValue(`\"$inputs.userId\"`)},")
+
+	api := upstream(t)
+	client := startStdioServer(t, bin, "MCP_UPSTREAM_BASE_URL="+api.URL)
+	call := client.call("tools/call", map[string]any{"name": "greet-user", "arguments": map[string]any{"userId": "42"}})
+	res := call["result"].(map[string]any)
+	require.Nil(t, res["isError"], res["content"])
+	content := res["structuredContent"].(map[string]any)
+	assert.Equal(t, map[string]any{"id": "42", "name": "Ada"}, content["steps"].(map[string]any)["user"].(map[string]any)["outputs"])
+	assert.Equal(t, map[string]any{
+		"sent": map[string]any{"user": "42", "greeting": "Hello Ada!", "source": map[string]any{"userId": "42"}},
+	}, content["outputs"])
+}
+
+func TestGeneratedServer_ValidatesWorkflowInputs(t *testing.T) {
+	cg := testGenerator(t)
+	flows := []flowcompiler.FlowDefinition{{
+		WorkflowID: "lookup-user",
+		Inputs: map[string]interface{}{
+			"type":                 "object",
+			"required":             []interface{}{"userId"},
+			"additionalProperties": false,
+			"properties": map[string]interface{}{
+				"userId": map[string]interface{}{"type": "string", "pattern": "^[0-9]+$"},
+				"email":  map[string]interface{}{"type": "string", "format": "email"},
+				"tags":   map[string]interface{}{"type": "array", "maxItems": 2, "items": map[string]interface{}{"type": "string"}},
+			},
+		},
+		Steps: []flowcompiler.FlowStep{{
+			ID: "user", Call: "getUser",
+			Parameters: []flowcompiler.StepParameter{{Name: "id", In: "path", Value: "$inputs.userId"}},
+		}},
+	}}
+	compiled, err := flowcompiler.NewFlowCompiler(cg.Endpoints, flows).Compile()
+	require.NoError(t, err)
+	cg.Flows = compiled
+	bin := buildServer(t, cg)
+
+	var calls int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"name":"Ada"}`))
+	}))
+	t.Cleanup(api.Close)
+	wantErrors := []any{
+		map[string]any{"pointer": "/email", "message": "must be a valid email"},
+		map[string]any{"pointer": "/extra", "message": "is not an allowed property"},
+		map[string]any{"pointer": "/tags", "message": "must have at most 2 items"},
+		map[string]any{"pointer": "/tags/0", "message": "expected string, got number"},
+		map[string]any{"pointer": "/userId", "message": "must match pattern ^[0-9]+$"},
+	}
+	invalid := map[string]any{"userId": "abc", "email": "not-an-email", "tags": []any{1, "a", "b"}, "extra": true}
+
+	client := startStdioServer(t, bin, "MCP_UPSTREAM_BASE_URL="+api.URL)
+	list := client.call("tools/list", map[string]any{})
+	schema := list["result"].(map[string]any)["tools"].([]any)[0].(map[string]any)["inputSchema"].(map[string]any)
+	assert.Equal(t, []any{"userId"}, schema["required"])
+
+	call := client.call("tools/call", map[string]any{"name": "lookup-user", "arguments": invalid})
+	res := call["result"].(map[string]any)
+	assert.Equal(t, true, res["isError"])
+	assert.Equal(t, wantErrors, res["structuredContent"].(map[string]any)["errors"])
+
+	call = client.call("tools/call", map[string]any{"name": "lookup-user", "arguments": map[string]any{}})
+	res = call["result"].(map[string]any)
+	assert.Equal(t, []any{map[string]any{"pointer": "/userId", "message": "is required"}}, res["structuredContent"].(map[string]any)["errors"])
+
+	base := strings.TrimSuffix(startHTTPServer(t, bin, "MCP_UPSTREAM_BASE_URL="+api.URL), "/mcp")
+	body, err := json.Marshal(invalid)
+	require.NoError(t, err)
+	resp, err := http.Post(base+"/run-task/lookup-user", "application/json", strings.NewReader(string(body)))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	var out map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	assert.Equal(t, wantErrors, out["errors"])
+	assert.Equal(t, int32(0), atomic.LoadInt32(&calls), "no downstream call may be made for invalid inputs")
+
+	call = client.call("tools/call", map[string]any{"name": "lookup-user", "arguments": map[string]any{"userId": "42", "tags": []any{"a"}}})
+	res = call["result"].(map[string]any)
+	assert.Nil(t, res["isError"], res["content"])
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestGeneratedServer_ValidatesEndpointSchemas(t *testing.T) {
+	defs := map[string]interface{}{
+		"User": map[string]interface{}{
+			"type":     "object",
+			"required": []interface{}{"id", "name"},
+			"properties": map[string]interface{}{
+				"id":   map[string]interface{}{"type": "integer"},
+				"name": map[string]interface{}{"type": "string"},
+			},
+		},
+		"NewUser": map[string]interface{}{
+			"allOf": []interface{}{
+				map[string]interface{}{"$ref": "#/$defs/User"},
+				map[string]interface{}{
+					"properties": map[string]interface{}{
+						"email": map[string]interface{}{"type": "string", "format": "email"},
+						"role":  map[string]interface{}{"oneOf": []interface{}{map[string]interface{}{"const": "admin"}, map[string]interface{}{"const": "member"}}},
+					},
+				},
+			},
+		},
+	}
+	cg := testGenerator(t)
+	cg.Validation = ValidationEnforce
+	cg.Endpoints = []flowcompiler.Endpoint{
+		{
+			ID: "getUser", Method: "GET", Path: "/users/{id}",
+			Parameters:  []flowcompiler.Parameter{{Name: "id", In: "path", Required: true, Schema: map[string]interface{}{"type": "integer", "minimum": 1}}},
+			Responses:   map[string]flowcompiler.Response{"200": {Code: "200", Schema: map[string]interface{}{"$ref": "#/$defs/User"}}},
+			Definitions: defs,
+		},
+		{
+			ID: "createUser", Method: "POST", Path: "/users",
+			RequestBody: &flowcompiler.RequestBody{ContentType: "application/json", Schema: map[string]interface{}{"$ref": "#/$defs/NewUser"}},
+			Responses:   map[string]flowcompiler.Response{"2XX": {Code: "2XX", Schema: map[string]interface{}{"$ref": "#/$defs/User"}}},
+			Definitions: defs,
+		},
+	}
+	flows := []flowcompiler.FlowDefinition{{
+		WorkflowID: "fetch-user",
+		Steps: []flowcompiler.FlowStep{{
+			ID: "user", Call: "getUser",
+			Parameters: []flowcompiler.StepParameter{{Name: "id", In: "path", Value: "$inputs.id"}},
+		}},
+	}}
+	compiled, err := flowcompiler.NewFlowCompiler(cg.Endpoints, flows).Compile()
+	require.NoError(t, err)
+	cg.Flows = compiled
+	bin := buildServer(t, cg)
+
+	var calls int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/users/1":
+			_, _ = w.Write([]byte(`{"id":1,"name":"Ada"}`))
+		case "/users/2":
+			_, _ = w.Write([]byte(`{"id":"two"}`))
+		default:
+			w.WriteHeader(http.StatusCreated)
+			_, _ = io.Copy(w, r.Body)
+		}
+	}))
+	t.Cleanup(api.Close)
+	errorsOf := func(call map[string]any) any {
+		res := call["result"].(map[string]any)
+		require.Equal(t, true, res["isError"], res["content"])
+		return res["structuredContent"].(map[string]any)["errors"]
+	}
+
+	client := startStdioServer(t, bin, "MCP_UPSTREAM_BASE_URL="+api.URL)
+	call := client.call("tools/call", map[string]any{"name": "getUser", "arguments": map[string]any{"id": "0"}})
+	assert.Equal(t, []any{map[string]any{"pointer": "/id", "message": "must be at least 1"}}, errorsOf(call))
+	call = client.call("tools/call", map[string]any{"name": "createUser", "arguments": map[string]any{
+		"body": map[string]any{"id": 3, "email": "not-an-email", "role": "owner"},
+	}})
+	assert.Equal(t, []any{
+		map[string]any{"pointer": "/body/name", "message": "is required"},
+		map[string]any{"pointer": "/body/email", "message": "must be a valid email"},
+		map[string]any{"pointer": "/body/role", "message": "must match exactly one schema in oneOf, matched 0"},
+	}, errorsOf(call))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&calls), "invalid requests must not be sent")
+
+	call = client.call("tools/call", map[string]any{"name": "getUser", "arguments": map[string]any{"id": "1"}})
+	assert.Nil(t, call["result"].(map[string]any)["isError"])
+	call = client.call("tools/call", map[string]any{"name": "createUser", "arguments": map[string]any{
+		"body": map[string]any{"id": 3, "name": "Grace", "email": "grace@example.com", "role": "admin"},
+	}})
+	assert.Nil(t, call["result"].(map[string]any)["isError"])
+	call = client.call("tools/call", map[string]any{"name": "getUser", "arguments": map[string]any{"id": 2}})
+	assert.Equal(t, []any{
+		map[string]any{"pointer": "/name", "message": "is required"},
+		map[string]any{"pointer": "/id", "message": "expected integer, got string"},
+	}, errorsOf(call))
+
+	// A mismatch in a workflow step is reported with the response that caused it.
+	call = client.call("tools/call", map[string]any{"name": "fetch-user", "arguments": map[string]any{"id": 2}})
+	assert.Equal(t, []any{
+		map[string]any{"pointer": "/name", "message": "is required"},
+		map[string]any{"pointer": "/id", "message": "expected integer, got string"},
+	}, errorsOf(call))
+	result := call["result"].(map[string]any)["structuredContent"].(map[string]any)["result"].(map[string]any)
+	assert.Equal(t, map[string]any{"id": "two"}, result["steps"].(map[string]any)["user"].(map[string]any)["body"])
+
+	warn := startStdioServer(t, bin, "MCP_UPSTREAM_BASE_URL="+api.URL, "MCP_VALIDATION=warn")
+	call = warn.call("tools/call", map[string]any{"name": "getUser", "arguments": map[string]any{"id": 2}})
+	assert.Nil(t, call["result"].(map[string]any)["isError"], "warn mode only logs mismatches")
+}
+
+func TestGeneratedServer_InjectsCredentials(t *testing.T) {
+	var tokenRequests int32
+	var rejectNext int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/token":
+			id, secret, _ := r.BasicAuth()
+			_ = r.ParseForm()
+			if id != "client" || secret != "s3cret" || r.Form.Get("grant_type") != "client_credentials" || r.Form.Get("scope") != "reports:read" {
+				w.WriteHeader(http.StatusUnauthorized)
+				_, _ = w.Write([]byte(`{"error":"invalid_client"}`))
+				return
+			}
+			n := atomic.AddInt32(&tokenRequests, 1)
+			_ = json.NewEncoder(w).Encode(map[string]any{"access_token": "token-" + strconv.Itoa(int(n)), "expires_in": 3600})
+		case "/reports":
+			if atomic.CompareAndSwapInt32(&rejectNext, 1, 0) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]string{"auth": r.Header.Get("Authorization")})
+		case "/keys":
+			_ = json.NewEncoder(w).Encode(map[string]string{"key": r.URL.Query().Get("key")})
+		case "/profile":
+			user, pass, _ := r.BasicAuth()
+			_ = json.NewEncoder(w).Encode(map[string]string{"user": user, "pass": pass})
+		case "/session":
+			cookie, _ := r.Cookie("SESSION")
+			value := ""
+			if cookie != nil {
+				value = cookie.Value
+			}
+			_ = json.NewEncoder(w).Encode(map[string]string{"cookie": value, "auth": r.Header.Get("Authorization")})
+		}
+	}))
+	t.Cleanup(api.Close)
+
+	cg := testGenerator(t)
+	cg.Flows = nil
+	cg.Endpoints = []flowcompiler.Endpoint{
+		{ID: "getReports", Service: "Vault", Method: "GET", Path: "/reports", Security: [][]flowcompiler.SecurityScheme{
+			{{Name: "oauth", Type: "oauth2", TokenURL: api.URL + "/token", Scopes: []string{"reports:read"}}},
+		}},
+		{ID: "listKeys", Service: "Vault", Method: "GET", Path: "/keys", Security: [][]flowcompiler.SecurityScheme{
+			{{Name: "query_key", Type: "apiKey", In: "query", ParamName: "key"}},
+		}},
+		{ID: "getProfile", Method: "GET", Path: "/profile", Security: [][]flowcompiler.SecurityScheme{
+			{{Name: "basic", Type: "http", Scheme: "basic"}},
+		}},
+		{ID: "getSession", Method: "GET", Path: "/session", Security: [][]flowcompiler.SecurityScheme{
+			{{Name: "session", Type: "apiKey", In: "cookie", ParamName: "SESSION"}},
+			{{Name: "jwt", Type: "http", Scheme: "bearer"}},
+		}},
+	}
+	bin := buildServer(t, cg)
+
+	secretsFile := t.TempDir() + "/secrets.json"
+	require.NoError(t, os.WriteFile(secretsFile, []byte(`{
+		"basic": {"username": "ada", "password": "file-pw"},
+		"Vault.oauth": {"client_id": "client", "client_secret": "wrong"},
+		"jwt": {"token": "jwt-token"}
+	}`), 0o600))
+	client := startStdioServer(t, bin,
+		"MCP_UPSTREAM_BASE_URL="+api.URL,
+		"MCP_SECRETS_FILE="+secretsFile,
+		"MCP_AUTH_VAULT_QUERY_KEY_API_KEY=k1",
+		"MCP_AUTH_VAULT_OAUTH_CLIENT_SECRET=s3cret",
+	)
+	body := func(name string) map[string]any {
+		call := client.call("tools/call", map[string]any{"name": name, "arguments": map[string]any{}})
+		res := call["result"].(map[string]any)
+		require.Nil(t, res["isError"], res["content"])
+		return res["structuredContent"].(map[string]any)["body"].(map[string]any)
+	}
+
+	assert.Equal(t, "k1", body("listKeys")["key"])
+	assert.Equal(t, map[string]any{"user": "ada", "pass": "file-pw"}, body("getProfile"))
+	assert.Equal(t, map[string]any{"cookie": "", "auth": "Bearer jwt-token"}, body("getSession"), "the first configured alternative is used")
+
+	assert.Equal(t, "Bearer token-1", body("getReports")["auth"])
+	assert.Equal(t, "Bearer token-1", body("getReports")["auth"], "tokens are cached")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&tokenRequests))
+	atomic.StoreInt32(&rejectNext, 1)
+	client.call("tools/call", map[string]any{"name": "getReports", "arguments": map[string]any{}})
+	assert.Equal(t, "Bearer token-2", body("getReports")["auth"], "a 401 drops the cached token")
+
+	bare := startStdioServer(t, bin, "MCP_UPSTREAM_BASE_URL="+api.URL)
+	for i := 0; i < 3; i++ {
+		bare.call("tools/call", map[string]any{"name": "getProfile", "arguments": map[string]any{}})
+	}
+	assert.Equal(t, 1, strings.Count(bare.stop(), "warning: no credentials configured for getProfile"), "the warning is logged once per endpoint")
+}
+
+func TestGeneratedServer_Hooks(t *testing.T) {
+	cg := testGenerator(t)
+	retryLimit := 3
+	flows := []flowcompiler.FlowDefinition{
+		{WorkflowID: "hooked", Steps: []flowcompiler.FlowStep{{ID: "lookup", Call: "getUser", PreHook: "rewrite", PostHook: "annotate"}}},
+		{WorkflowID: "cached", Steps: []flowcompiler.FlowStep{{ID: "lookup", Call: "getUser", PreHook: "cache", PostHook: "annotate"}}},
+		{WorkflowID: "denied", Steps: []flowcompiler.FlowStep{{
+			ID: "lookup", Call: "getUser", PreHook: "deny",
+			OnFailure: []flowcompiler.Action{{Name: "again", Type: "retry", RetryLimit: &retryLimit}},
+		}}},
+		{WorkflowID: "panicking", Steps: []flowcompiler.FlowStep{{ID: "lookup", Call: "getUser", PostHook: "boom"}}},
+		// A nested workflow step answered with an error status by its pre-hook.
+		{WorkflowID: "unavailable", Steps: []flowcompiler.FlowStep{{ID: "nested", WorkflowID: "hooked", PreHook: "unavailable"}}},
+	}
+	compiled, err := flowcompiler.NewFlowCompiler(cg.Endpoints, flows).Compile()
+	require.NoError(t, err)
+	cg.Flows = compiled
+	require.NoError(t, os.MkdirAll(cg.OutputDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(cg.OutputDir, "test_hooks.go"), []byte(`package main
+
+import (
+	"context"
+	"fmt"
+)
+
+var denials int
+
+func init() {
+	must := func(err error) {
+		if err != nil {
+			panic(err)
+		}
+	}
+	// The hooks of a name run by priority, so the id ends up as 99.
+	must(plugins.RegisterPreHook("rewrite", 10, func(ctx context.Context, sc *StepContext) error {
+		sc.Inputs["id"] = "99"
+		return nil
+	}))
+	must(plugins.RegisterPreHook("rewrite", 0, func(ctx context.Context, sc *StepContext) error {
+		sc.Inputs["id"] = "1"
+		return nil
+	}))
+	must(plugins.RegisterPreHook("cache", 0, func(ctx context.Context, sc *StepContext) error {
+		sc.Response = &StepResponse{StatusCode: 200, Body: map[string]any{"id": "cached"}}
+		return nil
+	}))
+	must(plugins.RegisterPreHook("deny", 0, func(ctx context.Context, sc *StepContext) error {
+		denials++
+		return fmt.Errorf("denied %d times: %w", denials, ErrAbort)
+	}))
+	must(plugins.RegisterPostHook("annotate", 0, func(ctx context.Context, sc *StepContext, resp *StepResponse) error {
+		resp.Body.(map[string]any)["hookedBy"] = sc.FlowID
+		return nil
+	}))
+	must(plugins.RegisterPreHook("unavailable", 0, func(ctx context.Context, sc *StepContext) error {
+		sc.Response = &StepResponse{StatusCode: 503, Body: map[string]any{}}
+		return nil
+	}))
+	must(plugins.RegisterPostHook("boom", 0, func(ctx context.Context, sc *StepContext, resp *StepResponse) error {
+		panic("kaboom")
+	}))
+}
+`), 0o644))
+	bin := buildServer(t, cg)
+
+	var calls atomic.Int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{"id": strings.TrimPrefix(r.URL.Path, "/users/")})
+	}))
+	t.Cleanup(api.Close)
+	client := startStdioServer(t, bin, "MCP_UPSTREAM_BASE_URL="+api.URL)
+	run := func(flow string) map[string]any {
+		call := client.call("tools/call", map[string]any{"name": flow, "arguments": map[string]any{"id": "7"}})
+		return call["result"].(map[string]any)
+	}
+	body := func(res map[string]any) map[string]any {
+		return res["structuredContent"].(map[string]any)["steps"].(map[string]any)["lookup"].(map[string]any)["body"].(map[string]any)
+	}
+
+	res := run("hooked")
+	require.Nil(t, res["isError"], res["content"])
+	assert.Equal(t, map[string]any{"id": "99", "hookedBy": "hooked"}, body(res))
+	assert.Equal(t, int32(1), calls.Load())
+
+	res = run("cached")
+	require.Nil(t, res["isError"], res["content"])
+	assert.Equal(t, map[string]any{"id": "cached", "hookedBy": "cached"}, body(res))
+	assert.Equal(t, int32(1), calls.Load())
+
+	// ErrAbort skips the retry action.
+	res = run("denied")
+	assert.Equal(t, true, res["isError"])
+	assert.Contains(t, res["content"].([]any)[0].(map[string]any)["text"], "pre-hook deny: denied 1 times: workflow aborted")
+
+	res = run("panicking")
+	assert.Equal(t, true, res["isError"])
+	assert.Contains(t, res["content"].([]any)[0].(map[string]any)["text"], "post-hook boom: panic: kaboom")
+
+	res = run("unavailable")
+	assert.Equal(t, true, res["isError"])
+	assert.Contains(t, res["content"].([]any)[0].(map[string]any)["text"], "workflow hooked returned 503")
+}
+
+func TestGeneratedServer_HookFiles(t *testing.T) {
+	cg := testGenerator(t)
+	retryLimit := 3
+	flows := []flowcompiler.FlowDefinition{{
+		WorkflowID: "lookup-user",
+		SourceFile: "testdata/lookup.arazzo.yaml",
+		Steps: []flowcompiler.FlowStep{{
+			ID: "lookup", Call: "getUser", PreHook: "hooks/validate_user.go", PostHook: "hooks/log_result.go",
+			OnFailure: []flowcompiler.Action{{Name: "again", Type: "retry", RetryLimit: &retryLimit}},
+		}},
+	}}
+	compiled, err := flowcompiler.NewFlowCompiler(cg.Endpoints, flows).Compile()
+	require.NoError(t, err)
+	cg.Flows = compiled
+	bin := buildServer(t, cg)
+
+	files := readTree(t, cg.OutputDir)
+	validate, err := os.ReadFile("testdata/hooks/validate_user.go")
+	require.NoError(t, err)
+	assert.Equal(t, string(validate), files[filepath.Join("hooks", "validate_user.go")])
+
+	var calls atomic.Int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{"id": strings.TrimPrefix(r.URL.Path, "/users/")})
+	}))
+	t.Cleanup(api.Close)
+	client := startStdioServer(t, bin, "MCP_UPSTREAM_BASE_URL="+api.URL)
+	run := func(id string) map[string]any {
+		call := client.call("tools/call", map[string]any{"name": "lookup-user", "arguments": map[string]any{"id": id}})
+		return call["result"].(map[string]any)
+	}
+
+	res := run("7")
+	require.Nil(t, res["isError"], res["content"])
+	lookup := res["structuredContent"].(map[string]any)["steps"].(map[string]any)["lookup"].(map[string]any)
+	assert.Equal(t, map[string]any{"id": "7", "logged": true}, lookup["body"])
+
+	// hooks.ErrAbort aborts the workflow like ErrAbort, skipping the retry.
+	res = run("0")
+	assert.Equal(t, true, res["isError"])
+	assert.Contains(t, res["content"].([]any)[0].(map[string]any)["text"], `pre-hook hooks/validate_user.go: invalid user id \"0\": workflow aborted`)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestGeneratedServer_WASMHooks(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping build of the wasm guest in short mode")
+	}
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain not available")
+	}
+	dir := t.TempDir()
+	guest := exec.Command(goBin, "build", "-buildmode=c-shared", "-o", filepath.Join(dir, "hooks", "guest.wasm"), ".")
+	guest.Dir = "../plugin-manager/testdata/guest"
+	guest.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+	out, err := guest.CombinedOutput()
+	require.NoError(t, err, string(out))
+
+	cg := testGenerator(t)
+	flows := []flowcompiler.FlowDefinition{{
+		WorkflowID: "lookup-user",
+		SourceFile: filepath.Join(dir, "lookup.arazzo.yaml"),
+		Steps:      []flowcompiler.FlowStep{{ID: "lookup", Call: "getUser", PreHook: "hooks/guest.wasm", PostHook: "hooks/guest.wasm"}},
+	}}
+	compiled, err := flowcompiler.NewFlowCompiler(cg.Endpoints, flows).Compile()
+	require.NoError(t, err)
+	cg.Flows = compiled
+	bin := buildServer(t, cg)
+
+	files := readTree(t, cg.OutputDir)
+	assert.Contains(t, files["go.mod"], "require github.com/tetratelabs/wazero")
+	assert.Contains(t, files, "wasm_hook.go")
+	assert.Contains(t, files, filepath.Join("hooks", "guest.wasm"))
+	assert.NotContains(t, files, filepath.Join("hooks", "step_context.go"))
+	assert.Equal(t, 1, strings.Count(files["hook_files.go"], "RegisterWASMHook("))
+
+	api := upstream(t)
+	client := startStdioServer(t, bin, "MCP_UPSTREAM_BASE_URL="+api.URL, "MCP_HOOKS_DIR="+filepath.Join(cg.OutputDir, "hooks"))
+	run := func(id string) map[string]any {
+		call := client.call("tools/call", map[string]any{"name": "lookup-user", "arguments": map[string]any{"id": id}})
+		return call["result"].(map[string]any)
+	}
+
+	res := run("ada")
+	require.Nil(t, res["isError"], res["content"])
+	lookup := res["structuredContent"].(map[string]any)["steps"].(map[string]any)["lookup"].(map[string]any)
+	assert.Equal(t, map[string]any{"id": "ADA", "name": "Ada", "hookedBy": "lookup", "version": "v1"}, lookup["body"])
+
+	res = run("0")
+	assert.Equal(t, true, res["isError"])
+	assert.Contains(t, res["content"].([]any)[0].(map[string]any)["text"], "pre-hook hooks/guest.wasm: invalid user id: workflow aborted")
+}
@@ -0,0 +1,357 @@
+package codegenerator
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// middlewarePackage is the directory, and package name, of the middleware
+// files in the generated module.
+const middlewarePackage = "middleware"
+
+// toolCallFile declares ToolCall and ToolHandler in the middleware package;
+// no middleware file may take its name.
+const toolCallFile = "tool_call.go"
+
+// Built-in middleware, named by the use key of a MiddlewareSpec.
+const (
+	MiddlewareRequestID = "request-id"
+	MiddlewareLogging   = "logging"
+	MiddlewareRecovery  = "recovery"
+	MiddlewareCORS      = "cors"
+	MiddlewareBodyLimit = "body-limit"
+)
+
+// MiddlewareConfig lists the middleware of the generated server, outermost
+// first. It is read from YAML or JSON:
+//
+//	http:
+//	  - use: request-id
+//	  - use: recovery
+//	  - use: cors
+//	    allowedOrigins: [https://app.example.com]
+//	  - use: body-limit
+//	    maxBytes: 1048576
+//	  - file: middleware/tenant.go
+//	tools:
+//	  - use: logging
+//	  - file: middleware/audit.go
+//
+// HTTP middleware wraps every HTTP endpoint of the server; tool middleware
+// wraps every MCP tools/call request, whatever the transport.
+type MiddlewareConfig struct {
+	HTTP  []MiddlewareSpec `yaml:"http"`
+	Tools []MiddlewareSpec `yaml:"tools"`
+}
+
+// MiddlewareSpec is a built-in middleware, or a Go file exporting one. An
+// HTTP middleware file exports
+//
+//	func Name(next http.Handler) http.Handler
+//
+// and a tool middleware file
+//
+//	func Name(next ToolHandler) ToolHandler
+//
+// in package middleware, where Name is the file name in camel case.
+type MiddlewareSpec struct {
+	// Use names a built-in: request-id, logging, recovery, cors or
+	// body-limit over HTTP, logging or recovery around tool calls.
+	Use string `yaml:"use"`
+	// File is the path of a middleware file, relative to the config file.
+	File string `yaml:"file"`
+
+	// The options of cors.
+	AllowedOrigins   []string `yaml:"allowedOrigins"`
+	AllowedMethods   []string `yaml:"allowedMethods"`
+	AllowedHeaders   []string `yaml:"allowedHeaders"`
+	ExposedHeaders   []string `yaml:"exposedHeaders"`
+	AllowCredentials bool     `yaml:"allowCredentials"`
+	// MaxAge is how many seconds browsers may cache a preflight response.
+	MaxAge int `yaml:"maxAge"`
+
+	// MaxBytes is the largest request body body-limit lets through.
+	MaxBytes int64 `yaml:"maxBytes"`
+}
+
+// LoadMiddlewareConfig reads the middleware config file at path. The files it
+// names are resolved relative to it.
+func LoadMiddlewareConfig(path string) (*MiddlewareConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read file: %w", err)
+	}
+	var c MiddlewareConfig
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&c); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse middleware config %s: %w", path, err)
+	}
+	for _, specs := range [][]MiddlewareSpec{c.HTTP, c.Tools} {
+		for i := range specs {
+			if specs[i].File != "" && !filepath.IsAbs(specs[i].File) {
+				specs[i].File = filepath.Join(filepath.Dir(path), specs[i].File)
+			}
+		}
+	}
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &c, nil
+}
+
+func (c *MiddlewareConfig) validate() error {
+	for _, kind := range []struct {
+		name  string
+		specs []MiddlewareSpec
+		tool  bool
+	}{{"http", c.HTTP, false}, {"tools", c.Tools, true}} {
+		seen := map[string]bool{}
+		for i, spec := range kind.specs {
+			if err := spec.validate(kind.tool); err != nil {
+				return fmt.Errorf("%s middleware %d: %w", kind.name, i+1, err)
+			}
+			name := spec.name()
+			if seen[name] {
+				return fmt.Errorf("%s middleware %d: %s is listed twice", kind.name, i+1, name)
+			}
+			seen[name] = true
+		}
+	}
+	return nil
+}
+
+func (s *MiddlewareSpec) validate(tool bool) error {
+	if (s.Use == "") == (s.File == "") {
+		return errors.New("exactly one of use and file must be set")
+	}
+	cors := len(s.AllowedOrigins) > 0 || len(s.AllowedMethods) > 0 || len(s.AllowedHeaders) > 0 ||
+		len(s.ExposedHeaders) > 0 || s.AllowCredentials || s.MaxAge != 0
+	if cors && s.Use != MiddlewareCORS {
+		return errors.New("allowedOrigins, allowedMethods, allowedHeaders, exposedHeaders, allowCredentials and maxAge only apply to cors")
+	}
+	if s.MaxBytes != 0 && s.Use != MiddlewareBodyLimit {
+		return errors.New("maxBytes only applies to body-limit")
+	}
+	if s.File != "" {
+		if !strings.HasSuffix(s.File, ".go") {
+			return fmt.Errorf("middleware file %s must be a Go file", s.File)
+		}
+		if filepath.Base(s.File) == toolCallFile {
+			return fmt.Errorf("%s is reserved for the middleware package", toolCallFile)
+		}
+		return nil
+	}
+
+	switch {
+	case s.Use == MiddlewareLogging || s.Use == MiddlewareRecovery:
+	case tool:
+		return fmt.Errorf("unsupported tool middleware '%s', expected logging or recovery", s.Use)
+	case s.Use != MiddlewareRequestID && s.Use != MiddlewareCORS && s.Use != MiddlewareBodyLimit:
+		return fmt.Errorf("unsupported middleware '%s', expected request-id, logging, recovery, cors or body-limit", s.Use)
+	}
+	if s.Use == MiddlewareCORS && len(s.AllowedOrigins) == 0 {
+		return errors.New("cors requires allowedOrigins")
+	}
+	if s.Use == MiddlewareCORS && s.AllowCredentials && slices.Contains(s.AllowedOrigins, "*") {
+		return errors.New("allowCredentials cannot be combined with the allowedOrigins wildcard *; list the origins instead")
+	}
+	if s.Use == MiddlewareCORS && s.MaxAge < 0 {
+		return errors.New("maxAge must not be negative")
+	}
+	if s.Use == MiddlewareBodyLimit && s.MaxBytes <= 0 {
+		return errors.New("body-limit requires a positive maxBytes")
+	}
+	return nil
+}
+
+// name is what the middleware is injected as: the built-in, or the file name.
+func (s *MiddlewareSpec) name() string {
+	if s.File != "" {
+		return filepath.Base(s.File)
+	}
+	return s.Use
+}
+
+// middlewareView is a middleware injected into the plugin manager of the
+// server.
+type middlewareView struct {
+	Name     string
+	Priority int
+	Tool     bool
+	// Expr is the Go expression of the middleware.
+	Expr string
+	// File marks middleware exported by a middleware file.
+	File bool
+	// MaxAge marks CORS options using package time.
+	MaxAge bool
+}
+
+// middleware checks the middleware config and the files it names, and
+// returns the middleware in the order the server injects it, with the files
+// keyed by their path in the generated module.
+func (cg *CodeGenerator) middleware() ([]*middlewareView, map[string][]byte, error) {
+	if cg.Middleware == nil {
+		return nil, nil, nil
+	}
+	if err := cg.Middleware.validate(); err != nil {
+		return nil, nil, err
+	}
+	var views []*middlewareView
+	sources := map[string][]byte{}
+	paths := map[string]string{}
+	add := func(spec MiddlewareSpec, priority int, tool bool) error {
+		view := &middlewareView{Name: spec.name(), Priority: priority, Tool: tool}
+		views = append(views, view)
+		if spec.Use != "" {
+			view.Expr = builtinMiddleware(spec, tool)
+			view.MaxAge = spec.MaxAge > 0
+			return nil
+		}
+
+		name := filepath.Base(spec.File)
+		if other, ok := paths[name]; ok && filepath.Clean(other) != filepath.Clean(spec.File) {
+			return fmt.Errorf("middleware files %s and %s would both be copied to %s/%s", other, spec.File, middlewarePackage, name)
+		}
+		src, err := os.ReadFile(spec.File)
+		if err != nil {
+			return fmt.Errorf("cannot read middleware file %s: %w", spec.File, err)
+		}
+		fn, err := checkMiddlewareFile(spec.File, src, tool)
+		if err != nil {
+			return fmt.Errorf("middleware file %s: %w", spec.File, err)
+		}
+		paths[name] = spec.File
+		sources[middlewarePackage+"/"+name] = src
+		view.File = true
+		view.Expr = middlewarePackage + "." + fn
+		if tool {
+			view.Expr = "toolMiddlewareFunc(" + view.Expr + ")"
+		}
+		return nil
+	}
+	for i, spec := range cg.Middleware.HTTP {
+		if err := add(spec, i, false); err != nil {
+			return nil, nil, err
+		}
+	}
+	for i, spec := range cg.Middleware.Tools {
+		if err := add(spec, i, true); err != nil {
+			return nil, nil, err
+		}
+	}
+	return views, sources, nil
+}
+
+// builtinMiddleware returns the Go expression of a built-in middleware.
+func builtinMiddleware(spec MiddlewareSpec, tool bool) string {
+	switch {
+	case spec.Use == MiddlewareLogging && tool:
+		return "LogToolCalls(log.Default())"
+	case spec.Use == MiddlewareRecovery && tool:
+		return "RecoverToolPanics()"
+	case spec.Use == MiddlewareRequestID:
+		return "RequestID()"
+	case spec.Use == MiddlewareLogging:
+		return "LogRequests(log.Default())"
+	case spec.Use == MiddlewareRecovery:
+		return "RecoverPanics(log.Default())"
+	case spec.Use == MiddlewareBodyLimit:
+		return "LimitBody(" + strconv.FormatInt(spec.MaxBytes, 10) + ")"
+	}
+
+	var fields []string
+	for _, list := range []struct {
+		field  string
+		values []string
+	}{
+		{"AllowedOrigins", spec.AllowedOrigins},
+		{"AllowedMethods", spec.AllowedMethods},
+		{"AllowedHeaders", spec.AllowedHeaders},
+		{"ExposedHeaders", spec.ExposedHeaders},
+	} {
+		if len(list.values) == 0 {
+			continue
+		}
+		quoted := make([]string, len(list.values))
+		for i, v := range list.values {
+			quoted[i] = strconv.Quote(v)
+		}
+		fields = append(fields, list.field+": []string{"+strings.Join(quoted, ", ")+"}")
+	}
+	if spec.AllowCredentials {
+		fields = append(fields, "AllowCredentials: true")
+	}
+	if spec.MaxAge > 0 {
+		fields = append(fields, "MaxAge: "+strconv.Itoa(spec.MaxAge)+" * time.Second")
+	}
+	return "CORS(CORSOptions{" + strings.Join(fields, ", ") + "})"
+}
+
+// checkMiddlewareFile checks that a middleware file belongs to the middleware
+// package, imports only the standard library and exports the middleware
+// function named after the file. It returns the name of the function.
+func checkMiddlewareFile(path string, src []byte, tool bool) (string, error) {
+	fn, err := exportedName(path)
+	if err != nil {
+		return "", err
+	}
+	want := exportedFunc{
+		kind:    "middleware file",
+		pkg:     middlewarePackage,
+		name:    fn,
+		params:  []string{"net/http.Handler"},
+		results: []string{"net/http.Handler"},
+		display: fmt.Sprintf("func %s(next http.Handler) http.Handler", fn),
+	}
+	if tool {
+		want.params = []string{"ToolHandler"}
+		want.results = []string{"ToolHandler"}
+		want.display = fmt.Sprintf("func %s(next ToolHandler) ToolHandler", fn)
+	}
+	if err := checkGoFile(path, src, want); err != nil {
+		return "", err
+	}
+	return fn, nil
+}
+
+// MiddlewareFiles reports whether the server has middleware files, which
+// make up its middleware package.
+func (d *templateData) MiddlewareFiles() bool {
+	for _, m := range d.Middleware {
+		if m.File {
+			return true
+		}
+	}
+	return false
+}
+
+// ToolMiddlewareFiles reports whether the middleware package has tool
+// middleware, which needs its ToolCall and ToolHandler.
+func (d *templateData) ToolMiddlewareFiles() bool {
+	for _, m := range d.Middleware {
+		if m.File && m.Tool {
+			return true
+		}
+	}
+	return false
+}
+
+// MiddlewareMaxAge reports whether CORS options of the server set MaxAge.
+func (d *templateData) MiddlewareMaxAge() bool {
+	for _, m := range d.Middleware {
+		if m.MaxAge {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package codegenerator
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMiddlewareConfig(t *testing.T) {
+	c, err := LoadMiddlewareConfig("testdata/middleware/middleware.yaml")
+	require.NoError(t, err)
+	require.Len(t, c.HTTP, 6)
+	assert.Equal(t, MiddlewareSpec{Use: MiddlewareCORS, AllowedOrigins: []string{"https://app.example.com"}, MaxAge: 600}, c.HTTP[3])
+	assert.Equal(t, filepath.Join("testdata", "middleware", "served_by.go"), c.HTTP[5].File)
+	assert.Equal(t, filepath.Join("testdata", "middleware", "deny_blocked.go"), c.Tools[2].File)
+
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{"empty", "", ""},
+		{"unknown key", "http:\n  - use: cors\n    allowedOrigin: [x]\n", "field allowedOrigin not found"},
+		{"use and file", "http:\n  - use: logging\n    file: a.go\n", "http middleware 1: exactly one of use and file must be set"},
+		{"unknown built-in", "http:\n  - use: gzip\n", "unsupported middleware 'gzip', expected request-id, logging, recovery, cors or body-limit"},
+		{"HTTP built-in around tools", "tools:\n  - use: cors\n", "tools middleware 1: unsupported tool middleware 'cors', expected logging or recovery"},
+		{"cors without origins", "http:\n  - use: cors\n", "cors requires allowedOrigins"},
+		{"cors wildcard with credentials", "http:\n  - use: cors\n    allowedOrigins: ['*']\n    allowCredentials: true\n", "allowCredentials cannot be combined with the allowedOrigins wildcard"},
+		{"body-limit without limit", "http:\n  - use: body-limit\n", "body-limit requires a positive maxBytes"},
+		{"misplaced option", "http:\n  - use: logging\n    maxBytes: 10\n", "maxBytes only applies to body-limit"},
+		{"duplicate", "http:\n  - use: logging\n  - use: request-id\n  - use: logging\n", "http middleware 3: logging is listed twice"},
+		{"not a Go file", "http:\n  - file: a.wasm\n", "middleware file"},
+		{"reserved name", "tools:\n  - file: tool_call.go\n", "tool_call.go is reserved for the middleware package"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "middleware.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(tt.config), 0o644))
+			_, err := LoadMiddlewareConfig(path)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestMiddleware_Errors(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, src string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(src), 0o644))
+		return path
+	}
+	httpFile := write("served_by.go", "package middleware\n\nimport \"net/http\"\n\nfunc ServedBy(next http.Handler) http.Handler { return next }\n")
+	other := write("other/served_by.go", "package middleware\n\nimport \"net/http\"\n\nfunc ServedBy(next http.Handler) http.Handler { return next }\n")
+	wrongPackage := write("audit.go", "package main\n")
+
+	generate := func(c MiddlewareConfig) error {
+		cg := testGenerator(t)
+		cg.Middleware = &c
+		return cg.GenerateServerCode()
+	}
+
+	err := generate(MiddlewareConfig{Tools: []MiddlewareSpec{{File: httpFile}}})
+	assert.ErrorContains(t, err, "ServedBy must have the signature func ServedBy(next ToolHandler) ToolHandler")
+
+	err = generate(MiddlewareConfig{HTTP: []MiddlewareSpec{{File: wrongPackage}}})
+	assert.ErrorContains(t, err, "middleware file must be in package middleware, not main")
+
+	err = generate(MiddlewareConfig{HTTP: []MiddlewareSpec{{File: filepath.Join(dir, "missing.go")}}})
+	assert.ErrorContains(t, err, "cannot read middleware file")
+
+	err = generate(MiddlewareConfig{HTTP: []MiddlewareSpec{{File: httpFile}}, Tools: []MiddlewareSpec{{File: other}}})
+	assert.ErrorContains(t, err, "would both be copied to middleware/served_by.go")
+
+	// Configs built in code are checked like loaded ones.
+	err = generate(MiddlewareConfig{HTTP: []MiddlewareSpec{{Use: MiddlewareBodyLimit}}})
+	assert.ErrorContains(t, err, "body-limit requires a positive maxBytes")
+}
+
+func TestGeneratedServer_Middleware(t *testing.T) {
+	cg := testGenerator(t)
+	cg.Transport = TransportHTTP
+	var err error
+	cg.Middleware, err = LoadMiddlewareConfig("testdata/middleware/middleware.yaml")
+	require.NoError(t, err)
+	bin := buildServer(t, cg)
+
+	files := readTree(t, cg.OutputDir)
+	assert.Contains(t, files, filepath.Join("middleware", "served_by.go"))
+	assert.Contains(t, files, filepath.Join("middleware", "tool_call.go"))
+	assert.Contains(t, files["middleware_config.go"], `plugins.InjectMiddleware("cors", 3, CORS(CORSOptions{AllowedOrigins: []string{"https://app.example.com"}, MaxAge: 600 * time.Second}))`)
+
+	api := upstream(t)
+	url := startHTTPServer(t, bin, "MCP_UPSTREAM_BASE_URL="+api.URL)
+	const accept = "application/json, text/event-stream"
+
+	init := postMCP(t, url, "", accept, map[string]any{"jsonrpc": "2.0", "id": 1, "method": "initialize",
+		"params": map[string]any{"protocolVersion": "2025-03-26"}})
+	require.Equal(t, http.StatusOK, init.StatusCode)
+	assert.Len(t, init.Header.Get("X-Request-ID"), 32)
+	assert.Equal(t, "mcpgen", init.Header.Get("X-Served-By"))
+	session := init.Header.Get("Mcp-Session-Id")
+
+	preflight, _ := http.NewRequest(http.MethodOptions, url, nil)
+	preflight.Header.Set("Origin", "https://app.example.com")
+	preflight.Header.Set("Access-Control-Request-Method", "POST")
+	resp, err := http.DefaultClient.Do(preflight)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, "https://app.example.com", resp.Header.Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "600", resp.Header.Get("Access-Control-Max-Age"))
+
+	large, _ := http.NewRequest(http.MethodPost, url, bytes.NewReader(make([]byte, 5000)))
+	resp, err = http.DefaultClient.Do(large)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+
+	call := func(id string) string {
+		resp := postMCP(t, url, session, accept, map[string]any{"jsonrpc": "2.0", "id": 2, "method": "tools/call",
+			"params": map[string]any{"name": "getUser", "arguments": map[string]any{"id": id}}})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		events := readEvents(t, resp)
+		require.NotEmpty(t, events)
+		return events[len(events)-1][1]
+	}
+	assert.Contains(t, call("42"), `\"name\":\"Ada\"`)
+	blocked := call("blocked")
+	assert.Contains(t, blocked, `"isError":true`)
+	assert.True(t, strings.Contains(blocked, "user is blocked"), blocked)
+}
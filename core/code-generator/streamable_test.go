@@ -0,0 +1,159 @@
+package codegenerator
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startHTTPServer runs a generated Streamable HTTP server and returns its MCP URL.
+func startHTTPServer(t *testing.T, bin string, env ...string) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+
+	cmd := exec.Command(bin, "-addr", addr)
+	cmd.Env = append(os.Environ(), env...)
+	require.NoError(t, cmd.Start())
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+
+	base := "http://" + addr
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(base + "/healthz")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, 10*time.Second, 50*time.Millisecond)
+	return base + "/mcp"
+}
+
+func postMCP(t *testing.T, url, session, accept string, msg any) *http.Response {
+	t.Helper()
+	body, err := json.Marshal(msg)
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", accept)
+	if session != "" {
+		req.Header.Set("Mcp-Session-Id", session)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// readEvents parses an SSE body into (id, data) pairs.
+func readEvents(t *testing.T, resp *http.Response) [][2]string {
+	t.Helper()
+	var events [][2]string
+	var id, data string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		case line == "":
+			events = append(events, [2]string{id, data})
+			id, data = "", ""
+		}
+	}
+	return events
+}
+
+func TestGeneratedServer_StreamableHTTP(t *testing.T) {
+	cg := testGenerator(t)
+	cg.Transport = TransportHTTP
+	bin := buildServer(t, cg)
+	api := upstream(t)
+	url := startHTTPServer(t, bin, "MCP_UPSTREAM_BASE_URL="+api.URL)
+	const accept = "application/json, text/event-stream"
+
+	init := postMCP(t, url, "", accept, map[string]any{"jsonrpc": "2.0", "id": 1, "method": "initialize",
+		"params": map[string]any{"protocolVersion": "2025-03-26"}})
+	require.Equal(t, http.StatusOK, init.StatusCode)
+	assert.Equal(t, "application/json", init.Header.Get("Content-Type"))
+	session := init.Header.Get("Mcp-Session-Id")
+	require.NotEmpty(t, session)
+
+	notified := postMCP(t, url, session, accept, map[string]any{"jsonrpc": "2.0", "method": "notifications/initialized"})
+	assert.Equal(t, http.StatusAccepted, notified.StatusCode)
+
+	missing := postMCP(t, url, "", accept, map[string]any{"jsonrpc": "2.0", "id": 2, "method": "tools/list"})
+	assert.Equal(t, http.StatusBadRequest, missing.StatusCode)
+	unknown := postMCP(t, url, "nope", accept, map[string]any{"jsonrpc": "2.0", "id": 2, "method": "tools/list"})
+	assert.Equal(t, http.StatusNotFound, unknown.StatusCode)
+
+	call := postMCP(t, url, session, accept, map[string]any{"jsonrpc": "2.0", "id": 3, "method": "tools/call",
+		"params": map[string]any{"name": "getUser", "arguments": map[string]any{"id": "42"}}})
+	require.Equal(t, http.StatusOK, call.StatusCode)
+	assert.Equal(t, "text/event-stream", call.Header.Get("Content-Type"))
+	events := readEvents(t, call)
+	require.Len(t, events, 2)
+	assert.Contains(t, events[1][1], `\"name\":\"Ada\"`)
+
+	// Resume the stream after the priming event and get the response replayed.
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Mcp-Session-Id", session)
+	req.Header.Set("Last-Event-ID", events[0][0])
+	resumed, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resumed.Body.Close()
+	replayed := readEvents(t, resumed)
+	require.Len(t, replayed, 1)
+	assert.Equal(t, events[1], replayed[0])
+
+	invalid := postMCP(t, url, session, accept, map[string]any{"jsonrpc": "2.0", "id": 5, "method": 5})
+	require.Equal(t, http.StatusOK, invalid.StatusCode)
+	body, err := io.ReadAll(invalid.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `"code":-32600`)
+
+	del, _ := http.NewRequest(http.MethodDelete, url, nil)
+	del.Header.Set("Mcp-Session-Id", session)
+	deleted, err := http.DefaultClient.Do(del)
+	require.NoError(t, err)
+	deleted.Body.Close()
+	assert.Equal(t, http.StatusNoContent, deleted.StatusCode)
+
+	gone := postMCP(t, url, session, accept, map[string]any{"jsonrpc": "2.0", "id": 4, "method": "tools/list"})
+	assert.Equal(t, http.StatusNotFound, gone.StatusCode)
+
+	// Without MCP_ALLOWED_ORIGINS no browser may call the endpoint.
+	browser, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"initialize"}`))
+	browser.Header.Set("Origin", "https://evil.example.com")
+	browser.Header.Set("Accept", accept)
+	rejected, err := http.DefaultClient.Do(browser)
+	require.NoError(t, err)
+	rejected.Body.Close()
+	assert.Equal(t, http.StatusForbidden, rejected.StatusCode)
+}
+
+func TestGenerateServerCode_UnsupportedTransport(t *testing.T) {
+	cg := testGenerator(t)
+	cg.Transport = "websocket"
+	assert.Error(t, cg.GenerateServerCode())
+}
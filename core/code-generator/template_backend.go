@@ -0,0 +1,457 @@
+package codegenerator
+
+import (
+	"MCPGen/core/flow-compiler"
+	runtimeexpr "MCPGen/core/flow-compiler/runtime-expr"
+	pluginmanager "MCPGen/core/plugin-manager"
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+//go:embed templates/*.tmpl runtime/*.txt
+var templateFS embed.FS
+
+var serverTemplates = template.Must(template.New("server").Funcs(template.FuncMap{
+	"goString": goString,
+	"goValue":  goValue,
+}).ParseFS(templateFS, "templates/*.tmpl"))
+
+// generatedFiles maps output file names to their source: a template rendered
+// from the compiled flows, or a runtime file copied verbatim. Files with a
+// condition are only generated when it holds.
+var generatedFiles = []struct {
+	name      string
+	template  string
+	runtime   string
+	transport Transport
+	condition func(*templateData) bool
+}{
+	{name: "go.mod", template: "go.mod.tmpl"},
+	{name: "go.sum", runtime: "runtime/wazero.sum.txt", condition: (*templateData).WASMHooks},
+	{name: "main.go", template: "main.go.tmpl"},
+	{name: "handlers.go", template: "handlers.go.tmpl"},
+	{name: "flows.go", template: "flows.go.tmpl"},
+	{name: "tools.go", template: "tools.go.tmpl"},
+	{name: "engine.go", runtime: "runtime/engine.go.txt"},
+	{name: "hooks.go", runtime: "runtime/hooks.go.txt"},
+	{name: "schema.go", runtime: "runtime/schema.go.txt"},
+	{name: "auth.go", runtime: "runtime/auth.go.txt"},
+	{name: "inbound.go", runtime: "runtime/inbound.go.txt"},
+	{name: "mcp.go", runtime: "runtime/mcp.go.txt"},
+	{name: "streamable.go", runtime: "runtime/streamable.go.txt", transport: TransportHTTP},
+	{name: "hook_files.go", template: "hook_files.go.tmpl", condition: func(d *templateData) bool { return len(d.HookFiles) > 0 }},
+	{name: hooksPackage + "/" + hookContextFile, runtime: "runtime/step_context.go.txt", condition: (*templateData).GoHooks},
+	{name: "middleware_config.go", template: "middleware_config.go.tmpl", condition: func(d *templateData) bool { return len(d.Middleware) > 0 }},
+	{name: middlewarePackage + "/" + toolCallFile, runtime: "runtime/tool_call.go.txt", condition: (*templateData).ToolMiddlewareFiles},
+}
+
+type templateData struct {
+	ModuleName  string
+	Transport   Transport
+	MCPEndpoint string
+	Validation  ValidationMode
+	Auth        InboundAuth
+	Endpoints   []*endpointView
+	Flows       []*flowView
+	Tools       []*toolView
+	HookFiles   []*hookFileView
+	Middleware  []*middlewareView
+}
+
+type endpointView struct {
+	VarName         string
+	ID              string
+	Service         string
+	Method          string
+	Path            string
+	BaseURL         string
+	Params          []flowcompiler.Parameter
+	BodyContentType string
+	BodySchema      interface{}
+	Responses       map[string]interface{}
+	Definitions     map[string]interface{}
+	Security        [][]flowcompiler.SecurityScheme
+	endpoint        *flowcompiler.Endpoint
+}
+
+type flowView struct {
+	ID          string
+	VarName     string
+	HandlerName string
+	Steps       []stepView
+	Levels      [][]string
+	Inputs      map[string]interface{}
+	Outputs     map[string]string
+	Scopes      []string
+	flow        *flowcompiler.CompiledFlow
+	endpoints   []*endpointView
+	nested      []string
+}
+
+type stepView struct {
+	ID              string
+	EndpointVar     string
+	Workflow        string
+	Params          []flowcompiler.StepParameter
+	Body            *flowcompiler.StepRequestBody
+	Outputs         map[string]string
+	SuccessCriteria []flowcompiler.Criterion
+	OnSuccess       []actionView
+	OnFailure       []actionView
+	PreHook         string
+	PostHook        string
+	DependsOn       []string
+}
+
+type actionView struct {
+	Name       string
+	Type       string
+	StepID     string
+	WorkflowID string
+	RetryAfter float64
+	RetryLimit int
+	Criteria   []flowcompiler.Criterion
+}
+
+type toolView struct {
+	Name        string
+	Description string
+	InputSchema string
+	FlowVar     string
+	EndpointVar string
+}
+
+// renderServer renders every generated file. The output only depends on the
+// generator inputs, so repeated runs produce byte-identical files.
+func (cg *CodeGenerator) renderServer() (map[string][]byte, error) {
+	data := cg.templateData()
+	hookFiles, hookSources, err := cg.hookFiles()
+	if err != nil {
+		return nil, err
+	}
+	data.HookFiles = hookFiles
+	middleware, middlewareSources, err := cg.middleware()
+	if err != nil {
+		return nil, err
+	}
+	data.Middleware = middleware
+	files := make(map[string][]byte, len(generatedFiles)+len(hookSources)+len(middlewareSources))
+	for _, f := range generatedFiles {
+		if f.transport != "" && f.transport != data.Transport {
+			continue
+		}
+		if f.condition != nil && !f.condition(data) {
+			continue
+		}
+		var out []byte
+		if f.runtime != "" {
+			src, err := templateFS.ReadFile(f.runtime)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", f.runtime, err)
+			}
+			out = src
+		} else {
+			var buf bytes.Buffer
+			if err := serverTemplates.ExecuteTemplate(&buf, f.template, data); err != nil {
+				return nil, fmt.Errorf("failed to render %s: %w", f.name, err)
+			}
+			out = buf.Bytes()
+		}
+		if strings.HasSuffix(f.name, ".go") {
+			formatted, err := format.Source(out)
+			if err != nil {
+				return nil, fmt.Errorf("failed to format %s: %w", f.name, err)
+			}
+			out = formatted
+		}
+		files[f.name] = out
+	}
+
+	// The runtime expression evaluator is shared with the flow compiler, the
+	// hook registry with the plugin manager package.
+	shared := []sharedSources{
+		{"runtime expression", runtimeexpr.Sources},
+		{"plugin manager", pluginmanager.Sources},
+	}
+	if data.WASMHooks() {
+		shared = append(shared, sharedSources{"wasm hook", pluginmanager.WASMSources})
+	}
+	for _, pkg := range shared {
+		pkgFiles, err := pkg.sources()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s sources: %w", pkg.what, err)
+		}
+		for name, src := range pkgFiles {
+			formatted, err := format.Source(src)
+			if err != nil {
+				return nil, fmt.Errorf("failed to format %s: %w", name, err)
+			}
+			files[name] = formatted
+		}
+	}
+	// Hook and middleware files are copied as written.
+	for name, src := range hookSources {
+		files[name] = src
+	}
+	for name, src := range middlewareSources {
+		files[name] = src
+	}
+	return files, nil
+}
+
+// sharedSources are the files of a package copied into the server.
+type sharedSources struct {
+	what    string
+	sources func() (map[string][]byte, error)
+}
+
+// GoHooks reports whether steps name Go hook files, which make up the hooks
+// package of the server.
+func (d *templateData) GoHooks() bool {
+	for _, h := range d.HookFiles {
+		if !h.WASM {
+			return true
+		}
+	}
+	return false
+}
+
+// WASMHooks reports whether steps name WebAssembly hook modules, which the
+// server runs with wazero.
+func (d *templateData) WASMHooks() bool {
+	for _, h := range d.HookFiles {
+		if h.WASM {
+			return true
+		}
+	}
+	return false
+}
+
+func (cg *CodeGenerator) templateData() *templateData {
+	data := &templateData{ModuleName: cg.ModuleName, Transport: cg.Transport, MCPEndpoint: cg.MCPEndpoint, Validation: cg.Validation}
+	if cg.Auth != nil {
+		data.Auth = *cg.Auth
+	}
+	if data.ModuleName == "" {
+		data.ModuleName = defaultModuleName
+	}
+	if data.Transport == "" {
+		data.Transport = TransportStdio
+	}
+	if data.MCPEndpoint == "" {
+		data.MCPEndpoint = defaultMCPEndpoint
+	}
+	if data.Validation == "" {
+		data.Validation = ValidationWarn
+	}
+
+	names := newNamer()
+	byKey := map[string]*endpointView{}
+	addEndpoint := func(ep *flowcompiler.Endpoint) *endpointView {
+		key := ep.Service + " " + ep.Method + " " + ep.BaseURL + ep.Path + "#" + ep.ID
+		if view, ok := byKey[key]; ok {
+			return view
+		}
+		id := ep.ID
+		if id == "" {
+			id = ep.Method + " " + ep.Path
+		}
+		view := &endpointView{
+			VarName: names.unique("op" + goName(ep.Service+" "+id)),
+			ID:      ep.ID,
+			Service: ep.Service,
+			Method:  strings.ToUpper(ep.Method),
+			Path:    ep.Path,
+			BaseURL: ep.BaseURL,
+			Params:  ep.Parameters,
+
+			Definitions: ep.Definitions,
+			Security:    ep.Security,
+			endpoint:    ep,
+		}
+		if ep.RequestBody != nil {
+			view.BodyContentType = ep.RequestBody.ContentType
+			if view.BodyContentType == "" {
+				view.BodyContentType = "application/json"
+			}
+			view.BodySchema = ep.RequestBody.Schema
+		}
+		for code, resp := range ep.Responses {
+			if resp.Schema == nil {
+				continue
+			}
+			if view.Responses == nil {
+				view.Responses = map[string]interface{}{}
+			}
+			view.Responses[code] = resp.Schema
+		}
+		byKey[key] = view
+		data.Endpoints = append(data.Endpoints, view)
+		return view
+	}
+	for i := range cg.Endpoints {
+		addEndpoint(&cg.Endpoints[i])
+	}
+
+	flows := append([]*flowcompiler.CompiledFlow{}, cg.Flows...)
+	sort.SliceStable(flows, func(i, j int) bool { return flows[i].WorkflowID < flows[j].WorkflowID })
+	for _, flow := range flows {
+		view := &flowView{
+			ID:          flow.WorkflowID,
+			VarName:     names.unique("flow" + goName(flow.WorkflowID)),
+			HandlerName: names.unique("handle" + goName(flow.WorkflowID)),
+			Levels:      flow.Levels,
+			Inputs:      flow.Inputs,
+			Outputs:     flow.Outputs,
+			Scopes:      data.Auth.Scopes[flow.WorkflowID],
+			flow:        flow,
+		}
+		for _, step := range flow.Steps {
+			sv := stepView{
+				ID:              step.StepID,
+				Workflow:        step.WorkflowID,
+				Params:          step.Parameters,
+				Body:            step.RequestBody,
+				Outputs:         step.Outputs,
+				SuccessCriteria: step.SuccessCriteria,
+				OnSuccess:       actionViews(step.OnSuccess),
+				OnFailure:       actionViews(step.OnFailure),
+				PreHook:         step.PreHook,
+				PostHook:        step.PostHook,
+				DependsOn:       step.DependsOn,
+			}
+			if step.Endpoint != nil {
+				ep := addEndpoint(step.Endpoint)
+				view.endpoints = append(view.endpoints, ep)
+				sv.EndpointVar = ep.VarName
+			} else {
+				view.nested = append(view.nested, step.WorkflowID)
+			}
+			view.Steps = append(view.Steps, sv)
+		}
+		data.Flows = append(data.Flows, view)
+	}
+	inlineNestedEndpoints(data.Flows)
+
+	data.Tools = buildTools(data)
+	return data
+}
+
+// actionViews converts compiled actions. A retry without retryLimit is
+// attempted once, as the Arazzo specification requires.
+func actionViews(actions []flowcompiler.Action) []actionView {
+	var out []actionView
+	for _, a := range actions {
+		view := actionView{
+			Name:       a.Name,
+			Type:       a.Type,
+			StepID:     a.StepID,
+			WorkflowID: a.WorkflowID,
+			RetryLimit: 1,
+			Criteria:   a.Criteria,
+		}
+		if a.RetryAfter != nil {
+			view.RetryAfter = *a.RetryAfter
+		}
+		if a.RetryLimit != nil {
+			view.RetryLimit = *a.RetryLimit
+		}
+		out = append(out, view)
+	}
+	return out
+}
+
+// inlineNestedEndpoints adds the endpoints of nested workflows to the flows
+// running them, so their tool schemas cover the nested arguments too.
+func inlineNestedEndpoints(flows []*flowView) {
+	byID := make(map[string]*flowView, len(flows))
+	for _, f := range flows {
+		byID[f.ID] = f
+	}
+	for _, f := range flows {
+		seen := map[*endpointView]bool{}
+		for _, ep := range f.endpoints {
+			seen[ep] = true
+		}
+		visited := map[string]bool{f.ID: true}
+		queue := append([]string{}, f.nested...)
+		for len(queue) > 0 {
+			nested := byID[queue[0]]
+			queue = queue[1:]
+			if nested == nil || visited[nested.ID] {
+				continue
+			}
+			visited[nested.ID] = true
+			for _, ep := range nested.endpoints {
+				if !seen[ep] {
+					seen[ep] = true
+					f.endpoints = append(f.endpoints, ep)
+				}
+			}
+			queue = append(queue, nested.nested...)
+		}
+	}
+}
+
+// goString quotes s as a Go string literal, preferring a raw string literal.
+func goString(s string) string {
+	if strings.ContainsAny(s, "`\r") {
+		return strconv.Quote(s)
+	}
+	return "`" + s + "`"
+}
+
+// goValue renders a decoded YAML or JSON value as a Go expression evaluating
+// to the same value.
+func goValue(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode %v: %w", v, err)
+	}
+	return "jsonValue(" + goString(string(data)) + ")", nil
+}
+
+// goName converts an identifier such as "sync-user-data" into "SyncUserData".
+func goName(s string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	if b.Len() == 0 {
+		return "X"
+	}
+	return b.String()
+}
+
+// namer hands out Go identifiers that are unique within a generated package.
+type namer map[string]bool
+
+func newNamer() namer {
+	return namer{}
+}
+
+func (n namer) unique(name string) string {
+	candidate := name
+	for i := 2; n[candidate]; i++ {
+		candidate = fmt.Sprintf("%s%d", name, i)
+	}
+	n[candidate] = true
+	return candidate
+}
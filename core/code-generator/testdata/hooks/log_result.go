@@ -0,0 +1,15 @@
+package hooks
+
+import (
+	"context"
+	"log"
+)
+
+// LogResult logs the status of a step and marks its response as logged.
+func LogResult(ctx context.Context, sc *StepContext, resp *StepResponse) error {
+	log.Printf("%s/%s: %d", sc.FlowID, sc.StepID, resp.StatusCode)
+	if body, ok := resp.Body.(map[string]any); ok {
+		body["logged"] = true
+	}
+	return nil
+}
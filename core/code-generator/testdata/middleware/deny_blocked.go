@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+)
+
+// DenyBlocked refuses tool calls for the user "blocked".
+func DenyBlocked(next ToolHandler) ToolHandler {
+	return func(ctx context.Context, call *ToolCall) (any, error) {
+		if call.Arguments["id"] == "blocked" {
+			return nil, errors.New("user is blocked")
+		}
+		return next(ctx, call)
+	}
+}
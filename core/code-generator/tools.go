@@ -0,0 +1,183 @@
+package codegenerator
+
+import (
+	"MCPGen/core/flow-compiler"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// maxToolNameLength is the longest tool name accepted by common MCP clients.
+const maxToolNameLength = 64
+
+// buildTools exposes every workflow and every API operation as an MCP tool.
+// Workflows come first so they win name clashes with operations. Operations
+// whose ID is shared by several services are named service_id.
+func buildTools(data *templateData) []*toolView {
+	names := map[string]bool{}
+	services := map[string]map[string]bool{}
+	for _, ep := range data.Endpoints {
+		if services[ep.ID] == nil {
+			services[ep.ID] = map[string]bool{}
+		}
+		services[ep.ID][ep.Service] = true
+	}
+	var tools []*toolView
+	for _, flow := range data.Flows {
+		tools = append(tools, &toolView{
+			Name:        uniqueToolName(names, flow.ID),
+			Description: "Runs the " + flow.ID + " workflow.",
+			InputSchema: encodeSchema(flowInputSchema(flow)),
+			FlowVar:     flow.VarName,
+		})
+	}
+	for _, ep := range data.Endpoints {
+		name := ep.ID
+		if name == "" {
+			name = strings.ToLower(ep.Method) + "_" + ep.Path
+		} else if len(services[ep.ID]) > 1 && ep.Service != "" {
+			name = ep.Service + "_" + ep.ID
+		}
+		tools = append(tools, &toolView{
+			Name:        uniqueToolName(names, name),
+			Description: endpointDescription(ep),
+			InputSchema: encodeSchema(endpointInputSchema(ep.endpoint)),
+			EndpointVar: ep.VarName,
+		})
+	}
+	return tools
+}
+
+// endpointInputSchema describes the tool arguments of an operation: one
+// property per parameter plus "body" for the request body.
+func endpointInputSchema(ep *flowcompiler.Endpoint) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+	for _, p := range ep.Parameters {
+		properties[p.Name] = parameterSchema(p)
+		if p.Required {
+			required = append(required, p.Name)
+		}
+	}
+	if ep.RequestBody != nil {
+		properties["body"] = orEmptySchema(ep.RequestBody.Schema)
+		if ep.RequestBody.Required {
+			required = append(required, "body")
+		}
+	}
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	if len(ep.Definitions) > 0 {
+		schema["$defs"] = ep.Definitions
+	}
+	return schema
+}
+
+// flowInputSchema returns the inputs schema of a workflow. Without one it
+// merges the arguments of every step, since the workflow inputs are handed to
+// each step by name.
+func flowInputSchema(flow *flowView) map[string]interface{} {
+	if flow.Inputs != nil {
+		return flow.Inputs
+	}
+	properties := map[string]interface{}{}
+	defs := map[string]interface{}{}
+	for _, ep := range flow.endpoints {
+		stepSchema := endpointInputSchema(ep.endpoint)
+		for name, prop := range stepSchema["properties"].(map[string]interface{}) {
+			if _, ok := properties[name]; !ok {
+				properties[name] = prop
+			}
+		}
+		for name, def := range ep.endpoint.Definitions {
+			defs[name] = def
+		}
+	}
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(defs) > 0 {
+		schema["$defs"] = defs
+	}
+	return schema
+}
+
+func parameterSchema(p flowcompiler.Parameter) interface{} {
+	schema, ok := p.Schema.(map[string]interface{})
+	if !ok {
+		return orEmptySchema(p.Schema)
+	}
+	if _, has := schema["description"]; has {
+		return schema
+	}
+	out := make(map[string]interface{}, len(schema)+1)
+	for k, v := range schema {
+		out[k] = v
+	}
+	out["description"] = p.In + " parameter"
+	return out
+}
+
+func orEmptySchema(schema interface{}) interface{} {
+	if schema == nil {
+		return map[string]interface{}{}
+	}
+	return schema
+}
+
+func endpointDescription(ep *endpointView) string {
+	desc := ep.Method + " " + ep.Path
+	if ep.endpoint.Summary != "" {
+		desc = ep.endpoint.Summary + " (" + desc + ")"
+	}
+	if ep.endpoint.Description != "" {
+		desc += "\n\n" + ep.endpoint.Description
+	}
+	return desc
+}
+
+// encodeSchema marshals a schema with sorted keys so output is deterministic.
+func encodeSchema(schema interface{}) string {
+	out, err := json.Marshal(schema)
+	if err != nil {
+		return `{"type":"object"}`
+	}
+	return string(out)
+}
+
+// uniqueToolName sanitizes name to the MCP tool name alphabet and makes it unique.
+func uniqueToolName(used map[string]bool, name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	base := strings.Trim(b.String(), "_")
+	if base == "" {
+		base = "tool"
+	}
+	if len(base) > maxToolNameLength {
+		base = base[:maxToolNameLength]
+	}
+	candidate := base
+	for i := 2; used[candidate]; i++ {
+		suffix := "_" + strconv.Itoa(i)
+		trimmed := base
+		if len(trimmed)+len(suffix) > maxToolNameLength {
+			trimmed = trimmed[:maxToolNameLength-len(suffix)]
+		}
+		candidate = trimmed + suffix
+	}
+	used[candidate] = true
+	return candidate
+}
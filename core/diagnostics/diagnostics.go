@@ -0,0 +1,125 @@
+// Package diagnostics describes problems found in API specs and Arazzo
+// documents, with enough location information for an editor or CI system to
+// point at them.
+package diagnostics
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Severity ranks a diagnostic. Only errors stop generation.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+)
+
+// Diagnostic is a problem at a position in a source document.
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+	// Rule identifies the check that reported the diagnostic, e.g.
+	// "schema-type".
+	Rule string `json:"rule,omitempty"`
+	// File is the path or URL of the document.
+	File string `json:"file,omitempty"`
+	// Line and Column are 1-based and zero when unknown.
+	Line   int `json:"line,omitempty"`
+	Column int `json:"column,omitempty"`
+	// Pointer is the JSON pointer of the offending value in the document, e.g.
+	// "/paths/~1users/get/responses".
+	Pointer string `json:"pointer,omitempty"`
+	Message string `json:"message"`
+}
+
+// String formats d as "file:line:column: severity: message (at pointer)",
+// leaving out the parts that are unknown.
+func (d Diagnostic) String() string {
+	var b strings.Builder
+	if d.File != "" {
+		b.WriteString(d.File)
+		if d.Line > 0 {
+			fmt.Fprintf(&b, ":%d:%d", d.Line, d.Column)
+		}
+		b.WriteString(": ")
+	}
+	fmt.Fprintf(&b, "%s: %s", d.Severity, d.Message)
+	if d.Pointer != "" {
+		fmt.Fprintf(&b, " (at %s)", d.Pointer)
+	}
+	return b.String()
+}
+
+// List is a set of diagnostics, usually of several documents.
+type List []Diagnostic
+
+// HasErrors reports whether l holds a diagnostic of severity error.
+func (l List) HasErrors() bool {
+	for _, d := range l {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns l as an *Error when it holds an error, and nil otherwise.
+func (l List) Err() error {
+	if !l.HasErrors() {
+		return nil
+	}
+	return &Error{Diagnostics: l}
+}
+
+// Sort orders l by file, line and column, keeping the order of diagnostics at
+// the same position.
+func (l List) Sort() {
+	sort.SliceStable(l, func(i, j int) bool {
+		a, b := l[i], l[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+}
+
+// Error carries the diagnostics that made an operation fail. Callers that
+// report diagnostics get them back with errors.As.
+type Error struct {
+	Diagnostics List
+}
+
+func (e *Error) Error() string {
+	var msgs []string
+	for _, d := range e.Diagnostics {
+		if d.Severity == SeverityError {
+			msgs = append(msgs, d.String())
+		}
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// FromError returns the diagnostics carried by err, or a single error
+// diagnostic with the message of err when it carries none. file is set on the
+// diagnostics that do not name one.
+func FromError(file string, err error) List {
+	var de *Error
+	if !errors.As(err, &de) {
+		return List{{Severity: SeverityError, File: file, Message: err.Error()}}
+	}
+	out := make(List, len(de.Diagnostics))
+	for i, d := range de.Diagnostics {
+		if d.File == "" {
+			d.File = file
+		}
+		out[i] = d
+	}
+	return out
+}
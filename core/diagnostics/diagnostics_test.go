@@ -0,0 +1,122 @@
+package diagnostics
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+var sample = List{
+	{Severity: SeverityWarning, Rule: "naming", File: "b.yaml", Message: "odd name"},
+	{Severity: SeverityError, Rule: "schema-type", File: "a.yaml", Line: 7, Column: 11, Pointer: "/definitions/User/type", Message: "bad type"},
+	{Severity: SeverityInfo, Message: "no file"},
+}
+
+func TestDiagnostic_String(t *testing.T) {
+	assert.Equal(t, "b.yaml: warning: odd name", sample[0].String())
+	assert.Equal(t, "a.yaml:7:11: error: bad type (at /definitions/User/type)", sample[1].String())
+	assert.Equal(t, "info: no file", sample[2].String())
+}
+
+func TestList_Err(t *testing.T) {
+	assert.NoError(t, List{sample[0], sample[2]}.Err())
+
+	err := fmt.Errorf("cannot load: %w", sample.Err())
+	assert.EqualError(t, err, "cannot load: a.yaml:7:11: error: bad type (at /definitions/User/type)")
+	got := FromError("c.yaml", err)
+	assert.Equal(t, sample[:2], got[:2])
+	assert.Equal(t, "c.yaml", got[2].File, "diagnostics without a file get the one of the error")
+
+	got = FromError("c.yaml", errors.New("cannot read file"))
+	assert.Equal(t, List{{Severity: SeverityError, File: "c.yaml", Message: "cannot read file"}}, got)
+}
+
+func TestList_Sort(t *testing.T) {
+	l := append(List(nil), sample...)
+	l.Sort()
+	assert.Equal(t, []string{"", "a.yaml", "b.yaml"}, []string{l[0].File, l[1].File, l[2].File})
+}
+
+func TestLocateAndLookup(t *testing.T) {
+	var root yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte(`paths:
+  /users/{id}:
+    get:
+      parameters:
+        - name: id
+          in: path
+      responses: {}
+`), &root))
+
+	assert.Equal(t, "/paths/~1users~1{id}/get/parameters/0/in", Locate(&root, 6, 15))
+	assert.Equal(t, "/paths/~1users~1{id}/get/parameters/0/in", Locate(&root, 6, 11), "a key designates its value")
+	assert.Equal(t, "/paths/~1users~1{id}/get/responses", Locate(&root, 7, 18))
+	assert.Equal(t, "", Locate(&root, 0, 0))
+
+	node := Lookup(&root, Pointer("paths", "/users/{id}", "get", "parameters", "0", "name"))
+	require.NotNil(t, node)
+	assert.Equal(t, "id", node.Value)
+	assert.Equal(t, 5, node.Line)
+	assert.Nil(t, Lookup(&root, "/paths/missing"))
+	assert.Nil(t, Lookup(&root, "paths"))
+}
+
+func TestWrite(t *testing.T) {
+	var text bytes.Buffer
+	require.NoError(t, Write(&text, FormatText, "mcpgen", sample))
+	assert.Equal(t, "b.yaml: warning: odd name\na.yaml:7:11: error: bad type (at /definitions/User/type)\ninfo: no file\n", text.String())
+
+	var out bytes.Buffer
+	require.NoError(t, Write(&out, FormatJSON, "mcpgen", sample))
+	var decoded List
+	require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+	assert.Equal(t, sample, decoded)
+
+	out.Reset()
+	require.NoError(t, Write(&out, FormatJSON, "mcpgen", nil))
+	assert.Equal(t, "[]\n", out.String())
+
+	out.Reset()
+	require.NoError(t, Write(&out, FormatSARIF, "mcpgen", sample))
+	var log struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Name  string              `json:"name"`
+					Rules []map[string]string `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			Results []map[string]interface{} `json:"results"`
+		} `json:"runs"`
+	}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &log))
+	assert.Equal(t, "2.1.0", log.Version)
+	require.Len(t, log.Runs, 1)
+	run := log.Runs[0]
+	assert.Equal(t, "mcpgen", run.Tool.Driver.Name)
+	assert.Equal(t, []map[string]string{{"id": "naming"}, {"id": "schema-type"}}, run.Tool.Driver.Rules)
+	require.Len(t, run.Results, 3)
+	assert.Equal(t, "note", run.Results[2]["level"])
+	assert.NotContains(t, run.Results[2], "locations")
+	location := run.Results[1]["locations"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{
+		"artifactLocation": map[string]interface{}{"uri": "a.yaml"},
+		"region":           map[string]interface{}{"startLine": 7.0, "startColumn": 11.0},
+	}, location["physicalLocation"])
+	assert.Equal(t, "/definitions/User/type", location["logicalLocations"].([]interface{})[0].(map[string]interface{})["fullyQualifiedName"])
+}
+
+func TestParseFormat(t *testing.T) {
+	f, err := ParseFormat("sarif")
+	require.NoError(t, err)
+	assert.Equal(t, FormatSARIF, f)
+	_, err = ParseFormat("xml")
+	assert.EqualError(t, err, `unsupported diagnostics format "xml", expected text, json or sarif`)
+}
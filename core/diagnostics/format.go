@@ -0,0 +1,157 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Format is an output format of diagnostics.
+type Format string
+
+const (
+	// FormatText writes one diagnostic per line, as Diagnostic.String does.
+	FormatText Format = "text"
+	// FormatJSON writes a JSON array of diagnostics.
+	FormatJSON Format = "json"
+	// FormatSARIF writes a SARIF 2.1.0 log, as read by code scanning tools.
+	FormatSARIF Format = "sarif"
+)
+
+// ParseFormat returns the format named s.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatText, FormatJSON, FormatSARIF:
+		return f, nil
+	}
+	return "", fmt.Errorf("unsupported diagnostics format %q, expected text, json or sarif", s)
+}
+
+// Write writes l to w in format; tool names the reporting program in SARIF
+// logs.
+func Write(w io.Writer, format Format, tool string, l List) error {
+	switch format {
+	case FormatJSON:
+		if l == nil {
+			l = List{}
+		}
+		return writeJSON(w, l)
+	case FormatSARIF:
+		return writeJSON(w, sarifLog(tool, l))
+	default:
+		for _, d := range l {
+			if _, err := fmt.Fprintln(w, d.String()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver struct {
+		Name  string      `json:"name"`
+		Rules []sarifRule `json:"rules,omitempty"`
+	} `json:"driver"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID  string `json:"ruleId,omitempty"`
+	Level   string `json:"level"`
+	Message struct {
+		Text string `json:"text"`
+	} `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation,omitempty"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation struct {
+		URI string `json:"uri"`
+	} `json:"artifactLocation"`
+	Region *sarifRegion `json:"region,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+func sarifLog(tool string, l List) interface{} {
+	run := sarifRun{Results: []sarifResult{}}
+	run.Tool.Driver.Name = tool
+	seen := map[string]bool{}
+	for _, d := range l {
+		if d.Rule != "" && !seen[d.Rule] {
+			seen[d.Rule] = true
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: d.Rule})
+		}
+		r := sarifResult{RuleID: d.Rule, Level: sarifLevel(d.Severity)}
+		r.Message.Text = d.Message
+		var loc sarifLocation
+		if d.File != "" {
+			loc.PhysicalLocation = &sarifPhysicalLocation{}
+			loc.PhysicalLocation.ArtifactLocation.URI = sarifURI(d.File)
+			if d.Line > 0 {
+				loc.PhysicalLocation.Region = &sarifRegion{StartLine: d.Line, StartColumn: d.Column}
+			}
+		}
+		if d.Pointer != "" {
+			loc.LogicalLocations = []sarifLogicalLocation{{FullyQualifiedName: d.Pointer, Kind: "object"}}
+		}
+		if loc.PhysicalLocation != nil || loc.LogicalLocations != nil {
+			r.Locations = []sarifLocation{loc}
+		}
+		run.Results = append(run.Results, r)
+	}
+	return map[string]interface{}{
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"version": "2.1.0",
+		"runs":    []sarifRun{run},
+	}
+}
+
+func sarifLevel(s Severity) string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	}
+	return "note"
+}
+
+// sarifURI turns a file path into the relative URI SARIF expects; URLs are
+// kept as they are.
+func sarifURI(file string) string {
+	if strings.Contains(file, "://") {
+		return file
+	}
+	return filepath.ToSlash(file)
+}
@@ -0,0 +1,112 @@
+package diagnostics
+
+import (
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Pointer joins tokens into a JSON pointer, escaping "~" and "/".
+func Pointer(tokens ...string) string {
+	var b strings.Builder
+	for _, t := range tokens {
+		b.WriteByte('/')
+		b.WriteString(strings.ReplaceAll(strings.ReplaceAll(t, "~", "~0"), "/", "~1"))
+	}
+	return b.String()
+}
+
+// Lookup returns the node pointer designates in the document root, or nil.
+func Lookup(root *yaml.Node, pointer string) *yaml.Node {
+	node := documentContent(root)
+	if pointer == "" {
+		return node
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil
+	}
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		node = child(node, token)
+		if node == nil {
+			return nil
+		}
+	}
+	return node
+}
+
+// Locate returns the JSON pointer of the value of root starting at line and
+// column, or else of the innermost value starting before. A mapping starts
+// where its first key does; the position designates the mapping itself.
+func Locate(root *yaml.Node, line, column int) string {
+	best, found := "", false
+	var walk func(node *yaml.Node, pointer string)
+	walk = func(node *yaml.Node, pointer string) {
+		if node == nil || found || after(node, line, column) {
+			return
+		}
+		best = pointer
+		if node.Line == line && node.Column == column {
+			found = true
+			return
+		}
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if found || after(node.Content[i], line, column) {
+					return
+				}
+				// A key designates its value.
+				best = pointer + Pointer(node.Content[i].Value)
+				walk(node.Content[i+1], best)
+			}
+		case yaml.SequenceNode:
+			for i, item := range node.Content {
+				if after(item, line, column) {
+					return
+				}
+				walk(item, pointer+Pointer(strconv.Itoa(i)))
+			}
+		}
+	}
+	walk(documentContent(root), "")
+	return best
+}
+
+// At sets the line and column of d to those of node.
+func (d Diagnostic) At(node *yaml.Node) Diagnostic {
+	if node != nil {
+		d.Line, d.Column = node.Line, node.Column
+	}
+	return d
+}
+
+func after(node *yaml.Node, line, column int) bool {
+	return node.Line > line || node.Line == line && node.Column > column
+}
+
+func documentContent(node *yaml.Node) *yaml.Node {
+	if node != nil && node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		return node.Content[0]
+	}
+	return node
+}
+
+func child(node *yaml.Node, token string) *yaml.Node {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == token {
+				return node.Content[i+1]
+			}
+		}
+	case yaml.SequenceNode:
+		if i, err := strconv.Atoi(token); err == nil && i >= 0 && i < len(node.Content) {
+			return node.Content[i]
+		}
+	case yaml.AliasNode:
+		return child(node.Alias, token)
+	}
+	return nil
+}
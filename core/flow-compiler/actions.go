@@ -0,0 +1,128 @@
+package flowcompiler
+
+import (
+	"fmt"
+	"strings"
+
+	runtimeexpr "MCPGen/core/flow-compiler/runtime-expr"
+)
+
+const (
+	successActionsPrefix = "$components.successActions."
+	failureActionsPrefix = "$components.failureActions."
+)
+
+// resolveActions returns the success and failure actions of step. Actions of
+// the step come first, followed by the workflow-level actions whose name the
+// step does not override. References to components are replaced by the
+// actions they name, and goto and retry targets are checked.
+func (r *resolver) resolveActions(flow *FlowDefinition, step *FlowStep) ([]Action, []Action, error) {
+	var components Components
+	if flow.Components != nil {
+		components = *flow.Components
+	}
+	onSuccess, err := r.actionList(flow, step, "onSuccess", step.OnSuccess, flow.SuccessActions, successActionsPrefix, components.SuccessActions)
+	if err != nil {
+		return nil, nil, err
+	}
+	onFailure, err := r.actionList(flow, step, "onFailure", step.OnFailure, flow.FailureActions, failureActionsPrefix, components.FailureActions)
+	if err != nil {
+		return nil, nil, err
+	}
+	return onSuccess, onFailure, nil
+}
+
+func (r *resolver) actionList(flow *FlowDefinition, step *FlowStep, field string, stepActions, flowActions []Action, prefix string, components map[string]Action) ([]Action, error) {
+	fail := func(format string, args ...interface{}) ([]Action, error) {
+		return nil, &ReferenceError{
+			Location:   step.Location,
+			WorkflowID: flow.WorkflowID,
+			StepID:     step.ID,
+			Message:    field + ": " + fmt.Sprintf(format, args...),
+		}
+	}
+
+	var out []Action
+	overridden := map[string]bool{}
+	for i, list := range [][]Action{stepActions, flowActions} {
+		for _, a := range list {
+			if a.Reference != "" {
+				name, ok := strings.CutPrefix(a.Reference, prefix)
+				if !ok {
+					return fail("reference '%s' must start with %s", a.Reference, prefix)
+				}
+				def, ok := components[name]
+				if !ok {
+					return fail("reference '%s' not found in components", a.Reference)
+				}
+				a = def
+			}
+			if i == 0 {
+				overridden[a.Name] = true
+			} else if overridden[a.Name] {
+				continue
+			}
+			resolved, err := r.resolveAction(flow, field, a)
+			if err != nil {
+				return fail("action '%s': %v", a.Name, err)
+			}
+			out = append(out, resolved)
+		}
+	}
+	return out, nil
+}
+
+// resolveAction checks a single action and replaces a qualified workflowId by
+// the ID of the workflow it resolves to.
+func (r *resolver) resolveAction(flow *FlowDefinition, field string, a Action) (Action, error) {
+	switch a.Type {
+	case "end":
+	case "goto":
+		if (a.StepID == "") == (a.WorkflowID == "") {
+			return a, fmt.Errorf("goto needs exactly one of stepId or workflowId")
+		}
+	case "retry":
+		if field != "onFailure" {
+			return a, fmt.Errorf("retry is only allowed on failure")
+		}
+		if a.StepID != "" && a.WorkflowID != "" {
+			return a, fmt.Errorf("stepId and workflowId are mutually exclusive")
+		}
+		if a.RetryAfter != nil && *a.RetryAfter < 0 {
+			return a, fmt.Errorf("retryAfter must not be negative")
+		}
+		if a.RetryLimit != nil && *a.RetryLimit < 0 {
+			return a, fmt.Errorf("retryLimit must not be negative")
+		}
+	default:
+		return a, fmt.Errorf("unknown type '%s'", a.Type)
+	}
+
+	if a.StepID != "" && !hasStep(flow, a.StepID) {
+		return a, fmt.Errorf("step '%s' does not exist", a.StepID)
+	}
+	if a.WorkflowID != "" {
+		target, err := r.lookupWorkflow(flow, a.WorkflowID)
+		if err != nil {
+			return a, err
+		}
+		a.WorkflowID = target.WorkflowID
+	}
+	for _, c := range a.Criteria {
+		rc := runtimeexpr.Criterion{Context: c.Context, Condition: c.Condition, Type: c.Type, Version: c.Version}
+		if err := rc.Validate(); err != nil {
+			return a, fmt.Errorf("invalid criteria: %v", err)
+		}
+	}
+	a.Reference = ""
+	return a, nil
+}
+
+func hasStep(flow *FlowDefinition, id string) bool {
+	for _, s := range flow.Steps {
+		if s.ID == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,214 @@
+package flowcompiler
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// stepReferencePattern finds "$steps.<stepId>" in runtime expressions, also
+// when they are embedded in strings as "{$steps.<stepId>.outputs.x}".
+var stepReferencePattern = regexp.MustCompile(`\$steps\.([A-Za-z0-9_\-]+)`)
+
+// buildGraph computes the dependencies and topological levels of the steps of
+// flow, whose compiled steps are given in document order. A step depends on the
+// steps its expressions reference ($steps.X...), on its explicit DependsOn
+// steps and on every step listing it in Next. A step with none of these keeps
+// the Arazzo default and follows the step listed before it; an explicitly
+// empty DependsOn makes it a root instead.
+func buildGraph(flow *FlowDefinition, steps []CompiledStep) ([][]string, error) {
+	index := make(map[string]int, len(flow.Steps))
+	for i, step := range flow.Steps {
+		index[step.ID] = i
+	}
+	fail := func(step *FlowStep, format string, args ...interface{}) error {
+		return &ReferenceError{
+			Location:   step.Location,
+			WorkflowID: flow.WorkflowID,
+			StepID:     step.ID,
+			Message:    fmt.Sprintf(format, args...),
+		}
+	}
+
+	deps := make([][]int, len(flow.Steps))
+	add := func(to, from int) {
+		for _, d := range deps[to] {
+			if d == from {
+				return
+			}
+		}
+		deps[to] = append(deps[to], from)
+	}
+	for i := range flow.Steps {
+		step := &flow.Steps[i]
+		for _, id := range stepReferences(step) {
+			j, ok := index[id]
+			if !ok {
+				return nil, fail(step, "references unknown step '%s'", id)
+			}
+			if j == i {
+				continue // a step may refer to its own outputs
+			}
+			add(i, j)
+		}
+		for _, id := range step.DependsOn {
+			j, ok := index[id]
+			if !ok {
+				return nil, fail(step, "depends on unknown step '%s'", id)
+			}
+			add(i, j)
+		}
+		for _, id := range step.Next {
+			j, ok := index[id]
+			if !ok {
+				return nil, fail(step, "next step '%s' does not exist", id)
+			}
+			add(j, i)
+		}
+	}
+	for i := 1; i < len(flow.Steps); i++ {
+		if len(deps[i]) == 0 && flow.Steps[i].DependsOn == nil {
+			deps[i] = []int{i - 1}
+		}
+	}
+
+	if cycle := findCycle(deps); cycle != nil {
+		var ids []string
+		for _, i := range cycle {
+			ids = append(ids, flow.Steps[i].ID)
+		}
+		return nil, fail(&flow.Steps[cycle[0]], "dependency cycle %s", strings.Join(ids, " -> "))
+	}
+
+	level := make([]int, len(flow.Steps))
+	var levelOf func(i int) int
+	levelOf = func(i int) int {
+		if level[i] > 0 {
+			return level[i] - 1
+		}
+		l := 0
+		for _, d := range deps[i] {
+			if dl := levelOf(d) + 1; dl > l {
+				l = dl
+			}
+		}
+		level[i] = l + 1
+		return l
+	}
+	var levels [][]string
+	for i := range flow.Steps {
+		l := levelOf(i)
+		for len(levels) <= l {
+			levels = append(levels, nil)
+		}
+		levels[l] = append(levels[l], flow.Steps[i].ID)
+		steps[i].Level = l
+		for _, d := range deps[i] {
+			steps[i].DependsOn = append(steps[i].DependsOn, flow.Steps[d].ID)
+		}
+	}
+	for i := range steps {
+		steps[i].Parallel = len(levels[steps[i].Level]) > 1
+	}
+	return levels, nil
+}
+
+// findCycle returns the step indexes of a dependency cycle, first step repeated
+// at the end, or nil when the graph is acyclic.
+func findCycle(deps [][]int) []int {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make([]int, len(deps))
+	var stack []int
+	var visit func(i int) []int
+	visit = func(i int) []int {
+		switch state[i] {
+		case visiting:
+			for k, s := range stack {
+				if s == i {
+					return append(append([]int{}, stack[k:]...), i)
+				}
+			}
+		case done:
+			return nil
+		}
+		state[i] = visiting
+		stack = append(stack, i)
+		for _, d := range deps[i] {
+			if cycle := visit(d); cycle != nil {
+				return cycle
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[i] = done
+		return nil
+	}
+	for i := range deps {
+		if cycle := visit(i); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+// stepReferences returns the IDs of the steps referenced by the runtime
+// expressions of step, in order of appearance.
+func stepReferences(step *FlowStep) []string {
+	var exprs []string
+	for _, p := range step.Parameters {
+		exprs = collectStrings(exprs, p.Value)
+	}
+	if step.RequestBody != nil {
+		exprs = collectStrings(exprs, step.RequestBody.Payload)
+		for _, r := range step.RequestBody.Replacements {
+			exprs = collectStrings(exprs, r.Value)
+		}
+	}
+	for _, c := range step.SuccessCriteria {
+		exprs = append(exprs, c.Context, c.Condition)
+	}
+	for _, name := range sortedKeys(step.Outputs) {
+		exprs = append(exprs, step.Outputs[name])
+	}
+
+	var ids []string
+	seen := map[string]bool{}
+	for _, expr := range exprs {
+		for _, m := range stepReferencePattern.FindAllStringSubmatch(expr, -1) {
+			if !seen[m[1]] {
+				seen[m[1]] = true
+				ids = append(ids, m[1])
+			}
+		}
+	}
+	return ids
+}
+
+// collectStrings appends every string found in a decoded YAML value.
+func collectStrings(out []string, value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		out = append(out, v)
+	case []interface{}:
+		for _, item := range v {
+			out = collectStrings(out, item)
+		}
+	case map[string]interface{}:
+		for _, k := range sortedKeys(v) {
+			out = collectStrings(out, v[k])
+		}
+	}
+	return out
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
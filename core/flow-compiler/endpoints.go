@@ -0,0 +1,82 @@
+package flowcompiler
+
+import (
+	"MCPGen/core/openapi-loader"
+	"sort"
+)
+
+// EndpointsFromSpec converts the operations of a loaded spec into compiler endpoints.
+// Schema references are rewritten so each endpoint carries the definitions it uses.
+func EndpointsFromSpec(spec *openapiloader.UnifiedAPISpec) []Endpoint {
+	var endpoints []Endpoint
+	baseURL := ""
+	if len(spec.Servers) > 0 {
+		baseURL = spec.Servers[0]
+	}
+	for _, ep := range spec.Endpoints {
+		bundler := newSchemaBundler(spec.Schemas)
+		endpoint := Endpoint{
+			ID:          ep.Operation,
+			Path:        ep.Path,
+			Method:      ep.Method,
+			Summary:     ep.Summary,
+			Description: ep.Description,
+			BaseURL:     baseURL,
+			Source:      spec.Source,
+			Responses:   make(map[string]Response, len(ep.Responses)),
+		}
+		if ep.RequestBody != nil {
+			endpoint.RequestBody = &RequestBody{
+				Required:    ep.RequestBody.Required,
+				ContentType: ep.RequestBody.ContentType,
+				Schema:      bundler.rewrite(ep.RequestBody.Schema),
+			}
+		}
+		for _, p := range ep.Parameters {
+			endpoint.Parameters = append(endpoint.Parameters, Parameter{
+				Name:     p.Name,
+				In:       p.In,
+				Required: p.Required,
+				Schema:   bundler.rewrite(p.Schema),
+			})
+		}
+		for code, resp := range ep.Responses {
+			endpoint.Responses[code] = Response{Code: resp.Code, Schema: bundler.rewrite(resp.Schema)}
+		}
+		endpoint.Definitions = bundler.definitions()
+		endpoint.Security = securityOf(ep.Security, spec.Security)
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints
+}
+
+// securityOf joins the security requirements of an operation with the schemes
+// they name.
+func securityOf(reqs []map[string][]string, schemes map[string]openapiloader.SecurityScheme) [][]SecurityScheme {
+	var out [][]SecurityScheme
+	for _, req := range reqs {
+		names := make([]string, 0, len(req))
+		for name := range req {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		alternative := []SecurityScheme{}
+		for _, name := range names {
+			def := schemes[name]
+			scheme := SecurityScheme{
+				Name:      name,
+				Type:      def.Type,
+				In:        def.In,
+				ParamName: def.Name,
+				Scheme:    def.Scheme,
+				Scopes:    req[name],
+			}
+			if def.ClientCredentials != nil {
+				scheme.TokenURL = def.ClientCredentials.TokenURL
+			}
+			alternative = append(alternative, scheme)
+		}
+		out = append(out, alternative)
+	}
+	return out
+}
@@ -0,0 +1,77 @@
+package flowcompiler
+
+import (
+	"MCPGen/core/openapi-loader"
+	"testing"
+)
+
+func TestEndpointsFromSpec_BundlesDefinitions(t *testing.T) {
+	spec := &openapiloader.UnifiedAPISpec{
+		Servers: []string{"https://api.example.com"},
+		Schemas: map[string]interface{}{
+			"Node": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"children": map[string]interface{}{
+						"type":  "array",
+						"items": map[string]interface{}{"$ref": "#/components/schemas/Node"},
+					},
+				},
+			},
+			"Unused": map[string]interface{}{"type": "string"},
+		},
+		Endpoints: []openapiloader.APIEndpoint{{
+			Path:        "/nodes",
+			Method:      "POST",
+			Operation:   "createNode",
+			RequestBody: &openapiloader.RequestBody{Required: true, ContentType: "application/json", Schema: map[string]interface{}{"$ref": "#/components/schemas/Node"}},
+		}},
+	}
+
+	endpoints := EndpointsFromSpec(spec)
+	if len(endpoints) != 1 {
+		t.Fatalf("Expected 1 endpoint, got %d", len(endpoints))
+	}
+	ep := endpoints[0]
+	if ep.ID != "createNode" || ep.BaseURL != "https://api.example.com" {
+		t.Errorf("Unexpected endpoint %+v", ep)
+	}
+	body := ep.RequestBody.Schema.(map[string]interface{})
+	if body["$ref"] != "#/$defs/Node" {
+		t.Errorf("Expected body ref to be rewritten, got %v", body["$ref"])
+	}
+	if len(ep.Definitions) != 1 {
+		t.Fatalf("Expected only the referenced definition, got %v", ep.Definitions)
+	}
+	node := ep.Definitions["Node"].(map[string]interface{})
+	items := node["properties"].(map[string]interface{})["children"].(map[string]interface{})["items"].(map[string]interface{})
+	if items["$ref"] != "#/$defs/Node" {
+		t.Errorf("Expected recursive ref to be rewritten, got %v", items["$ref"])
+	}
+}
+
+func TestEndpointsFromSpec_Security(t *testing.T) {
+	spec := &openapiloader.UnifiedAPISpec{
+		Security: map[string]openapiloader.SecurityScheme{
+			"api_key": {Type: "apiKey", Name: "X-API-Key", In: "header"},
+			"oauth":   {Type: "oauth2", ClientCredentials: &openapiloader.OAuthFlow{TokenURL: "https://auth.example.com/token"}},
+		},
+		Endpoints: []openapiloader.APIEndpoint{{
+			Path:     "/reports",
+			Method:   "GET",
+			Security: []map[string][]string{{"oauth": {"reports:read"}, "api_key": {}}, {}},
+		}},
+	}
+
+	security := EndpointsFromSpec(spec)[0].Security
+	if len(security) != 2 || len(security[0]) != 2 || len(security[1]) != 0 {
+		t.Fatalf("Unexpected security %+v", security)
+	}
+	key, oauth := security[0][0], security[0][1]
+	if key.Name != "api_key" || key.Type != "apiKey" || key.In != "header" || key.ParamName != "X-API-Key" {
+		t.Errorf("Unexpected apiKey scheme %+v", key)
+	}
+	if oauth.TokenURL != "https://auth.example.com/token" || len(oauth.Scopes) != 1 || oauth.Scopes[0] != "reports:read" {
+		t.Errorf("Unexpected oauth2 scheme %+v", oauth)
+	}
+}
@@ -0,0 +1,144 @@
+package flowcompiler
+
+import (
+	"fmt"
+	"strings"
+
+	runtimeexpr "MCPGen/core/flow-compiler/runtime-expr"
+)
+
+const parametersPrefix = "$components.parameters."
+
+// resolveParameters returns the parameters of step: the workflow parameters
+// the step does not override by name and location, followed by those of the
+// step. References to components are replaced by the parameter they name,
+// keeping the value of the reference when it sets one.
+func resolveParameters(flow *FlowDefinition, step *FlowStep) ([]StepParameter, error) {
+	fail := func(format string, args ...interface{}) ([]StepParameter, error) {
+		return nil, &ReferenceError{
+			Location:   step.Location,
+			WorkflowID: flow.WorkflowID,
+			StepID:     step.ID,
+			Message:    fmt.Sprintf(format, args...),
+		}
+	}
+	resolve := func(p StepParameter) (StepParameter, error) {
+		if p.Reference == "" {
+			return p, nil
+		}
+		name, ok := strings.CutPrefix(p.Reference, parametersPrefix)
+		if !ok {
+			return p, fmt.Errorf("parameter reference '%s' must start with %s", p.Reference, parametersPrefix)
+		}
+		var def StepParameter
+		var found bool
+		if flow.Components != nil {
+			def, found = flow.Components.Parameters[name]
+		}
+		if !found {
+			return p, fmt.Errorf("parameter reference '%s' not found in components", p.Reference)
+		}
+		if p.Value != nil {
+			def.Value = p.Value
+		}
+		return def, nil
+	}
+
+	var stepParams []StepParameter
+	overridden := map[string]bool{}
+	for _, p := range step.Parameters {
+		resolved, err := resolve(p)
+		if err != nil {
+			return fail("%v", err)
+		}
+		overridden[resolved.In+"/"+resolved.Name] = true
+		stepParams = append(stepParams, resolved)
+	}
+	var out []StepParameter
+	for _, p := range flow.Parameters {
+		resolved, err := resolve(p)
+		if err != nil {
+			return fail("%v", err)
+		}
+		if !overridden[resolved.In+"/"+resolved.Name] {
+			out = append(out, resolved)
+		}
+	}
+	return append(out, stepParams...), nil
+}
+
+// validateExpressions checks the runtime expressions of the parameters,
+// request body and outputs of a step. References to other steps must name an
+// output the step declares, and references to inputs an input of the workflow
+// when its inputs schema lists properties.
+func validateExpressions(flow *FlowDefinition, step *FlowStep, params []StepParameter) error {
+	var exprs []string
+	for _, p := range params {
+		exprs = append(exprs, runtimeexpr.Expressions(p.Value)...)
+	}
+	if step.RequestBody != nil {
+		exprs = append(exprs, runtimeexpr.Expressions(step.RequestBody.Payload)...)
+		for _, r := range step.RequestBody.Replacements {
+			exprs = append(exprs, runtimeexpr.Expressions(r.Value)...)
+		}
+	}
+	for _, name := range sortedKeys(step.Outputs) {
+		exprs = append(exprs, step.Outputs[name])
+	}
+	for _, expr := range exprs {
+		if err := checkExpression(flow, expr, false); err != nil {
+			return &ReferenceError{
+				Location:   step.Location,
+				WorkflowID: flow.WorkflowID,
+				StepID:     step.ID,
+				Message:    err.Error(),
+			}
+		}
+	}
+	return nil
+}
+
+// validateFlowOutputs checks the runtime expressions of the workflow outputs.
+func validateFlowOutputs(flow *FlowDefinition) error {
+	for _, name := range sortedKeys(flow.Outputs) {
+		if err := checkExpression(flow, flow.Outputs[name], true); err != nil {
+			return &ReferenceError{
+				Location:   Location{File: flow.SourceFile},
+				WorkflowID: flow.WorkflowID,
+				Message:    fmt.Sprintf("output '%s': %v", name, err),
+			}
+		}
+	}
+	return nil
+}
+
+// checkExpression validates a single expression. Unknown step IDs are already
+// reported by the dependency graph for step expressions, so they are only
+// checked here for workflow outputs.
+func checkExpression(flow *FlowDefinition, expr string, checkStep bool) error {
+	if err := runtimeexpr.ValidateExpression(expr); err != nil {
+		return fmt.Errorf("invalid runtime expression: %v", err)
+	}
+	if id, output, ok := runtimeexpr.StepOutput(expr); ok {
+		for _, s := range flow.Steps {
+			if s.ID != id {
+				continue
+			}
+			if _, declared := s.Outputs[output]; !declared {
+				return fmt.Errorf("'%s' refers to output '%s' which step '%s' does not declare", expr, output, id)
+			}
+			return nil
+		}
+		if checkStep {
+			return fmt.Errorf("'%s' references unknown step '%s'", expr, id)
+		}
+	}
+	if name, ok := runtimeexpr.InputName(expr); ok {
+		if props, ok := flow.Inputs["properties"].(map[string]interface{}); ok {
+			if _, declared := props[name]; !declared {
+				return fmt.Errorf("'%s' refers to input '%s' which the workflow does not declare", expr, name)
+			}
+		}
+	}
+	return nil
+}
@@ -1,7 +1,11 @@
 package flowcompiler
 
 import (
+	"errors"
 	"fmt"
+	"strings"
+
+	runtimeexpr "MCPGen/core/flow-compiler/runtime-expr"
 )
 
 // FlowCompiler merges endpoints and workflows into executable flows.
@@ -18,31 +22,179 @@ func NewFlowCompiler(endpoints []Endpoint, flows []FlowDefinition) *FlowCompiler
 	}
 }
 
-// Compile merges endpoints and flows into CompiledFlow objects.
+// Compile merges endpoints and flows into CompiledFlow objects. Each step is
+// resolved by operationId, operationPath or workflowId across every loaded
+// spec, and the steps are ordered into a dependency graph. All unresolved or
+// ambiguous references and dependency cycles are reported together.
 func (fc *FlowCompiler) Compile() ([]*CompiledFlow, error) {
 	var compiledFlows []*CompiledFlow
-	endpointMap := make(map[string]*Endpoint)
-	for i, ep := range fc.Endpoints {
-		endpointMap[ep.ID] = &fc.Endpoints[i]
-	}
+	var errs []error
+	r := newResolver(fc.Endpoints, fc.Flows)
+	errs = append(errs, duplicateWorkflows(r.flows)...)
 
-	for _, flow := range fc.Flows {
+	for _, flow := range r.flows {
 		cf := &CompiledFlow{
 			WorkflowID: flow.WorkflowID,
+			SourceFile: flow.SourceFile,
+			Outputs:    flow.Outputs,
+		}
+		if err := validateFlowOutputs(flow); err != nil {
+			errs = append(errs, err)
 		}
-		for _, step := range flow.Steps {
-			endpoint, ok := endpointMap[step.Call]
-			if !ok {
-				return nil, fmt.Errorf("endpoint with ID '%s' not found for step '%s' in workflow '%s'", step.Call, step.ID, flow.WorkflowID)
+		inputs, err := inputSchema(flow)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		cf.Inputs = inputs
+		for i := range flow.Steps {
+			step := &flow.Steps[i]
+			endpoint, nested, err := r.resolveStep(flow, step)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if err := validateCriteria(flow, step); err != nil {
+				errs = append(errs, err)
 			}
-			cf.Steps = append(cf.Steps, CompiledStep{
-				StepID:   step.ID,
-				Endpoint: endpoint,
-				PreHook:  step.PreHook,
-				PostHook: step.PostHook,
-			})
+			onSuccess, onFailure, err := r.resolveActions(flow, step)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			params, err := resolveParameters(flow, step)
+			if err != nil {
+				errs = append(errs, err)
+			} else if err := validateExpressions(flow, step, params); err != nil {
+				errs = append(errs, err)
+			}
+			compiled := CompiledStep{
+				StepID:          step.ID,
+				Endpoint:        endpoint,
+				SuccessCriteria: step.SuccessCriteria,
+				OnSuccess:       onSuccess,
+				OnFailure:       onFailure,
+				Parameters:      params,
+				RequestBody:     step.RequestBody,
+				Outputs:         step.Outputs,
+				PreHook:         step.PreHook,
+				PostHook:        step.PostHook,
+			}
+			if nested != nil {
+				compiled.WorkflowID = nested.WorkflowID
+			}
+			cf.Steps = append(cf.Steps, compiled)
+		}
+		if len(cf.Steps) == len(flow.Steps) {
+			levels, err := buildGraph(flow, cf.Steps)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			cf.Levels = levels
 		}
 		compiledFlows = append(compiledFlows, cf)
 	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	if err := checkNestedCycles(compiledFlows); err != nil {
+		return nil, err
+	}
 	return compiledFlows, nil
 }
+
+// duplicateWorkflows reports workflowIds defined by more than one Arazzo
+// document. The generated server names its tools, /run-task routes and nested
+// workflows by workflowId alone, so they must be unique across documents.
+func duplicateWorkflows(flows []*FlowDefinition) []error {
+	var errs []error
+	first := map[string]*FlowDefinition{}
+	for _, flow := range flows {
+		prev, ok := first[flow.WorkflowID]
+		if !ok {
+			first[flow.WorkflowID] = flow
+			continue
+		}
+		loc := flow.Location
+		if loc.File == "" {
+			loc.File = flow.SourceFile
+		}
+		errs = append(errs, &ReferenceError{
+			Location:   loc,
+			WorkflowID: flow.WorkflowID,
+			Message:    fmt.Sprintf("workflowId is already defined in %s; workflowIds must be unique across Arazzo files", prev.SourceFile),
+		})
+	}
+	return errs
+}
+
+// validateCriteria checks that the success criteria of a step parse, so that
+// a typo fails at generation time rather than on the first call.
+func validateCriteria(flow *FlowDefinition, step *FlowStep) error {
+	for _, c := range step.SuccessCriteria {
+		rc := runtimeexpr.Criterion{Context: c.Context, Condition: c.Condition, Type: c.Type, Version: c.Version}
+		if err := rc.Validate(); err != nil {
+			return &ReferenceError{
+				Location:   step.Location,
+				WorkflowID: flow.WorkflowID,
+				StepID:     step.ID,
+				Message:    fmt.Sprintf("invalid successCriteria: %v", err),
+			}
+		}
+	}
+	return nil
+}
+
+// workflowTargets returns the workflows the step may run: its nested workflow
+// and those named by its actions.
+func (s *CompiledStep) workflowTargets() []string {
+	var out []string
+	if s.WorkflowID != "" {
+		out = append(out, s.WorkflowID)
+	}
+	for _, a := range append(append([]Action{}, s.OnSuccess...), s.OnFailure...) {
+		if a.WorkflowID != "" {
+			out = append(out, a.WorkflowID)
+		}
+	}
+	return out
+}
+
+// checkNestedCycles rejects workflows that end up running themselves through
+// nested workflow steps or the workflows their actions transfer to.
+func checkNestedCycles(flows []*CompiledFlow) error {
+	byID := make(map[string]*CompiledFlow, len(flows))
+	for _, f := range flows {
+		byID[f.WorkflowID] = f
+	}
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := map[string]int{}
+	var visit func(id string, path []string) error
+	visit = func(id string, path []string) error {
+		switch state[id] {
+		case visiting:
+			return fmt.Errorf("workflow cycle through nested workflows: %s", strings.Join(append(path, id), " -> "))
+		case done:
+			return nil
+		}
+		state[id] = visiting
+		if f := byID[id]; f != nil {
+			for _, step := range f.Steps {
+				for _, target := range step.workflowTargets() {
+					if err := visit(target, append(path, id)); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		state[id] = done
+		return nil
+	}
+	for _, f := range flows {
+		if err := visit(f.WorkflowID, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
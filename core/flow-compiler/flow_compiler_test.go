@@ -1,39 +1,402 @@
 package flowcompiler
 
 import (
+	"reflect"
+	"strings"
 	"testing"
 )
 
-func TestFlowCompiler_Compile(t *testing.T) {
-	endpoints := []Endpoint{
-		{ID: "getUser", Path: "/user", Method: "GET"},
-		{ID: "syncData", Path: "/sync", Method: "POST"},
+// twoSpecEndpoints returns endpoints loaded from two specs that both define getItem.
+func twoSpecEndpoints() []Endpoint {
+	return []Endpoint{
+		{ID: "getItem", Method: "GET", Path: "/items/{id}", Source: "specs/store.yaml"},
+		{ID: "listItems", Method: "GET", Path: "/items", Source: "specs/store.yaml"},
+		{ID: "getItem", Method: "GET", Path: "/stock/{id}", Source: "specs/inventory.yaml"},
 	}
+}
+
+func twoSourceFlow(steps ...FlowStep) FlowDefinition {
+	return FlowDefinition{
+		WorkflowID: "wf",
+		SourceFile: "workflows/flows.arazzo.yaml",
+		SourceDescriptions: []SourceDescription{
+			{Name: "store", URL: "../specs/store.yaml", Type: "openapi"},
+			{Name: "inventory", URL: "https://example.com/inventory.yaml", Type: "openapi"},
+		},
+		Steps: steps,
+	}
+}
+
+func TestCompile_ResolvesQualifiedReferences(t *testing.T) {
+	flow := twoSourceFlow(
+		FlowStep{ID: "store", Call: "$sourceDescriptions.store.getItem"},
+		FlowStep{ID: "stock", Call: "$sourceDescriptions.inventory.getItem"},
+		FlowStep{ID: "list", OperationPath: "{$sourceDescriptions.store.url}#/paths/~1items/get"},
+		FlowStep{ID: "unique", Call: "listItems"},
+	)
+	compiled, err := NewFlowCompiler(twoSpecEndpoints(), []FlowDefinition{flow}).Compile()
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	want := map[string]string{
+		"store":  "/items/{id}",
+		"stock":  "/stock/{id}",
+		"list":   "/items",
+		"unique": "/items",
+	}
+	for _, step := range compiled[0].Steps {
+		if step.Endpoint == nil || step.Endpoint.Path != want[step.StepID] {
+			t.Errorf("Step %s resolved to %+v, want path %s", step.StepID, step.Endpoint, want[step.StepID])
+		}
+	}
+}
+
+func TestCompile_ReportsAmbiguousAndUnresolvedReferences(t *testing.T) {
+	flow := twoSourceFlow(
+		FlowStep{ID: "ambiguous", Call: "getItem", Location: Location{File: "workflows/flows.arazzo.yaml", Line: 12, Column: 22}},
+		FlowStep{ID: "missing", Call: "deleteItem"},
+		FlowStep{ID: "unknownSource", Call: "$sourceDescriptions.billing.getInvoice"},
+		FlowStep{ID: "badPath", OperationPath: "{$sourceDescriptions.store.url}#/paths/~1items/delete"},
+	)
+	_, err := NewFlowCompiler(twoSpecEndpoints(), []FlowDefinition{flow}).Compile()
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	for _, want := range []string{
+		"workflows/flows.arazzo.yaml:12:22: step 'ambiguous' in workflow 'wf': operationId 'getItem' is ambiguous",
+		"specs/store.yaml, specs/inventory.yaml",
+		"step 'missing' in workflow 'wf': operationId 'deleteItem' not found",
+		"unknown source description 'billing'",
+		"operationPath '{$sourceDescriptions.store.url}#/paths/~1items/delete' not found",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to contain %q, got:\n%v", want, err)
+		}
+	}
+}
+
+func TestCompile_ResolvesNestedWorkflows(t *testing.T) {
+	endpoints := []Endpoint{{ID: "listItems", Method: "GET", Path: "/items"}}
+	flows := []FlowDefinition{
+		{WorkflowID: "outer", Steps: []FlowStep{{ID: "run", WorkflowID: "inner"}}},
+		{WorkflowID: "inner", Steps: []FlowStep{{ID: "list", Call: "listItems"}}},
+	}
+	compiled, err := NewFlowCompiler(endpoints, flows).Compile()
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	step := compiled[0].Steps[0]
+	if step.WorkflowID != "inner" || step.Endpoint != nil {
+		t.Errorf("Expected nested workflow step, got %+v", step)
+	}
+
+	flows[1].Steps = []FlowStep{{ID: "back", WorkflowID: "outer"}}
+	_, err = NewFlowCompiler(endpoints, flows).Compile()
+	if err == nil || !strings.Contains(err.Error(), "outer -> inner -> outer") {
+		t.Errorf("Expected workflow cycle error, got %v", err)
+	}
+}
+
+func TestCompile_RejectsDuplicateWorkflowIDs(t *testing.T) {
+	endpoints := []Endpoint{{ID: "listItems", Method: "GET", Path: "/items"}}
 	flows := []FlowDefinition{
+		{WorkflowID: "refresh", SourceFile: "workflows/a.arazzo.yaml", Steps: []FlowStep{{ID: "list", Call: "listItems"}}},
 		{
-			WorkflowID: "sync-user-data",
-			Steps: []FlowStep{
-				{ID: "step1", Call: "getUser", PreHook: "pre1.go", PostHook: "post1.go"},
-				{ID: "step2", Call: "syncData", PreHook: "pre2.go", PostHook: "post2.go"},
+			WorkflowID: "refresh", SourceFile: "workflows/b.arazzo.yaml",
+			Location: Location{File: "workflows/b.arazzo.yaml", Line: 4, Column: 17},
+			Steps:    []FlowStep{{ID: "list", Call: "listItems"}},
+		},
+	}
+	_, err := NewFlowCompiler(endpoints, flows).Compile()
+	want := "workflows/b.arazzo.yaml:4:17: workflow 'refresh': workflowId is already defined in workflows/a.arazzo.yaml"
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("Expected error containing %q, got %v", want, err)
+	}
+}
+
+func TestCompile_BuildsStepGraph(t *testing.T) {
+	endpoints := []Endpoint{{ID: "getUser"}, {ID: "getOrders"}, {ID: "getInvoices"}, {ID: "notify"}}
+	flow := FlowDefinition{WorkflowID: "report", Steps: []FlowStep{
+		{ID: "user", Call: "getUser", Outputs: map[string]string{"id": "$response.body#/id"}},
+		{ID: "orders", Call: "getOrders", Parameters: []StepParameter{{Name: "userId", In: "query", Value: "$steps.user.outputs.id"}}},
+		{ID: "invoices", Call: "getInvoices", RequestBody: &StepRequestBody{Payload: map[string]interface{}{"user": "{$steps.user.outputs.id}"}}, Outputs: map[string]string{"total": "$response.body#/total"}},
+		{ID: "notify", Call: "notify", DependsOn: []string{"orders"}, Outputs: map[string]string{"sent": "$steps.invoices.outputs.total"}},
+	}}
+	compiled, err := NewFlowCompiler(endpoints, []FlowDefinition{flow}).Compile()
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	got := compiled[0]
+	want := [][]string{{"user"}, {"orders", "invoices"}, {"notify"}}
+	if !reflect.DeepEqual(got.Levels, want) {
+		t.Errorf("Expected levels %v, got %v", want, got.Levels)
+	}
+	if !got.Steps[1].Parallel || !got.Steps[2].Parallel || got.Steps[0].Parallel {
+		t.Errorf("Expected only orders and invoices to be parallel: %+v", got.Steps)
+	}
+	if !reflect.DeepEqual(got.Steps[3].DependsOn, []string{"invoices", "orders"}) {
+		t.Errorf("Unexpected dependencies of notify: %v", got.Steps[3].DependsOn)
+	}
+}
+
+func TestCompile_SequentialByDefault(t *testing.T) {
+	endpoints := []Endpoint{{ID: "a"}, {ID: "b"}}
+	flow := FlowDefinition{WorkflowID: "seq", Steps: []FlowStep{
+		{ID: "a", Call: "a", PreHook: "pre1.go", PostHook: "post1.go"},
+		{ID: "b", Call: "b", PreHook: "pre2.go", PostHook: "post2.go"},
+	}}
+	compiled, err := NewFlowCompiler(endpoints, []FlowDefinition{flow}).Compile()
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	if want := [][]string{{"a"}, {"b"}}; !reflect.DeepEqual(compiled[0].Levels, want) {
+		t.Errorf("Expected levels %v, got %v", want, compiled[0].Levels)
+	}
+	for i, step := range compiled[0].Steps {
+		if step.PreHook != flow.Steps[i].PreHook || step.PostHook != flow.Steps[i].PostHook {
+			t.Errorf("Step %s hooks = %q, %q, want %q, %q", step.StepID, step.PreHook, step.PostHook, flow.Steps[i].PreHook, flow.Steps[i].PostHook)
+		}
+	}
+}
+
+func TestCompile_ReportsDependencyErrors(t *testing.T) {
+	endpoints := []Endpoint{{ID: "a"}, {ID: "b"}}
+	tests := map[string]struct {
+		steps []FlowStep
+		want  string
+	}{
+		"cycle": {
+			steps: []FlowStep{
+				{ID: "a", Call: "a", Next: []string{"b"}},
+				{ID: "b", Call: "b", Parameters: []StepParameter{{Name: "x", Value: "$steps.c.outputs.x"}}},
+				{ID: "c", Call: "a", DependsOn: []string{"b"}},
+			},
+			want: "dependency cycle b -> c -> b",
+		},
+		"unknown step": {
+			steps: []FlowStep{{ID: "a", Call: "a", Parameters: []StepParameter{{Name: "x", Value: "$steps.missing.outputs.x"}}}},
+			want:  "step 'a' in workflow 'wf': references unknown step 'missing'",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			flow := FlowDefinition{WorkflowID: "wf", Steps: tt.steps}
+			_, err := NewFlowCompiler(endpoints, []FlowDefinition{flow}).Compile()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestCompile_SuccessCriteria(t *testing.T) {
+	criteria := []Criterion{
+		{Condition: "$statusCode == 200"},
+		{Context: "$response.body", Condition: "$[?(@.status == 'ok')]", Type: "jsonpath"},
+	}
+	flow := twoSourceFlow(FlowStep{ID: "list", Call: "listItems", SuccessCriteria: criteria})
+	compiled, err := NewFlowCompiler(twoSpecEndpoints(), []FlowDefinition{flow}).Compile()
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	if !reflect.DeepEqual(compiled[0].Steps[0].SuccessCriteria, criteria) {
+		t.Errorf("Expected criteria %+v, got %+v", criteria, compiled[0].Steps[0].SuccessCriteria)
+	}
+
+	flow = twoSourceFlow(
+		FlowStep{ID: "syntax", Call: "listItems", SuccessCriteria: []Criterion{{Condition: "$statusCode =="}}},
+		FlowStep{ID: "regex", Call: "listItems", SuccessCriteria: []Criterion{{Condition: "^ok", Type: "regex"}}},
+	)
+	_, err = NewFlowCompiler(twoSpecEndpoints(), []FlowDefinition{flow}).Compile()
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	for _, want := range []string{
+		"step 'syntax' in workflow 'wf': invalid successCriteria",
+		"step 'regex' in workflow 'wf': invalid successCriteria: regex criterion \"^ok\" needs a context",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to contain %q, got:\n%v", want, err)
+		}
+	}
+}
+
+func TestCompile_ResolvesActions(t *testing.T) {
+	retryAfter, retryLimit := 1.5, 3
+	flow := twoSourceFlow(
+		FlowStep{
+			ID:        "list",
+			Call:      "listItems",
+			OnSuccess: []Action{{Name: "done", Type: "end", Criteria: []Criterion{{Condition: "$statusCode == 204"}}}},
+			OnFailure: []Action{{Reference: "$components.failureActions.retryLater"}},
+		},
+		FlowStep{ID: "store", Call: "$sourceDescriptions.store.getItem"},
+	)
+	flow.SuccessActions = []Action{{Name: "done", Type: "goto", StepID: "list"}, {Name: "again", Type: "goto", StepID: "store"}}
+	flow.FailureActions = []Action{{Name: "stop", Type: "end"}}
+	flow.Components = &Components{FailureActions: map[string]Action{
+		"retryLater": {Name: "retryLater", Type: "retry", RetryAfter: &retryAfter, RetryLimit: &retryLimit},
+	}}
+	compiled, err := NewFlowCompiler(twoSpecEndpoints(), []FlowDefinition{flow}).Compile()
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	list := compiled[0].Steps[0]
+	wantSuccess := []Action{
+		{Name: "done", Type: "end", Criteria: []Criterion{{Condition: "$statusCode == 204"}}},
+		{Name: "again", Type: "goto", StepID: "store"},
+	}
+	if !reflect.DeepEqual(list.OnSuccess, wantSuccess) {
+		t.Errorf("Expected onSuccess %+v, got %+v", wantSuccess, list.OnSuccess)
+	}
+	wantFailure := []Action{
+		{Name: "retryLater", Type: "retry", RetryAfter: &retryAfter, RetryLimit: &retryLimit},
+		{Name: "stop", Type: "end"},
+	}
+	if !reflect.DeepEqual(list.OnFailure, wantFailure) {
+		t.Errorf("Expected onFailure %+v, got %+v", wantFailure, list.OnFailure)
+	}
+	if got := compiled[0].Steps[1].OnSuccess; len(got) != 2 || got[0].Type != "goto" {
+		t.Errorf("Expected the workflow-level actions on store, got %+v", got)
+	}
+}
+
+func TestCompile_ReportsActionErrors(t *testing.T) {
+	flow := twoSourceFlow(
+		FlowStep{ID: "missingStep", Call: "listItems", OnSuccess: []Action{{Name: "jump", Type: "goto", StepID: "nowhere"}}},
+		FlowStep{ID: "missingRef", Call: "listItems", OnFailure: []Action{{Reference: "$components.failureActions.unknown"}}},
+		FlowStep{ID: "successRetry", Call: "listItems", OnSuccess: []Action{{Name: "again", Type: "retry"}}},
+		FlowStep{ID: "gotoBoth", Call: "listItems", OnFailure: []Action{{Name: "both", Type: "goto", StepID: "missingStep", WorkflowID: "other"}}},
+		FlowStep{ID: "missingFlow", Call: "listItems", OnFailure: []Action{{Name: "away", Type: "goto", WorkflowID: "other"}}},
+	)
+	_, err := NewFlowCompiler(twoSpecEndpoints(), []FlowDefinition{flow}).Compile()
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	for _, want := range []string{
+		"step 'missingStep' in workflow 'wf': onSuccess: action 'jump': step 'nowhere' does not exist",
+		"step 'missingRef' in workflow 'wf': onFailure: reference '$components.failureActions.unknown' not found in components",
+		"step 'successRetry' in workflow 'wf': onSuccess: action 'again': retry is only allowed on failure",
+		"step 'gotoBoth' in workflow 'wf': onFailure: action 'both': goto needs exactly one of stepId or workflowId",
+		"step 'missingFlow' in workflow 'wf': onFailure: action 'away': workflowId 'other' not found",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to contain %q, got:\n%v", want, err)
+		}
+	}
+}
+
+func TestCompile_RejectsGotoWorkflowCycles(t *testing.T) {
+	endpoints := []Endpoint{{ID: "listItems", Method: "GET", Path: "/items"}}
+	flows := []FlowDefinition{
+		{WorkflowID: "a", Steps: []FlowStep{{ID: "s", Call: "listItems", OnFailure: []Action{{Name: "b", Type: "goto", WorkflowID: "b"}}}}},
+		{WorkflowID: "b", Steps: []FlowStep{{ID: "s", Call: "listItems", OnSuccess: []Action{{Name: "a", Type: "goto", WorkflowID: "a"}}}}},
+	}
+	_, err := NewFlowCompiler(endpoints, flows).Compile()
+	if err == nil || !strings.Contains(err.Error(), "workflow cycle through nested workflows: a -> b -> a") {
+		t.Errorf("Expected a workflow cycle error, got %v", err)
+	}
+}
+
+func TestCompile_ResolvesParametersAndExpressions(t *testing.T) {
+	flow := twoSourceFlow(
+		FlowStep{
+			ID:   "list",
+			Call: "listItems",
+			Parameters: []StepParameter{
+				{Name: "limit", In: "query", Value: 5},
+				{Reference: "$components.parameters.owner", Value: "$inputs.owner"},
 			},
+			Outputs: map[string]string{"first": "$response.body#/0/id"},
+		},
+		FlowStep{
+			ID:         "store",
+			Call:       "$sourceDescriptions.store.getItem",
+			Parameters: []StepParameter{{Name: "id", In: "path", Value: "$steps.list.outputs.first"}},
 		},
+	)
+	flow.Inputs = map[string]interface{}{"type": "object", "properties": map[string]interface{}{"owner": map[string]interface{}{"type": "string"}}}
+	flow.Parameters = []StepParameter{{Name: "limit", In: "query", Value: 10}, {Name: "X-Trace", In: "header", Value: "mcp"}}
+	flow.Components = &Components{Parameters: map[string]StepParameter{"owner": {Name: "owner", In: "query", Value: "nobody"}}}
+	flow.Outputs = map[string]string{"item": "$steps.list.outputs.first"}
+	compiled, err := NewFlowCompiler(twoSpecEndpoints(), []FlowDefinition{flow}).Compile()
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	want := []StepParameter{
+		{Name: "X-Trace", In: "header", Value: "mcp"},
+		{Name: "limit", In: "query", Value: 5},
+		{Name: "owner", In: "query", Value: "$inputs.owner"},
 	}
-	compiler := NewFlowCompiler(endpoints, flows)
-	compiled, err := compiler.Compile()
+	if got := compiled[0].Steps[0].Parameters; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected parameters %+v, got %+v", want, got)
+	}
+	if !reflect.DeepEqual(compiled[0].Outputs, flow.Outputs) {
+		t.Errorf("Expected workflow outputs %v, got %v", flow.Outputs, compiled[0].Outputs)
+	}
+
+	flow = twoSourceFlow(
+		FlowStep{ID: "list", Call: "listItems", Parameters: []StepParameter{{Name: "owner", In: "query", Value: "$inputs.ownr"}}},
+		FlowStep{ID: "store", Call: "listItems", Parameters: []StepParameter{{Name: "id", In: "query", Value: "$steps.list.outputs.first"}}},
+		FlowStep{ID: "bad", Call: "listItems", RequestBody: &StepRequestBody{Payload: map[string]interface{}{"x": "{$step.list}"}}},
+		FlowStep{ID: "ref", Call: "listItems", Parameters: []StepParameter{{Reference: "$components.parameters.nope"}}},
+	)
+	flow.Inputs = map[string]interface{}{"properties": map[string]interface{}{"owner": map[string]interface{}{}}}
+	flow.Outputs = map[string]string{"total": "$steps.missing.outputs.total"}
+	_, err = NewFlowCompiler(twoSpecEndpoints(), []FlowDefinition{flow}).Compile()
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	for _, want := range []string{
+		"workflow 'wf': output 'total': '$steps.missing.outputs.total' references unknown step 'missing'",
+		"step 'list' in workflow 'wf': '$inputs.ownr' refers to input 'ownr' which the workflow does not declare",
+		"step 'store' in workflow 'wf': '$steps.list.outputs.first' refers to output 'first' which step 'list' does not declare",
+		"step 'bad' in workflow 'wf': invalid runtime expression",
+		"step 'ref' in workflow 'wf': parameter reference '$components.parameters.nope' not found in components",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to contain %q, got:\n%v", want, err)
+		}
+	}
+}
+
+func TestCompile_InlinesInputComponents(t *testing.T) {
+	flow := twoSourceFlow(FlowStep{ID: "list", Call: "listItems"})
+	flow.Inputs = map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"owner": map[string]interface{}{"$ref": "#/components/inputs/owner"},
+		},
+	}
+	flow.Components = &Components{Inputs: map[string]interface{}{
+		"owner":   map[string]interface{}{"type": "object", "properties": map[string]interface{}{"address": map[string]interface{}{"$ref": "#/components/inputs/address"}}},
+		"address": map[string]interface{}{"type": "string"},
+		"unused":  map[string]interface{}{"type": "integer"},
+	}}
+	compiled, err := NewFlowCompiler(twoSpecEndpoints(), []FlowDefinition{flow}).Compile()
 	if err != nil {
 		t.Fatalf("Compile failed: %v", err)
 	}
-	if len(compiled) != 1 {
-		t.Fatalf("Expected 1 compiled flow, got %d", len(compiled))
+	want := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"owner": map[string]interface{}{"$ref": "#/$defs/owner"},
+		},
+		"$defs": map[string]interface{}{
+			"owner":   map[string]interface{}{"type": "object", "properties": map[string]interface{}{"address": map[string]interface{}{"$ref": "#/$defs/address"}}},
+			"address": map[string]interface{}{"type": "string"},
+		},
 	}
-	cf := compiled[0]
-	if cf.WorkflowID != "sync-user-data" {
-		t.Errorf("Expected WorkflowID 'sync-user-data', got '%s'", cf.WorkflowID)
+	if !reflect.DeepEqual(compiled[0].Inputs, want) {
+		t.Errorf("Expected inputs %v, got %v", want, compiled[0].Inputs)
 	}
-	if len(cf.Steps) != 2 {
-		t.Errorf("Expected 2 steps, got %d", len(cf.Steps))
+	if _, ok := flow.Inputs["properties"].(map[string]interface{})["owner"].(map[string]interface{})["$ref"].(string); !ok {
+		t.Error("Compile must not modify the flow definition")
 	}
-	if cf.Steps[0].Endpoint.ID != "getUser" || cf.Steps[1].Endpoint.ID != "syncData" {
-		t.Errorf("Step endpoint IDs do not match expected")
+
+	flow.Inputs = map[string]interface{}{"$ref": "#/components/inputs/missing"}
+	_, err = NewFlowCompiler(twoSpecEndpoints(), []FlowDefinition{flow}).Compile()
+	if err == nil || !strings.Contains(err.Error(), "workflow 'wf': inputs: reference '#/components/inputs/missing' not found in components") {
+		t.Errorf("Expected a missing component error, got %v", err)
 	}
 }
@@ -0,0 +1,87 @@
+package flowcompiler
+
+import (
+	"fmt"
+	"strings"
+)
+
+const inputsPrefix = "#/components/inputs/"
+
+// inputSchema returns the inputs JSON Schema of flow, self-contained: schemas
+// referenced from the document components ("#/components/inputs/x") are
+// copied under "$defs" and the references rewritten to "#/$defs/x".
+func inputSchema(flow *FlowDefinition) (map[string]interface{}, error) {
+	if len(flow.Inputs) == 0 {
+		return nil, nil
+	}
+	var components map[string]interface{}
+	if flow.Components != nil {
+		components = flow.Components.Inputs
+	}
+	defs := map[string]interface{}{}
+	var rewrite func(v interface{}) (interface{}, error)
+	rewrite = func(v interface{}) (interface{}, error) {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			out := make(map[string]interface{}, len(t))
+			for k, e := range t {
+				if ref, ok := e.(string); ok && k == "$ref" && strings.HasPrefix(ref, inputsPrefix) {
+					name := strings.TrimPrefix(ref, inputsPrefix)
+					def, ok := components[name]
+					if !ok {
+						return nil, fmt.Errorf("reference '%s' not found in components", ref)
+					}
+					out[k] = "#/$defs/" + name
+					if _, done := defs[name]; !done {
+						defs[name] = true
+						resolved, err := rewrite(def)
+						if err != nil {
+							return nil, err
+						}
+						defs[name] = resolved
+					}
+					continue
+				}
+				r, err := rewrite(e)
+				if err != nil {
+					return nil, err
+				}
+				out[k] = r
+			}
+			return out, nil
+		case []interface{}:
+			out := make([]interface{}, len(t))
+			for i, e := range t {
+				r, err := rewrite(e)
+				if err != nil {
+					return nil, err
+				}
+				out[i] = r
+			}
+			return out, nil
+		}
+		return v, nil
+	}
+
+	schema, err := rewrite(flow.Inputs)
+	if err != nil {
+		return nil, &ReferenceError{
+			Location:   Location{File: flow.SourceFile},
+			WorkflowID: flow.WorkflowID,
+			Message:    "inputs: " + err.Error(),
+		}
+	}
+	out := schema.(map[string]interface{})
+	if len(defs) > 0 {
+		existing, _ := out["$defs"].(map[string]interface{})
+		merged := make(map[string]interface{}, len(existing)+len(defs))
+		for k, v := range existing {
+			merged[k] = v
+		}
+		for k, v := range defs {
+			merged[k] = v
+		}
+		out["$defs"] = merged
+	}
+	return out, nil
+}
@@ -0,0 +1,343 @@
+package flowcompiler
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// sourceDescriptionsPrefix qualifies a step reference with a source description name.
+const sourceDescriptionsPrefix = "$sourceDescriptions."
+
+// operationPathPattern matches "{$sourceDescriptions.<name>.url}#<json pointer>".
+var operationPathPattern = regexp.MustCompile(`^\{?\$sourceDescriptions\.([A-Za-z0-9_\-]+)\.url\}?#(.*)$`)
+
+// ReferenceError reports a step whose operationId, operationPath or workflowId
+// does not resolve to exactly one operation or workflow.
+type ReferenceError struct {
+	Location   Location
+	WorkflowID string
+	StepID     string
+	Message    string
+}
+
+func (e *ReferenceError) Error() string {
+	msg := fmt.Sprintf("step '%s' in workflow '%s': %s", e.StepID, e.WorkflowID, e.Message)
+	if e.StepID == "" {
+		msg = fmt.Sprintf("workflow '%s': %s", e.WorkflowID, e.Message)
+	}
+	if loc := e.Location.String(); loc != "" {
+		return loc + ": " + msg
+	}
+	return msg
+}
+
+// resolver looks up step references across every loaded spec and workflow.
+type resolver struct {
+	endpoints []*Endpoint
+	flows     []*FlowDefinition
+	services  map[string]bool
+}
+
+func newResolver(endpoints []Endpoint, flows []FlowDefinition) *resolver {
+	r := &resolver{services: map[string]bool{}}
+	for i := range endpoints {
+		r.endpoints = append(r.endpoints, &endpoints[i])
+		if endpoints[i].Service != "" {
+			r.services[endpoints[i].Service] = true
+		}
+	}
+	for i := range flows {
+		r.flows = append(r.flows, &flows[i])
+	}
+	return r
+}
+
+// resolveStep returns the endpoint or the workflow a step refers to.
+func (r *resolver) resolveStep(flow *FlowDefinition, step *FlowStep) (*Endpoint, *FlowDefinition, error) {
+	fail := func(format string, args ...interface{}) (*Endpoint, *FlowDefinition, error) {
+		return nil, nil, &ReferenceError{
+			Location:   step.Location,
+			WorkflowID: flow.WorkflowID,
+			StepID:     step.ID,
+			Message:    fmt.Sprintf(format, args...),
+		}
+	}
+
+	set := 0
+	for _, ref := range []string{step.Call, step.OperationPath, step.WorkflowID} {
+		if ref != "" {
+			set++
+		}
+	}
+	switch {
+	case set == 0:
+		return fail("one of operationId, operationPath or workflowId must be set")
+	case set > 1:
+		return fail("operationId, operationPath and workflowId are mutually exclusive")
+	}
+
+	switch {
+	case step.Call != "":
+		name, id := splitQualified(step.Call)
+		candidates, err := r.sourceEndpoints(flow, name)
+		if err != nil {
+			return fail("operationId '%s': %v", step.Call, err)
+		}
+		service := ""
+		if name == "" {
+			service, id = r.splitService(id)
+		}
+		var matches []*Endpoint
+		for _, ep := range candidates {
+			if ep.ID == id && (service == "" || ep.Service == service) {
+				matches = append(matches, ep)
+			}
+		}
+		switch len(matches) {
+		case 0:
+			return fail("operationId '%s' not found", step.Call)
+		case 1:
+			return matches[0], nil, nil
+		default:
+			hint := "qualify it with $sourceDescriptions.<name>"
+			if names := qualifiedNames(matches); names != "" {
+				hint = "qualify it as " + names + " or with $sourceDescriptions.<name>"
+			}
+			return fail("operationId '%s' is ambiguous, it is defined in %s; %s", step.Call, endpointSources(matches), hint)
+		}
+
+	case step.OperationPath != "":
+		m := operationPathPattern.FindStringSubmatch(step.OperationPath)
+		if m == nil {
+			return fail("operationPath '%s' must have the form {$sourceDescriptions.<name>.url}#/paths/<path>/<method>", step.OperationPath)
+		}
+		pointer := m[2]
+		if unescaped, err := url.PathUnescape(pointer); err == nil {
+			pointer = unescaped
+		}
+		tokens := splitPointer(pointer)
+		if len(tokens) != 3 || tokens[0] != "paths" {
+			return fail("operationPath '%s' must point at /paths/<path>/<method>", step.OperationPath)
+		}
+		candidates, err := r.sourceEndpoints(flow, m[1])
+		if err != nil {
+			return fail("operationPath '%s': %v", step.OperationPath, err)
+		}
+		var matches []*Endpoint
+		for _, ep := range candidates {
+			if ep.Path == tokens[1] && strings.EqualFold(ep.Method, tokens[2]) {
+				matches = append(matches, ep)
+			}
+		}
+		switch len(matches) {
+		case 0:
+			return fail("operationPath '%s' not found", step.OperationPath)
+		case 1:
+			return matches[0], nil, nil
+		default:
+			return fail("operationPath '%s' is ambiguous, it is defined in %s", step.OperationPath, endpointSources(matches))
+		}
+
+	default:
+		nested, err := r.lookupWorkflow(flow, step.WorkflowID)
+		if err != nil {
+			return fail("%v", err)
+		}
+		return nil, nested, nil
+	}
+}
+
+// lookupWorkflow resolves a workflowId, unqualified for the document of flow
+// or qualified with the name of an Arazzo source description. A workflow may
+// not refer to itself.
+func (r *resolver) lookupWorkflow(flow *FlowDefinition, ref string) (*FlowDefinition, error) {
+	name, id := splitQualified(ref)
+	var matches []*FlowDefinition
+	for _, candidate := range r.flows {
+		if candidate.WorkflowID != id {
+			continue
+		}
+		if name == "" {
+			if sameSource(candidate.SourceFile, flow.SourceFile) {
+				matches = append(matches, candidate)
+			}
+			continue
+		}
+		sd := findSourceDescription(flow, name)
+		if sd == nil {
+			return nil, fmt.Errorf("workflowId '%s': unknown source description '%s'", ref, name)
+		}
+		if sameSource(candidate.SourceFile, resolveSourceURL(flow.SourceFile, sd.URL)) {
+			matches = append(matches, candidate)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("workflowId '%s' not found", ref)
+	case 1:
+		if matches[0].WorkflowID == flow.WorkflowID && sameSource(matches[0].SourceFile, flow.SourceFile) {
+			return nil, fmt.Errorf("workflowId '%s' refers to the workflow itself", ref)
+		}
+		return matches[0], nil
+	default:
+		return nil, fmt.Errorf("workflowId '%s' is ambiguous", ref)
+	}
+}
+
+// sourceEndpoints returns the endpoints a reference qualified with source
+// description name may resolve to. Unqualified references search the specs of
+// every OpenAPI source description of the flow, or all endpoints when none of
+// them was loaded.
+func (r *resolver) sourceEndpoints(flow *FlowDefinition, name string) ([]*Endpoint, error) {
+	if name != "" {
+		sd := findSourceDescription(flow, name)
+		if sd == nil {
+			return nil, fmt.Errorf("unknown source description '%s'", name)
+		}
+		endpoints := r.endpointsOf(flow, sd)
+		if len(endpoints) == 0 {
+			return nil, fmt.Errorf("source description '%s' (%s) does not match any loaded spec", name, sd.URL)
+		}
+		return endpoints, nil
+	}
+
+	var endpoints []*Endpoint
+	for i := range flow.SourceDescriptions {
+		sd := &flow.SourceDescriptions[i]
+		if sd.Type == "" || sd.Type == "openapi" {
+			endpoints = append(endpoints, r.endpointsOf(flow, sd)...)
+		}
+	}
+	if len(endpoints) == 0 {
+		return r.endpoints, nil
+	}
+	return endpoints, nil
+}
+
+// endpointsOf returns the endpoints loaded from the spec a source description
+// points at. Specs are matched by location, falling back to the file name so a
+// local copy of a remote description is accepted.
+func (r *resolver) endpointsOf(flow *FlowDefinition, sd *SourceDescription) []*Endpoint {
+	location := resolveSourceURL(flow.SourceFile, sd.URL)
+	var exact, byName []*Endpoint
+	for _, ep := range r.endpoints {
+		switch {
+		case ep.Source == "":
+		case sameSource(ep.Source, location):
+			exact = append(exact, ep)
+		case baseName(ep.Source) == baseName(location):
+			byName = append(byName, ep)
+		}
+	}
+	if len(exact) > 0 {
+		return exact
+	}
+	return byName
+}
+
+func findSourceDescription(flow *FlowDefinition, name string) *SourceDescription {
+	for i := range flow.SourceDescriptions {
+		if flow.SourceDescriptions[i].Name == name {
+			return &flow.SourceDescriptions[i]
+		}
+	}
+	return nil
+}
+
+// splitQualified splits "$sourceDescriptions.<name>.<id>" into name and id.
+// Unqualified references are returned with an empty name.
+func splitQualified(ref string) (string, string) {
+	if !strings.HasPrefix(ref, sourceDescriptionsPrefix) {
+		return "", ref
+	}
+	rest := strings.TrimPrefix(ref, sourceDescriptionsPrefix)
+	if i := strings.IndexByte(rest, '.'); i >= 0 {
+		return rest[:i], rest[i+1:]
+	}
+	return "", ref
+}
+
+// splitService splits "<service>.<operationId>" when the prefix names a
+// loaded service. Other references are returned with an empty service.
+func (r *resolver) splitService(ref string) (string, string) {
+	if service, id, ok := strings.Cut(ref, "."); ok && r.services[service] {
+		return service, id
+	}
+	return "", ref
+}
+
+// qualifiedNames lists the endpoints as "<service>.<operationId>", or returns
+// "" when that does not tell them apart.
+func qualifiedNames(endpoints []*Endpoint) string {
+	seen := map[string]bool{}
+	var names []string
+	for _, ep := range endpoints {
+		name := ep.Service + "." + ep.ID
+		if ep.Service == "" || seen[name] {
+			return ""
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// splitPointer decodes the reference tokens of a JSON pointer.
+func splitPointer(pointer string) []string {
+	pointer = strings.TrimPrefix(pointer, "/")
+	if pointer == "" {
+		return nil
+	}
+	tokens := strings.Split(pointer, "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens
+}
+
+// resolveSourceURL resolves a source description URL against the document it
+// is declared in.
+func resolveSourceURL(document, location string) string {
+	if isRemote(location) || filepath.IsAbs(location) || document == "" || isRemote(document) {
+		return location
+	}
+	return filepath.Join(filepath.Dir(document), location)
+}
+
+func sameSource(a, b string) bool {
+	if a == b {
+		return true
+	}
+	if isRemote(a) || isRemote(b) {
+		return false
+	}
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+func isRemote(location string) bool {
+	return strings.Contains(location, "://")
+}
+
+func baseName(location string) string {
+	if i := strings.LastIndexAny(location, "/\\"); i >= 0 {
+		return location[i+1:]
+	}
+	return location
+}
+
+func endpointSources(endpoints []*Endpoint) string {
+	var sources []string
+	for _, ep := range endpoints {
+		source := ep.Source
+		if source == "" {
+			source = ep.Method + " " + ep.Path
+		}
+		sources = append(sources, source)
+	}
+	return strings.Join(sources, ", ")
+}
@@ -0,0 +1,419 @@
+package runtimeexpr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// condNode is a node of a parsed condition: a literal, a reference resolved at
+// evaluation time, a function call or an operator.
+type condNode struct {
+	op       string // "lit", "ref", "call", "!", "&&", "||" or a comparison
+	value    any
+	ref      string
+	children []*condNode
+}
+
+// condResolver returns every value a reference selects. Runtime expressions
+// select at most one value; JSONPath references may select several.
+type condResolver func(ref string) ([]any, error)
+
+// parseCondition parses a simple condition such as
+// "$statusCode == 200 && $response.body#/status != 'failed'".
+func parseCondition(src string) (*condNode, error) {
+	tokens, err := tokenizeCondition(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &condParser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("condition %q: %v", src, err)
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("condition %q: unexpected %q", src, p.tokens[p.pos].text)
+	}
+	return node, nil
+}
+
+// refs returns every reference used by the condition.
+func (n *condNode) refs() []string {
+	var out []string
+	if n.op == "ref" {
+		out = append(out, n.ref)
+	}
+	for _, c := range n.children {
+		out = append(out, c.refs()...)
+	}
+	return out
+}
+
+// eval evaluates the condition to a boolean.
+func (n *condNode) eval(resolve condResolver) (bool, error) {
+	v, err := n.evalValue(resolve)
+	if err != nil {
+		return false, err
+	}
+	return truthy(v), nil
+}
+
+func (n *condNode) evalValue(resolve condResolver) (any, error) {
+	switch n.op {
+	case "lit":
+		return n.value, nil
+	case "ref":
+		values, err := resolve(n.ref)
+		if err != nil || len(values) == 0 {
+			return nil, err
+		}
+		return values[0], nil
+	case "call":
+		values, err := resolve(n.children[0].ref)
+		if err != nil {
+			return nil, err
+		}
+		switch n.ref {
+		case "count":
+			return float64(len(values)), nil
+		case "length":
+			if len(values) == 0 {
+				return nil, nil
+			}
+			switch v := values[0].(type) {
+			case string:
+				return float64(len([]rune(v))), nil
+			case []any:
+				return float64(len(v)), nil
+			case map[string]any:
+				return float64(len(v)), nil
+			}
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unknown function %s", n.ref)
+	case "!":
+		b, err := n.children[0].eval(resolve)
+		return !b, err
+	case "&&", "||":
+		left, err := n.children[0].eval(resolve)
+		if err != nil {
+			return false, err
+		}
+		if (n.op == "&&") != left {
+			return left, nil
+		}
+		return n.children[1].eval(resolve)
+	}
+	left, err := n.children[0].evalValue(resolve)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.children[1].evalValue(resolve)
+	if err != nil {
+		return nil, err
+	}
+	return compareValues(n.op, left, right), nil
+}
+
+// compareValues applies a comparison operator. Numbers compare numerically,
+// also when one side is a numeric string such as a header value; strings
+// compare case-insensitively as the Arazzo specification requires.
+func compareValues(op string, left, right any) bool {
+	if l, lok := toNumber(left); lok {
+		if r, rok := toNumber(right); rok {
+			return compareOrdered(op, l, r)
+		}
+	}
+	ls, lok := left.(string)
+	rs, rok := right.(string)
+	if lok && rok {
+		return compareOrdered(op, strings.ToLower(ls), strings.ToLower(rs))
+	}
+	equal := fmt.Sprint(left) == fmt.Sprint(right) && (left == nil) == (right == nil)
+	switch op {
+	case "==":
+		return equal
+	case "!=":
+		return !equal
+	}
+	return false
+}
+
+func compareOrdered[T float64 | string](op string, l, r T) bool {
+	switch op {
+	case "==":
+		return l == r
+	case "!=":
+		return l != r
+	case "<":
+		return l < r
+	case "<=":
+		return l <= r
+	case ">":
+		return l > r
+	case ">=":
+		return l >= r
+	}
+	return false
+}
+
+// toNumber converts JSON numbers, Go integers and numeric strings.
+func toNumber(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func truthy(v any) bool {
+	switch t := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return t
+	case string:
+		return t != ""
+	case []any:
+		return len(t) > 0
+	case map[string]any:
+		return len(t) > 0
+	}
+	if n, ok := toNumber(v); ok {
+		return n != 0
+	}
+	return true
+}
+
+type condToken struct {
+	kind string // "op", "lit", "ref", "ident", "(" or ")"
+	text string
+	lit  any
+}
+
+// tokenizeCondition splits a condition into tokens. References start with $
+// or @ and run until whitespace, a parenthesis or an operator character.
+func tokenizeCondition(src string) ([]condToken, error) {
+	var tokens []condToken
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, condToken{kind: string(c), text: string(c)})
+			i++
+		case strings.HasPrefix(src[i:], "&&") || strings.HasPrefix(src[i:], "||") ||
+			strings.HasPrefix(src[i:], "==") || strings.HasPrefix(src[i:], "!=") ||
+			strings.HasPrefix(src[i:], "<=") || strings.HasPrefix(src[i:], ">="):
+			tokens = append(tokens, condToken{kind: "op", text: src[i : i+2]})
+			i += 2
+		case c == '<' || c == '>' || c == '!':
+			tokens = append(tokens, condToken{kind: "op", text: string(c)})
+			i++
+		case c == '\'' || c == '"':
+			end := i + 1
+			var b strings.Builder
+			for ; end < len(src) && src[end] != c; end++ {
+				if src[end] == '\\' && end+1 < len(src) {
+					end++
+				}
+				b.WriteByte(src[end])
+			}
+			if end >= len(src) {
+				return nil, fmt.Errorf("condition %q: unterminated string", src)
+			}
+			tokens = append(tokens, condToken{kind: "lit", text: src[i : end+1], lit: b.String()})
+			i = end + 1
+		case c == '$' || c == '@':
+			end := scanReference(src, i)
+			tokens = append(tokens, condToken{kind: "ref", text: src[i:end]})
+			i = end
+		case c == '-' || c == '.' || (c >= '0' && c <= '9'):
+			end := i + 1
+			for end < len(src) && (src[end] == '.' || src[end] == 'e' || src[end] == 'E' || (src[end] >= '0' && src[end] <= '9')) {
+				end++
+			}
+			f, err := strconv.ParseFloat(src[i:end], 64)
+			if err != nil {
+				return nil, fmt.Errorf("condition %q: invalid number %q", src, src[i:end])
+			}
+			tokens = append(tokens, condToken{kind: "lit", text: src[i:end], lit: f})
+			i = end
+		case unicode.IsLetter(rune(c)):
+			end := i + 1
+			for end < len(src) && (unicode.IsLetter(rune(src[end])) || unicode.IsDigit(rune(src[end])) || src[end] == '_') {
+				end++
+			}
+			word := src[i:end]
+			switch word {
+			case "true":
+				tokens = append(tokens, condToken{kind: "lit", text: word, lit: true})
+			case "false":
+				tokens = append(tokens, condToken{kind: "lit", text: word, lit: false})
+			case "null":
+				tokens = append(tokens, condToken{kind: "lit", text: word, lit: nil})
+			default:
+				tokens = append(tokens, condToken{kind: "ident", text: word})
+			}
+			i = end
+		default:
+			return nil, fmt.Errorf("condition %q: unexpected character %q", src, c)
+		}
+	}
+	return tokens, nil
+}
+
+// scanReference returns the end of the reference starting at i. Brackets and
+// quotes inside a reference, as in "@.items[0]['a b']", are kept together.
+func scanReference(src string, i int) int {
+	depth := 0
+	var quote byte
+	for j := i; j < len(src); j++ {
+		c := src[j]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			if depth == 0 {
+				return j
+			}
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case depth > 0:
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '(' || c == ')' ||
+			c == '=' || c == '!' || c == '<' || c == '>' || c == '&' || c == '|':
+			return j
+		}
+	}
+	return len(src)
+}
+
+type condParser struct {
+	tokens []condToken
+	pos    int
+}
+
+func (p *condParser) peek() *condToken {
+	if p.pos < len(p.tokens) {
+		return &p.tokens[p.pos]
+	}
+	return nil
+}
+
+func (p *condParser) parseOr() (*condNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for t := p.peek(); t != nil && t.kind == "op" && t.text == "||"; t = p.peek() {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &condNode{op: "||", children: []*condNode{left, right}}
+	}
+	return left, nil
+}
+
+func (p *condParser) parseAnd() (*condNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for t := p.peek(); t != nil && t.kind == "op" && t.text == "&&"; t = p.peek() {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &condNode{op: "&&", children: []*condNode{left, right}}
+	}
+	return left, nil
+}
+
+func (p *condParser) parseUnary() (*condNode, error) {
+	if t := p.peek(); t != nil && t.kind == "op" && t.text == "!" {
+		p.pos++
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &condNode{op: "!", children: []*condNode{operand}}, nil
+	}
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t != nil && t.kind == "op" {
+		switch t.text {
+		case "==", "!=", "<", "<=", ">", ">=":
+			p.pos++
+			right, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			return &condNode{op: t.text, children: []*condNode{left, right}}, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *condParser) parseOperand() (*condNode, error) {
+	t := p.peek()
+	if t == nil {
+		return nil, fmt.Errorf("unexpected end of condition")
+	}
+	p.pos++
+	switch t.kind {
+	case "lit":
+		return &condNode{op: "lit", value: t.lit}, nil
+	case "ref":
+		return &condNode{op: "ref", ref: t.text}, nil
+	case "(":
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if end := p.peek(); end == nil || end.kind != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return node, nil
+	case "ident":
+		if t.text != "count" && t.text != "length" {
+			return nil, fmt.Errorf("unknown function %s", t.text)
+		}
+		if open := p.peek(); open == nil || open.kind != "(" {
+			return nil, fmt.Errorf("%s must be called", t.text)
+		}
+		p.pos++
+		arg := p.peek()
+		if arg == nil || arg.kind != "ref" {
+			return nil, fmt.Errorf("%s expects a reference", t.text)
+		}
+		p.pos++
+		if end := p.peek(); end == nil || end.kind != ")" {
+			return nil, fmt.Errorf("missing ) after %s argument", t.text)
+		}
+		p.pos++
+		return &condNode{op: "call", ref: t.text, children: []*condNode{{op: "ref", ref: arg.text}}}, nil
+	}
+	return nil, fmt.Errorf("unexpected %q", t.text)
+}
@@ -0,0 +1,115 @@
+package runtimeexpr
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Criterion is an Arazzo success criterion. Simple conditions are evaluated
+// on their own; regex, jsonpath and xpath conditions apply to the value of
+// Context, e.g. "$response.body".
+type Criterion struct {
+	Context   string
+	Condition string
+	Type      string // simple (default), regex, jsonpath or xpath
+	Version   string
+}
+
+// Validate checks the criterion without evaluating it.
+func (c Criterion) Validate() error {
+	if c.Context != "" {
+		if err := ValidateExpression(c.Context); err != nil {
+			return err
+		}
+	}
+	switch c.Type {
+	case "", "simple":
+		node, err := parseCondition(c.Condition)
+		if err != nil {
+			return err
+		}
+		for _, ref := range node.refs() {
+			if err := ValidateExpression(ref); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "regex":
+		if c.Context == "" {
+			return fmt.Errorf("regex criterion %q needs a context", c.Condition)
+		}
+		_, err := regexp.Compile(c.Condition)
+		return err
+	case "jsonpath":
+		if c.Context == "" {
+			return fmt.Errorf("jsonpath criterion %q needs a context", c.Condition)
+		}
+		_, err := parseJSONPath(c.Condition)
+		return err
+	case "xpath":
+		if c.Context == "" {
+			return fmt.Errorf("xpath criterion %q needs a context", c.Condition)
+		}
+		_, err := parseXPath(c.Condition)
+		return err
+	}
+	return fmt.Errorf("unknown criterion type %q", c.Type)
+}
+
+// Evaluate reports whether the criterion holds for ec.
+func (c Criterion) Evaluate(ec *EvalContext) (bool, error) {
+	var context any
+	if c.Context != "" {
+		v, err := ec.Resolve(c.Context)
+		if err != nil {
+			return false, err
+		}
+		context = v
+	}
+
+	switch c.Type {
+	case "", "simple":
+		node, err := parseCondition(c.Condition)
+		if err != nil {
+			return false, err
+		}
+		return node.eval(func(ref string) ([]any, error) {
+			v, err := ec.Resolve(ref)
+			if err != nil || v == nil {
+				return nil, err
+			}
+			return []any{v}, nil
+		})
+	case "regex":
+		re, err := regexp.Compile(c.Condition)
+		if err != nil {
+			return false, err
+		}
+		if context == nil {
+			return false, nil
+		}
+		return re.MatchString(fmt.Sprint(context)), nil
+	case "jsonpath":
+		path, err := parseJSONPath(c.Condition)
+		if err != nil {
+			return false, err
+		}
+		nodes, err := path.query(context, context)
+		return len(nodes) > 0, err
+	case "xpath":
+		expr, err := parseXPath(c.Condition)
+		if err != nil {
+			return false, err
+		}
+		text, ok := context.(string)
+		if !ok {
+			return false, nil
+		}
+		doc, err := parseXML(text)
+		if err != nil {
+			return false, fmt.Errorf("xpath criterion: %v", err)
+		}
+		return expr.evaluate(doc), nil
+	}
+	return false, fmt.Errorf("unknown criterion type %q", c.Type)
+}
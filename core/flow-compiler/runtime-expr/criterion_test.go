@@ -0,0 +1,115 @@
+package runtimeexpr
+
+import (
+	"go/parser"
+	"go/token"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func testContext() *EvalContext {
+	return &EvalContext{
+		Inputs:         map[string]any{"petId": 7, "owner": map[string]any{"name": "Ada"}},
+		Method:         "GET",
+		URL:            "https://petstore.example.com/pets/7",
+		StatusCode:     200,
+		ResponseHeader: http.Header{"X-Rate-Remaining": {"12"}, "Content-Type": {"application/json"}},
+		ResponseBody: map[string]any{
+			"id":     float64(7),
+			"status": "Available",
+			"tags":   []any{map[string]any{"name": "dog"}, map[string]any{"name": "good boy"}},
+		},
+	}
+}
+
+func TestCriterion_Evaluate(t *testing.T) {
+	xmlContext := testContext()
+	xmlContext.ResponseBody = `<pets><pet id="7" status="available"><name>Rex</name></pet><pet id="8"><name>Tom</name></pet></pets>`
+
+	tests := []struct {
+		criterion Criterion
+		ec        *EvalContext
+		want      bool
+	}{
+		{Criterion{Condition: "$statusCode == 200"}, testContext(), true},
+		{Criterion{Condition: "$statusCode == 201"}, testContext(), false},
+		{Criterion{Condition: "$statusCode >= 200 && $statusCode < 300"}, testContext(), true},
+		{Criterion{Condition: "$response.body#/status == 'available'"}, testContext(), true},
+		{Criterion{Condition: "$response.body#/status != 'available' || $method == 'POST'"}, testContext(), false},
+		{Criterion{Condition: "!($response.body#/missing)"}, testContext(), true},
+		{Criterion{Condition: "$response.header.X-Rate-Remaining > 10"}, testContext(), true},
+		{Criterion{Condition: "$response.body#/id == $inputs.petId"}, testContext(), true},
+		{Criterion{Condition: "$inputs.owner.name == 'ada'"}, testContext(), true},
+		{Criterion{Context: "$response.body#/status", Condition: "^Avail", Type: "regex"}, testContext(), true},
+		{Criterion{Context: "$response.header.Content-Type", Condition: "xml", Type: "regex"}, testContext(), false},
+		{Criterion{Context: "$response.body", Condition: "$[?(@.status == 'available')]", Type: "jsonpath"}, testContext(), true},
+		{Criterion{Context: "$response.body", Condition: "$.tags[?(@.name == 'good boy')]", Type: "jsonpath"}, testContext(), true},
+		{Criterion{Context: "$response.body", Condition: "$[?count(@.tags[*]) > 2]", Type: "jsonpath"}, testContext(), false},
+		{Criterion{Context: "$response.body", Condition: "$..name", Type: "jsonpath"}, testContext(), true},
+		{Criterion{Context: "$response.body", Condition: "$.owner", Type: "jsonpath"}, testContext(), false},
+		{Criterion{Context: "$response.body", Condition: "/pets/pet[@id='7']/name = 'Rex'", Type: "xpath"}, xmlContext, true},
+		{Criterion{Context: "$response.body", Condition: "count(//pet) = 2", Type: "xpath"}, xmlContext, true},
+		{Criterion{Context: "$response.body", Condition: "//pet[2]/@status", Type: "xpath"}, xmlContext, false},
+		{Criterion{Context: "$response.body", Condition: "//pet[name='Tom']/@id = 8", Type: "xpath"}, xmlContext, true},
+	}
+	for _, tt := range tests {
+		if err := tt.criterion.Validate(); err != nil {
+			t.Errorf("Validate(%+v) failed: %v", tt.criterion, err)
+			continue
+		}
+		got, err := tt.criterion.Evaluate(tt.ec)
+		if err != nil {
+			t.Errorf("Evaluate(%+v) failed: %v", tt.criterion, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Evaluate(%+v) = %v, want %v", tt.criterion, got, tt.want)
+		}
+	}
+}
+
+func TestCriterion_Validate(t *testing.T) {
+	tests := map[string]Criterion{
+		"syntax":           {Condition: "$statusCode =="},
+		"unknown source":   {Condition: "$reply.body == 1"},
+		"regex context":    {Condition: "^ok$", Type: "regex"},
+		"bad regex":        {Context: "$response.body", Condition: "(", Type: "regex"},
+		"bad jsonpath":     {Context: "$response.body", Condition: "pets", Type: "jsonpath"},
+		"bad xpath":        {Context: "$response.body", Condition: "//pet[0]", Type: "xpath"},
+		"xpath function":   {Context: "$response.body", Condition: "/r/status[text()='ok']", Type: "xpath"},
+		"xpath operator":   {Context: "$response.body", Condition: "//pet[@id!='7']", Type: "xpath"},
+		"xpath ordering":   {Context: "$response.body", Condition: "//pet[age > 3]", Type: "xpath"},
+		"xpath step":       {Context: "$response.body", Condition: "//node()", Type: "xpath"},
+		"unknown type":     {Condition: "$statusCode == 200", Type: "cel"},
+		"response query":   {Condition: "$response.query.page == 1"},
+		"unterminated str": {Condition: "$method == 'GET"},
+	}
+	for name, c := range tests {
+		if err := c.Validate(); err == nil {
+			t.Errorf("%s: expected %+v to be invalid", name, c)
+		}
+	}
+}
+
+func TestSources_CompileAsPackageMain(t *testing.T) {
+	files, err := Sources()
+	if err != nil {
+		t.Fatalf("Sources failed: %v", err)
+	}
+	if _, ok := files["embed.go"]; ok {
+		t.Error("embed.go must not be emitted")
+	}
+	for name, src := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			t.Errorf("test file %s must not be emitted", name)
+		}
+		f, err := parser.ParseFile(token.NewFileSet(), name, src, parser.PackageClauseOnly)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if f.Name.Name != "main" {
+			t.Errorf("%s: expected package main, got %s", name, f.Name.Name)
+		}
+	}
+}
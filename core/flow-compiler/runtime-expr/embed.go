@@ -0,0 +1,39 @@
+// Package runtimeexpr evaluates Arazzo runtime expressions and success
+// criteria. The compiler uses it to validate workflows; its sources are also
+// copied into every generated server, so it only depends on the standard
+// library and every file but this one must compile as part of package main.
+package runtimeexpr
+
+import (
+	"embed"
+	"regexp"
+	"strings"
+)
+
+//go:embed *.go
+var sourceFS embed.FS
+
+var packageClause = regexp.MustCompile(`(?m)^package runtimeexpr$`)
+
+// Sources returns the files of this package rewritten into package main,
+// keyed by file name. Tests and this file are left out.
+func Sources() (map[string][]byte, error) {
+	entries, err := sourceFS.ReadDir(".")
+	if err != nil {
+		return nil, err
+	}
+	files := map[string][]byte{}
+	for _, e := range entries {
+		name := e.Name()
+		if name == "embed.go" || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		src, err := sourceFS.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		src = packageClause.ReplaceAll(src, []byte("// Code generated by mcpgen. DO NOT EDIT.\n\npackage main"))
+		files[name] = src
+	}
+	return files, nil
+}
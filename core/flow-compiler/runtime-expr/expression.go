@@ -0,0 +1,363 @@
+package runtimeexpr
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// EvalContext holds the values runtime expressions of a step are evaluated
+// against: the workflow inputs, the outputs of the steps that already ran and
+// the request and response of the step.
+type EvalContext struct {
+	Inputs map[string]any
+	// Steps holds the outputs of completed steps by step ID.
+	Steps map[string]map[string]any
+
+	URL            string
+	Method         string
+	RequestHeader  http.Header
+	RequestQuery   url.Values
+	RequestPath    map[string]string
+	RequestBody    any
+	StatusCode     int
+	ResponseHeader http.Header
+	// ResponseBody is the decoded JSON body, or the raw body as a string.
+	ResponseBody any
+}
+
+// IsExpression reports whether s is a runtime expression.
+func IsExpression(s string) bool {
+	return strings.HasPrefix(s, "$")
+}
+
+// ValidateExpression checks the syntax of a runtime expression.
+func ValidateExpression(expr string) error {
+	_, _, err := splitExpression(expr)
+	return err
+}
+
+// Resolve evaluates a runtime expression. Values that do not exist, such as a
+// missing header or JSON pointer target, resolve to nil.
+func (ec *EvalContext) Resolve(expr string) (any, error) {
+	source, rest, err := splitExpression(expr)
+	if err != nil {
+		return nil, err
+	}
+	switch source {
+	case "$url":
+		return ec.URL, nil
+	case "$method":
+		return ec.Method, nil
+	case "$statusCode":
+		return ec.StatusCode, nil
+	case "$inputs":
+		return lookupPath(ec.Inputs, rest)
+	case "$steps":
+		id, output, _ := strings.Cut(rest, ".outputs.")
+		return lookupPath(ec.Steps[id], output)
+	case "$request":
+		return resolveMessage(rest, ec.RequestHeader, ec.RequestQuery, ec.RequestPath, ec.RequestBody)
+	case "$response":
+		return resolveMessage(rest, ec.ResponseHeader, nil, nil, ec.ResponseBody)
+	}
+	return nil, fmt.Errorf("runtime expression %q is not supported", expr)
+}
+
+// StepOutput returns the step ID and output name a $steps expression refers
+// to, e.g. "getUser" and "id" for "$steps.getUser.outputs.id#/0".
+func StepOutput(expr string) (string, string, bool) {
+	source, rest, err := splitExpression(expr)
+	if err != nil || source != "$steps" {
+		return "", "", false
+	}
+	id, output, _ := strings.Cut(rest, ".outputs.")
+	if i := strings.IndexAny(output, ".#"); i >= 0 {
+		output = output[:i]
+	}
+	return id, output, true
+}
+
+// InputName returns the input an $inputs expression refers to, e.g.
+// "user" for "$inputs.user.name".
+func InputName(expr string) (string, bool) {
+	source, rest, err := splitExpression(expr)
+	if err != nil || source != "$inputs" {
+		return "", false
+	}
+	if i := strings.IndexAny(rest, ".#"); i >= 0 {
+		rest = rest[:i]
+	}
+	return rest, true
+}
+
+// Expressions returns the runtime expressions used in a value: strings that
+// are an expression, and expressions embedded in strings as "{$inputs.id}".
+// Maps and arrays are searched recursively.
+func Expressions(v any) []string {
+	var out []string
+	switch t := v.(type) {
+	case string:
+		if IsExpression(t) {
+			return []string{t}
+		}
+		for rest := t; ; {
+			start := strings.Index(rest, "{$")
+			if start < 0 {
+				break
+			}
+			end := strings.IndexByte(rest[start:], '}')
+			if end < 0 {
+				break
+			}
+			out = append(out, rest[start+1:start+end])
+			rest = rest[start+end+1:]
+		}
+	case map[string]any:
+		for _, k := range sortedKeys(t) {
+			out = append(out, Expressions(t[k])...)
+		}
+	case []any:
+		for _, e := range t {
+			out = append(out, Expressions(e)...)
+		}
+	}
+	return out
+}
+
+// ResolveValue returns v with every runtime expression replaced by its value.
+// A string that is an expression takes the type of the value; embedded
+// expressions are formatted into the surrounding string.
+func (ec *EvalContext) ResolveValue(v any) (any, error) {
+	switch t := v.(type) {
+	case string:
+		if IsExpression(t) {
+			return ec.Resolve(t)
+		}
+		if !strings.Contains(t, "{$") {
+			return t, nil
+		}
+		var b strings.Builder
+		rest := t
+		for {
+			start := strings.Index(rest, "{$")
+			end := -1
+			if start >= 0 {
+				end = strings.IndexByte(rest[start:], '}')
+			}
+			if end < 0 {
+				b.WriteString(rest)
+				return b.String(), nil
+			}
+			value, err := ec.Resolve(rest[start+1 : start+end])
+			if err != nil {
+				return nil, err
+			}
+			b.WriteString(rest[:start])
+			if value != nil {
+				b.WriteString(fmt.Sprint(value))
+			}
+			rest = rest[start+end+1:]
+		}
+	case map[string]any:
+		out := make(map[string]any, len(t))
+		for k, e := range t {
+			r, err := ec.ResolveValue(e)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = r
+		}
+		return out, nil
+	case []any:
+		out := make([]any, len(t))
+		for i, e := range t {
+			r, err := ec.ResolveValue(e)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = r
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+// SetPointer stores value at the JSON pointer target inside doc and returns
+// the updated document. Missing objects along the way are created; "-"
+// appends to an array.
+func SetPointer(doc any, target string, value any) (any, error) {
+	if target == "" {
+		return value, nil
+	}
+	if !strings.HasPrefix(target, "/") {
+		return nil, fmt.Errorf("JSON pointer %q must start with /", target)
+	}
+	token, rest, _ := strings.Cut(target[1:], "/")
+	token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	if rest != "" {
+		rest = "/" + rest
+	}
+	switch v := doc.(type) {
+	case []any:
+		if token == "-" {
+			child, err := SetPointer(nil, rest, value)
+			if err != nil {
+				return nil, err
+			}
+			return append(v, child), nil
+		}
+		i, err := strconv.Atoi(token)
+		if err != nil || i < 0 || i >= len(v) {
+			return nil, fmt.Errorf("JSON pointer %q: index %s out of range", target, token)
+		}
+		child, err := SetPointer(v[i], rest, value)
+		if err != nil {
+			return nil, err
+		}
+		v[i] = child
+		return v, nil
+	case map[string]any:
+		child, err := SetPointer(v[token], rest, value)
+		if err != nil {
+			return nil, err
+		}
+		v[token] = child
+		return v, nil
+	case nil:
+		child, err := SetPointer(nil, rest, value)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{token: child}, nil
+	}
+	return nil, fmt.Errorf("JSON pointer %q: cannot set %s in a %T", target, token, doc)
+}
+
+// splitExpression splits an expression into its source ("$inputs") and the
+// remainder after the source and its separating dot.
+func splitExpression(expr string) (string, string, error) {
+	if !IsExpression(expr) {
+		return "", "", fmt.Errorf("runtime expression %q must start with $", expr)
+	}
+	end := len(expr)
+	if i := strings.IndexAny(expr, ".#"); i >= 0 {
+		end = i
+	}
+	source, rest := expr[:end], expr[end:]
+	switch source {
+	case "$url", "$method", "$statusCode":
+		if rest != "" {
+			return "", "", fmt.Errorf("runtime expression %q: %s takes no path", expr, source)
+		}
+		return source, "", nil
+	case "$steps":
+		if !strings.HasPrefix(rest, ".") {
+			return "", "", fmt.Errorf("runtime expression %q: $steps needs a step ID", expr)
+		}
+		rest = rest[1:]
+		if id, output, ok := strings.Cut(rest, ".outputs."); !ok || id == "" || output == "" || strings.Contains(id, ".") {
+			return "", "", fmt.Errorf("runtime expression %q must have the form $steps.<stepId>.outputs.<name>", expr)
+		}
+		return source, rest, nil
+	case "$inputs", "$request", "$response":
+		if !strings.HasPrefix(rest, ".") || len(rest) == 1 {
+			return "", "", fmt.Errorf("runtime expression %q: %s needs a name", expr, source)
+		}
+		rest = rest[1:]
+		if source != "$inputs" {
+			if err := validateMessageReference(rest, source == "$response"); err != nil {
+				return "", "", fmt.Errorf("runtime expression %q: %v", expr, err)
+			}
+		}
+		return source, rest, nil
+	}
+	return "", "", fmt.Errorf("runtime expression %q has unknown source %s", expr, source)
+}
+
+func validateMessageReference(ref string, response bool) error {
+	kind, name, _ := strings.Cut(ref, ".")
+	switch {
+	case kind == "body" || strings.HasPrefix(kind, "body#"):
+		return nil
+	case kind == "header" && name != "":
+		return nil
+	case (kind == "query" || kind == "path") && name != "" && !response:
+		return nil
+	}
+	return fmt.Errorf("unsupported reference %q", ref)
+}
+
+func resolveMessage(ref string, header http.Header, query url.Values, path map[string]string, body any) (any, error) {
+	kind, name, _ := strings.Cut(ref, ".")
+	switch {
+	case kind == "body":
+		return body, nil
+	case strings.HasPrefix(kind, "body#"):
+		return resolvePointer(body, strings.TrimPrefix(ref, "body#"))
+	case kind == "header":
+		if v := header.Values(name); len(v) > 0 {
+			return v[0], nil
+		}
+	case kind == "query":
+		if v, ok := query[name]; ok && len(v) > 0 {
+			return v[0], nil
+		}
+	case kind == "path":
+		if v, ok := path[name]; ok {
+			return v, nil
+		}
+	}
+	return nil, nil
+}
+
+// lookupPath resolves "name", "name.field" or "name#/pointer" in values.
+func lookupPath(values map[string]any, ref string) (any, error) {
+	name, pointer, hasPointer := strings.Cut(ref, "#")
+	parts := strings.Split(name, ".")
+	var current any = values
+	for _, part := range parts {
+		m, ok := current.(map[string]any)
+		if !ok {
+			return nil, nil
+		}
+		current = m[part]
+	}
+	if hasPointer {
+		return resolvePointer(current, pointer)
+	}
+	return current, nil
+}
+
+// resolvePointer resolves a JSON pointer in a decoded JSON value.
+func resolvePointer(doc any, pointer string) (any, error) {
+	if pointer == "" {
+		return doc, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("JSON pointer %q must start with /", pointer)
+	}
+	current := doc
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		switch v := current.(type) {
+		case map[string]any:
+			next, ok := v[token]
+			if !ok {
+				return nil, nil
+			}
+			current = next
+		case []any:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, nil
+			}
+			current = v[i]
+		default:
+			return nil, nil
+		}
+	}
+	return current, nil
+}
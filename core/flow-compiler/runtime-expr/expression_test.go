@@ -0,0 +1,106 @@
+package runtimeexpr
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestEvalContext_Resolve(t *testing.T) {
+	ec := &EvalContext{
+		Inputs: map[string]any{"username": "ada", "user": map[string]any{"name": "Ada"}},
+		Steps: map[string]map[string]any{
+			"getUser": {"id": float64(42), "tags": []any{"a", "b"}},
+		},
+		StatusCode:     201,
+		ResponseHeader: http.Header{"Location": {"/users/42"}},
+		ResponseBody:   map[string]any{"data": []any{map[string]any{"id": "first"}}},
+	}
+	tests := map[string]any{
+		"$inputs.username":               "ada",
+		"$inputs.user.name":              "Ada",
+		"$inputs.missing":                nil,
+		"$steps.getUser.outputs.id":      float64(42),
+		"$steps.getUser.outputs.tags#/1": "b",
+		"$steps.other.outputs.id":        nil,
+		"$response.body#/data/0/id":      "first",
+		"$response.header.Location":      "/users/42",
+		"$statusCode":                    201,
+	}
+	for expr, want := range tests {
+		got, err := ec.Resolve(expr)
+		if err != nil {
+			t.Errorf("Resolve(%q) failed: %v", expr, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Resolve(%q) = %#v, want %#v", expr, got, want)
+		}
+	}
+
+	for _, expr := range []string{"$steps", "$steps.getUser", "$steps.getUser.id", "$steps..outputs.id", "$input.username"} {
+		if err := ValidateExpression(expr); err == nil {
+			t.Errorf("Expected %q to be invalid", expr)
+		}
+	}
+}
+
+func TestEvalContext_ResolveValue(t *testing.T) {
+	ec := &EvalContext{
+		Inputs: map[string]any{"token": "secret", "ids": []any{float64(1), float64(2)}},
+		Steps:  map[string]map[string]any{"login": {"session": "s-1"}},
+	}
+	value := map[string]any{
+		"auth":    "Bearer {$inputs.token}",
+		"ids":     "$inputs.ids",
+		"nested":  []any{"$steps.login.outputs.session", "plain", float64(3)},
+		"missing": "id={$inputs.nope};",
+	}
+	got, err := ec.ResolveValue(value)
+	if err != nil {
+		t.Fatalf("ResolveValue failed: %v", err)
+	}
+	want := map[string]any{
+		"auth":    "Bearer secret",
+		"ids":     []any{float64(1), float64(2)},
+		"nested":  []any{"s-1", "plain", float64(3)},
+		"missing": "id=;",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ResolveValue = %#v, want %#v", got, want)
+	}
+
+	exprs := Expressions(value)
+	wantExprs := []string{"$inputs.token", "$inputs.ids", "$inputs.nope", "$steps.login.outputs.session"}
+	if !reflect.DeepEqual(exprs, wantExprs) {
+		t.Errorf("Expressions = %v, want %v", exprs, wantExprs)
+	}
+	if id, output, ok := StepOutput("$steps.login.outputs.session#/0"); !ok || id != "login" || output != "session" {
+		t.Errorf("StepOutput = %q, %q, %v", id, output, ok)
+	}
+	if name, ok := InputName("$inputs.user.name"); !ok || name != "user" {
+		t.Errorf("InputName = %q, %v", name, ok)
+	}
+}
+
+func TestSetPointer(t *testing.T) {
+	doc := map[string]any{"user": map[string]any{"name": "old"}, "tags": []any{"a"}}
+	var err error
+	var got any = doc
+	for target, value := range map[string]any{"/user/name": "new", "/tags/-": "b", "/meta/source": "mcp"} {
+		if got, err = SetPointer(got, target, value); err != nil {
+			t.Fatalf("SetPointer(%s) failed: %v", target, err)
+		}
+	}
+	want := map[string]any{
+		"user": map[string]any{"name": "new"},
+		"tags": []any{"a", "b"},
+		"meta": map[string]any{"source": "mcp"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SetPointer = %#v, want %#v", got, want)
+	}
+	if _, err := SetPointer(doc, "/tags/5", "x"); err == nil {
+		t.Error("Expected an out of range error")
+	}
+}
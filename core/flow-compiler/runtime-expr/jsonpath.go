@@ -0,0 +1,242 @@
+package runtimeexpr
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsonPath is a parsed JSONPath query. The supported subset covers the root
+// ($) and current (@) node, .name, ['name'], [n], [*], .*, ..name and filters
+// [?(<condition>)] whose condition uses @ for the filtered node.
+type jsonPath struct {
+	relative bool
+	segments []jsonPathSegment
+}
+
+type jsonPathSegment struct {
+	kind   string // "child", "wildcard", "index", "descendant" or "filter"
+	name   string
+	index  int
+	filter *condNode
+}
+
+func parseJSONPath(src string) (*jsonPath, error) {
+	if src == "" || (src[0] != '$' && src[0] != '@') {
+		return nil, fmt.Errorf("JSONPath %q must start with $ or @", src)
+	}
+	p := &jsonPath{relative: src[0] == '@'}
+	for i := 1; i < len(src); {
+		switch {
+		case strings.HasPrefix(src[i:], ".."):
+			name, end := scanJSONPathName(src, i+2)
+			if name == "" {
+				return nil, fmt.Errorf("JSONPath %q: missing name after ..", src)
+			}
+			p.segments = append(p.segments, jsonPathSegment{kind: "descendant", name: name})
+			i = end
+		case src[i] == '.':
+			name, end := scanJSONPathName(src, i+1)
+			switch name {
+			case "":
+				return nil, fmt.Errorf("JSONPath %q: missing name after .", src)
+			case "*":
+				p.segments = append(p.segments, jsonPathSegment{kind: "wildcard"})
+			default:
+				p.segments = append(p.segments, jsonPathSegment{kind: "child", name: name})
+			}
+			i = end
+		case src[i] == '[':
+			end := matchingBracket(src, i)
+			if end < 0 {
+				return nil, fmt.Errorf("JSONPath %q: unterminated [", src)
+			}
+			seg, err := parseJSONPathBracket(strings.TrimSpace(src[i+1 : end]))
+			if err != nil {
+				return nil, fmt.Errorf("JSONPath %q: %v", src, err)
+			}
+			p.segments = append(p.segments, seg)
+			i = end + 1
+		default:
+			return nil, fmt.Errorf("JSONPath %q: unexpected %q", src, src[i])
+		}
+	}
+	return p, nil
+}
+
+func scanJSONPathName(src string, i int) (string, int) {
+	end := i
+	for end < len(src) && src[end] != '.' && src[end] != '[' {
+		end++
+	}
+	return src[i:end], end
+}
+
+// matchingBracket returns the index of the ] closing the [ at i.
+func matchingBracket(src string, i int) int {
+	depth := 0
+	var quote byte
+	for j := i; j < len(src); j++ {
+		c := src[j]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return -1
+}
+
+func parseJSONPathBracket(inner string) (jsonPathSegment, error) {
+	switch {
+	case inner == "*":
+		return jsonPathSegment{kind: "wildcard"}, nil
+	case strings.HasPrefix(inner, "?"):
+		expr := strings.TrimSpace(inner[1:])
+		if strings.HasPrefix(expr, "(") && strings.HasSuffix(expr, ")") {
+			expr = expr[1 : len(expr)-1]
+		}
+		filter, err := parseCondition(expr)
+		if err != nil {
+			return jsonPathSegment{}, err
+		}
+		for _, ref := range filter.refs() {
+			if _, err := parseJSONPath(ref); err != nil {
+				return jsonPathSegment{}, err
+			}
+		}
+		return jsonPathSegment{kind: "filter", filter: filter}, nil
+	case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+		return jsonPathSegment{kind: "child", name: inner[1 : len(inner)-1]}, nil
+	}
+	n, err := strconv.Atoi(inner)
+	if err != nil {
+		return jsonPathSegment{}, fmt.Errorf("unsupported selector [%s]", inner)
+	}
+	return jsonPathSegment{kind: "index", index: n}, nil
+}
+
+// query returns the nodes selected from current, with root resolving $ inside
+// filters.
+func (p *jsonPath) query(root, current any) ([]any, error) {
+	nodes := []any{root}
+	if p.relative {
+		nodes = []any{current}
+	}
+	for _, seg := range p.segments {
+		var next []any
+		for _, node := range nodes {
+			selected, err := seg.apply(root, node)
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, selected...)
+		}
+		nodes = next
+	}
+	return nodes, nil
+}
+
+func (s jsonPathSegment) apply(root, node any) ([]any, error) {
+	switch s.kind {
+	case "child":
+		if m, ok := node.(map[string]any); ok {
+			if v, ok := m[s.name]; ok {
+				return []any{v}, nil
+			}
+		}
+		return nil, nil
+	case "wildcard":
+		return jsonChildren(node), nil
+	case "index":
+		if a, ok := node.([]any); ok {
+			i := s.index
+			if i < 0 {
+				i += len(a)
+			}
+			if i >= 0 && i < len(a) {
+				return []any{a[i]}, nil
+			}
+		}
+		return nil, nil
+	case "descendant":
+		var out []any
+		var walk func(any)
+		walk = func(n any) {
+			if m, ok := n.(map[string]any); ok && s.name != "*" {
+				if v, ok := m[s.name]; ok {
+					out = append(out, v)
+				}
+			}
+			for _, c := range jsonChildren(n) {
+				if s.name == "*" {
+					out = append(out, c)
+				}
+				walk(c)
+			}
+		}
+		walk(node)
+		return out, nil
+	case "filter":
+		// Arrays are filtered element by element; any other node is tested itself,
+		// so "$[?(@.status == 'ok')]" applies to a response object as a whole.
+		candidates := []any{node}
+		if a, ok := node.([]any); ok {
+			candidates = a
+		}
+		var out []any
+		for _, c := range candidates {
+			ok, err := s.filter.eval(func(ref string) ([]any, error) {
+				path, err := parseJSONPath(ref)
+				if err != nil {
+					return nil, err
+				}
+				return path.query(root, c)
+			})
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				out = append(out, c)
+			}
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("unknown JSONPath segment %s", s.kind)
+}
+
+// jsonChildren returns the array elements or the object member values of node,
+// members in key order.
+func jsonChildren(node any) []any {
+	switch v := node.(type) {
+	case []any:
+		return v
+	case map[string]any:
+		out := make([]any, 0, len(v))
+		for _, k := range sortedKeys(v) {
+			out = append(out, v[k])
+		}
+		return out
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
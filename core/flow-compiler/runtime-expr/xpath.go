@@ -0,0 +1,386 @@
+package runtimeexpr
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// xpathExpr is a parsed XPath expression. The supported subset is a location
+// path, count(path), or either compared with a literal, where a location path
+// is made of /, //, name, *, @name, text(), . and .. steps with [n],
+// [@attr], [@attr='v'], [name] and [name='v'] predicates.
+type xpathExpr struct {
+	count   bool
+	path    []xpathStep
+	op      string
+	literal any
+}
+
+type xpathStep struct {
+	axis       string // "child", "descendant", "self" or "parent"
+	test       string // element name, "*", "@name" or "text()"
+	predicates []xpathPredicate
+}
+
+type xpathPredicate struct {
+	index int
+	test  string // "@name" or element name; empty for an index
+	value *string
+}
+
+// xmlNode is an element, or an attribute or text value selected by a path.
+type xmlNode struct {
+	name     string
+	attrs    map[string]string
+	children []*xmlNode
+	parent   *xmlNode
+	text     string
+	value    *string
+}
+
+func parseXPath(src string) (*xpathExpr, error) {
+	expr := &xpathExpr{}
+	left := strings.TrimSpace(src)
+	if i, op := findXPathOperator(left); i >= 0 {
+		lit := strings.TrimSpace(left[i+len(op):])
+		left = strings.TrimSpace(left[:i])
+		expr.op = op
+		if op == "=" {
+			expr.op = "=="
+		}
+		switch {
+		case len(lit) >= 2 && (lit[0] == '\'' || lit[0] == '"') && lit[len(lit)-1] == lit[0]:
+			expr.literal = lit[1 : len(lit)-1]
+		default:
+			n, err := strconv.ParseFloat(lit, 64)
+			if err != nil {
+				return nil, fmt.Errorf("XPath %q: invalid literal %q", src, lit)
+			}
+			expr.literal = n
+		}
+	}
+	if strings.HasPrefix(left, "count(") && strings.HasSuffix(left, ")") {
+		expr.count = true
+		left = strings.TrimSpace(left[len("count(") : len(left)-1])
+	}
+	path, err := parseXPathPath(left)
+	if err != nil {
+		return nil, fmt.Errorf("XPath %q: %v", src, err)
+	}
+	expr.path = path
+	return expr, nil
+}
+
+// findXPathOperator returns the position of the top-level comparison operator.
+func findXPathOperator(src string) (int, string) {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[' || c == '(':
+			depth++
+		case c == ']' || c == ')':
+			depth--
+		case depth == 0:
+			for _, op := range []string{"!=", "<=", ">=", "=", "<", ">"} {
+				if strings.HasPrefix(src[i:], op) {
+					return i, op
+				}
+			}
+		}
+	}
+	return -1, ""
+}
+
+func parseXPathPath(src string) ([]xpathStep, error) {
+	if src == "" {
+		return nil, fmt.Errorf("empty path")
+	}
+	var steps []xpathStep
+	axis := "child"
+	for i := 0; i < len(src); {
+		switch {
+		case strings.HasPrefix(src[i:], "//"):
+			axis = "descendant"
+			i += 2
+			continue
+		case src[i] == '/':
+			axis = "child"
+			i++
+			continue
+		}
+		end := i
+		depth := 0
+		for end < len(src) && (depth > 0 || src[end] != '/') {
+			switch src[end] {
+			case '[':
+				depth++
+			case ']':
+				depth--
+			}
+			end++
+		}
+		step, err := parseXPathStep(src[i:end], axis)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+		i = end
+	}
+	return steps, nil
+}
+
+func parseXPathStep(src, axis string) (xpathStep, error) {
+	test := src
+	var preds []string
+	if i := strings.IndexByte(src, '['); i >= 0 {
+		test = src[:i]
+		rest := src[i:]
+		for rest != "" {
+			end := matchingBracket(rest, 0)
+			if end < 0 || rest[0] != '[' {
+				return xpathStep{}, fmt.Errorf("invalid predicate in %q", src)
+			}
+			preds = append(preds, strings.TrimSpace(rest[1:end]))
+			rest = rest[end+1:]
+		}
+	}
+	step := xpathStep{axis: axis, test: test}
+	switch test {
+	case ".":
+		step.axis = "self"
+	case "..":
+		step.axis = "parent"
+	case "":
+		return xpathStep{}, fmt.Errorf("empty step in %q", src)
+	case "*", "text()":
+	default:
+		if !isXPathName(strings.TrimPrefix(test, "@")) {
+			return xpathStep{}, fmt.Errorf("unsupported step %q, expected a name, *, @name, text(), . or ..", test)
+		}
+	}
+	for _, p := range preds {
+		pred, err := parseXPathPredicate(p)
+		if err != nil {
+			return xpathStep{}, err
+		}
+		step.predicates = append(step.predicates, pred)
+	}
+	return step, nil
+}
+
+func parseXPathPredicate(src string) (xpathPredicate, error) {
+	if n, err := strconv.Atoi(src); err == nil {
+		if n < 1 {
+			return xpathPredicate{}, fmt.Errorf("predicate [%s] must be a positive index", src)
+		}
+		return xpathPredicate{index: n}, nil
+	}
+	test, lit, hasValue := strings.Cut(src, "=")
+	pred := xpathPredicate{test: strings.TrimSpace(test)}
+	if hasValue {
+		lit = strings.TrimSpace(lit)
+		if len(lit) < 2 || (lit[0] != '\'' && lit[0] != '"') || lit[len(lit)-1] != lit[0] {
+			return xpathPredicate{}, fmt.Errorf("predicate [%s] must compare with a quoted string", src)
+		}
+		v := lit[1 : len(lit)-1]
+		pred.value = &v
+	}
+	if !isXPathName(strings.TrimPrefix(pred.test, "@")) {
+		return xpathPredicate{}, fmt.Errorf("unsupported predicate [%s], expected [n], [@name], [name] or either compared with = to a quoted string", src)
+	}
+	return pred, nil
+}
+
+// isXPathName reports whether s is a plain element or attribute name, rather
+// than a function call, operator or other XPath the subset does not cover.
+func isXPathName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range s {
+		switch {
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c > 0x7f:
+		case i > 0 && (c == '-' || c == '.' || c >= '0' && c <= '9'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// parseXML reads a document into a tree below a synthetic document node.
+func parseXML(data string) (*xmlNode, error) {
+	doc := &xmlNode{}
+	current := doc
+	dec := xml.NewDecoder(strings.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &xmlNode{name: t.Name.Local, attrs: map[string]string{}, parent: current}
+			for _, a := range t.Attr {
+				n.attrs[a.Name.Local] = a.Value
+			}
+			current.children = append(current.children, n)
+			current = n
+		case xml.EndElement:
+			if current.parent != nil {
+				current = current.parent
+			}
+		case xml.CharData:
+			current.text += string(t)
+		}
+	}
+	if len(doc.children) == 0 {
+		return nil, fmt.Errorf("no XML element found")
+	}
+	return doc, nil
+}
+
+func (n *xmlNode) stringValue() string {
+	if n.value != nil {
+		return *n.value
+	}
+	var b strings.Builder
+	b.WriteString(n.text)
+	for _, c := range n.children {
+		b.WriteString(c.stringValue())
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func (n *xmlNode) descendants() []*xmlNode {
+	var out []*xmlNode
+	for _, c := range n.children {
+		out = append(out, c)
+		out = append(out, c.descendants()...)
+	}
+	return out
+}
+
+// evaluate applies the expression to a document and reports whether it holds:
+// a path holds when it selects a node, a comparison when any selected node
+// satisfies it.
+func (e *xpathExpr) evaluate(doc *xmlNode) bool {
+	nodes := []*xmlNode{doc}
+	for _, step := range e.path {
+		var next []*xmlNode
+		for _, n := range nodes {
+			next = append(next, step.apply(n)...)
+		}
+		nodes = next
+	}
+	if e.count {
+		return compareXPath(e.op, float64(len(nodes)), e.literal, e.op == "")
+	}
+	if e.op == "" {
+		return len(nodes) > 0
+	}
+	for _, n := range nodes {
+		if compareXPath(e.op, n.stringValue(), e.literal, false) {
+			return true
+		}
+	}
+	return false
+}
+
+func compareXPath(op string, value, literal any, exists bool) bool {
+	if exists {
+		n, _ := value.(float64)
+		return n > 0
+	}
+	if lit, ok := literal.(float64); ok {
+		v, ok := toNumber(value)
+		return ok && compareOrdered(op, v, lit)
+	}
+	return compareOrdered(op, fmt.Sprint(value), fmt.Sprint(literal))
+}
+
+func (s xpathStep) apply(n *xmlNode) []*xmlNode {
+	var candidates []*xmlNode
+	switch s.axis {
+	case "self":
+		return filterXPath([]*xmlNode{n}, s.predicates)
+	case "parent":
+		if n.parent == nil {
+			return nil
+		}
+		return filterXPath([]*xmlNode{n.parent}, s.predicates)
+	case "descendant":
+		candidates = append([]*xmlNode{n}, n.descendants()...)
+	default:
+		candidates = []*xmlNode{n}
+	}
+
+	var out []*xmlNode
+	for _, c := range candidates {
+		var matched []*xmlNode
+		switch {
+		case strings.HasPrefix(s.test, "@"):
+			if v, ok := c.attrs[s.test[1:]]; ok {
+				matched = append(matched, &xmlNode{parent: c, value: &v})
+			}
+		case s.test == "text()":
+			if t := strings.TrimSpace(c.text); t != "" {
+				matched = append(matched, &xmlNode{parent: c, value: &t})
+			}
+		default:
+			for _, child := range c.children {
+				if s.test == "*" || child.name == s.test {
+					matched = append(matched, child)
+				}
+			}
+		}
+		out = append(out, filterXPath(matched, s.predicates)...)
+	}
+	return out
+}
+
+func filterXPath(nodes []*xmlNode, predicates []xpathPredicate) []*xmlNode {
+	for _, p := range predicates {
+		var kept []*xmlNode
+		for i, n := range nodes {
+			if p.test == "" {
+				if i+1 == p.index {
+					kept = append(kept, n)
+				}
+				continue
+			}
+			if p.matches(n) {
+				kept = append(kept, n)
+			}
+		}
+		nodes = kept
+	}
+	return nodes
+}
+
+func (p xpathPredicate) matches(n *xmlNode) bool {
+	if strings.HasPrefix(p.test, "@") {
+		v, ok := n.attrs[p.test[1:]]
+		return ok && (p.value == nil || v == *p.value)
+	}
+	for _, c := range n.children {
+		if c.name == p.test && (p.value == nil || c.stringValue() == *p.value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package flowcompiler
+
+import (
+	"strings"
+)
+
+// DefinitionsRefPrefix is the prefix of references into Endpoint.Definitions.
+const DefinitionsRefPrefix = "#/$defs/"
+
+// componentRefPrefixes are the locations of named schemas in OpenAPI 3.x and Swagger 2.0.
+var componentRefPrefixes = []string{"#/components/schemas/", "#/definitions/"}
+
+// schemaBundler rewrites references to named schemas into "#/$defs/<name>" and
+// collects the definitions they need, so every endpoint schema is self-contained.
+type schemaBundler struct {
+	components map[string]interface{}
+	defs       map[string]interface{}
+}
+
+func newSchemaBundler(components map[string]interface{}) *schemaBundler {
+	return &schemaBundler{components: components, defs: map[string]interface{}{}}
+}
+
+func (b *schemaBundler) rewrite(schema interface{}) interface{} {
+	switch t := schema.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			if ref, ok := v.(string); ok && k == "$ref" {
+				out[k] = b.ref(ref)
+				continue
+			}
+			out[k] = b.rewrite(v)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, v := range t {
+			out[i] = b.rewrite(v)
+		}
+		return out
+	default:
+		return schema
+	}
+}
+
+func (b *schemaBundler) ref(ref string) string {
+	for _, prefix := range componentRefPrefixes {
+		if !strings.HasPrefix(ref, prefix) {
+			continue
+		}
+		name := strings.TrimPrefix(ref, prefix)
+		if _, seen := b.defs[name]; !seen {
+			if def, ok := b.components[name]; ok {
+				// Reserve the name first so recursive schemas terminate.
+				b.defs[name] = nil
+				b.defs[name] = b.rewrite(def)
+			}
+		}
+		return DefinitionsRefPrefix + name
+	}
+	return ref
+}
+
+// definitions returns the collected definitions, or nil when there are none.
+func (b *schemaBundler) definitions() map[string]interface{} {
+	if len(b.defs) == 0 {
+		return nil
+	}
+	return b.defs
+}
@@ -0,0 +1,153 @@
+package flowcompiler
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"MCPGen/core/openapi-loader"
+)
+
+// CollisionPolicy decides what MergeServices does with two operations of the
+// same service that share an operationId, or a method and path.
+type CollisionPolicy string
+
+const (
+	// CollisionFail rejects the specs; it is the default.
+	CollisionFail CollisionPolicy = "error"
+	// CollisionKeepFirst keeps the operation loaded first and drops the other.
+	CollisionKeepFirst CollisionPolicy = "first"
+	// CollisionRename keeps both operations, suffixing the operationId of the
+	// one loaded later with _2, _3, ...
+	CollisionRename CollisionPolicy = "rename"
+)
+
+// serviceNamePattern matches explicit service names. Dots are excluded since
+// they separate the service from the operationId in step references.
+var serviceNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// Service is a loaded spec and the namespace its operations are qualified
+// with, as in "UserService.validateInput".
+type Service struct {
+	// Name is derived from the spec by ServiceName when empty.
+	Name string
+	Spec *openapiloader.UnifiedAPISpec
+}
+
+// ServiceName derives the namespace of a spec from its title, or from the name
+// of the file it was loaded from: "User Service" becomes "UserService".
+func ServiceName(spec *openapiloader.UnifiedAPISpec) string {
+	source := spec.Title
+	if strings.TrimSpace(source) == "" {
+		base := filepath.Base(spec.Source)
+		source = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	var b strings.Builder
+	upper := true
+	for _, r := range source {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	name := b.String()
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		name = "Service" + name
+	}
+	return name
+}
+
+// MergeServices converts the specs of every service into endpoints qualified
+// with the service name. Services sharing a name are merged into one. Within a
+// service, operations sharing an operationId or a method and path collide and
+// are handled according to policy; the notes describe what was dropped or
+// renamed.
+func MergeServices(services []Service, policy CollisionPolicy) ([]Endpoint, []string, error) {
+	switch policy {
+	case "", CollisionFail, CollisionKeepFirst, CollisionRename:
+	default:
+		return nil, nil, fmt.Errorf("unknown collision policy '%s'", policy)
+	}
+
+	var (
+		endpoints []Endpoint
+		notes     []string
+		errs      []error
+		// byID and byRoute hold the source of the operations seen so far.
+		byID    = map[string]string{}
+		byRoute = map[string]string{}
+	)
+	for _, svc := range services {
+		name := svc.Name
+		if name == "" {
+			name = ServiceName(svc.Spec)
+		} else if !serviceNamePattern.MatchString(name) {
+			errs = append(errs, fmt.Errorf("invalid service name '%s' for %s", name, svc.Spec.Source))
+			continue
+		}
+		for _, ep := range EndpointsFromSpec(svc.Spec) {
+			ep.Service = name
+			route := name + " " + strings.ToUpper(ep.Method) + " " + ep.Path
+			idKey := name + "." + ep.ID
+
+			if prev, ok := byRoute[route]; ok {
+				msg := fmt.Sprintf("service '%s': %s %s is defined by %s and %s", name, strings.ToUpper(ep.Method), ep.Path, prev, sourceOf(ep))
+				switch policy {
+				case CollisionKeepFirst:
+					notes = append(notes, msg+", keeping the first")
+					continue
+				case CollisionRename:
+				default:
+					errs = append(errs, errors.New(msg))
+					continue
+				}
+			}
+			if prev, ok := byID[idKey]; ep.ID != "" && ok {
+				msg := fmt.Sprintf("service '%s': operationId '%s' is defined by %s and %s", name, ep.ID, prev, sourceOf(ep))
+				switch policy {
+				case CollisionKeepFirst:
+					notes = append(notes, msg+", keeping the first")
+					continue
+				case CollisionRename:
+					renamed := ep.ID
+					for i := 2; byID[name+"."+renamed] != ""; i++ {
+						renamed = ep.ID + "_" + strconv.Itoa(i)
+					}
+					notes = append(notes, fmt.Sprintf("%s, renaming the second to '%s'", msg, renamed))
+					ep.ID = renamed
+					idKey = name + "." + renamed
+				default:
+					errs = append(errs, errors.New(msg))
+					continue
+				}
+			}
+			if _, ok := byRoute[route]; !ok {
+				byRoute[route] = sourceOf(ep)
+			}
+			if ep.ID != "" {
+				byID[idKey] = sourceOf(ep)
+			}
+			endpoints = append(endpoints, ep)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, nil, errors.Join(errs...)
+	}
+	return endpoints, notes, nil
+}
+
+func sourceOf(ep Endpoint) string {
+	if ep.Source == "" {
+		return "an in-memory spec"
+	}
+	return ep.Source
+}
@@ -0,0 +1,124 @@
+package flowcompiler
+
+import (
+	"strings"
+	"testing"
+
+	"MCPGen/core/openapi-loader"
+)
+
+func serviceSpec(title, source string, ops ...string) *openapiloader.UnifiedAPISpec {
+	spec := &openapiloader.UnifiedAPISpec{Title: title, Source: source}
+	for _, op := range ops {
+		id, route, _ := strings.Cut(op, " ")
+		method, path, _ := strings.Cut(route, " ")
+		spec.Endpoints = append(spec.Endpoints, openapiloader.APIEndpoint{Operation: id, Method: method, Path: path})
+	}
+	return spec
+}
+
+func TestServiceName(t *testing.T) {
+	tests := map[string]*openapiloader.UnifiedAPISpec{
+		"UserService":     {Title: "User Service"},
+		"SwaggerPetstore": {Title: "swagger petstore", Source: "specs/pets.yaml"},
+		"BillingApi":      {Source: "specs/billing-api.yaml"},
+		"Service3DAPI":    {Title: "3D API"},
+	}
+	for want, spec := range tests {
+		if got := ServiceName(spec); got != want {
+			t.Errorf("ServiceName(%+v) = %q, want %q", spec, got, want)
+		}
+	}
+}
+
+func TestMergeServices_QualifiesOperations(t *testing.T) {
+	endpoints, notes, err := MergeServices([]Service{
+		{Spec: serviceSpec("User Service", "users.yaml", "getUser GET /users/{id}", "health GET /health")},
+		{Name: "billing", Spec: serviceSpec("Billing", "billing.yaml", "getUser GET /accounts/{id}", "health GET /health")},
+	}, "")
+	if err != nil {
+		t.Fatalf("MergeServices failed: %v", err)
+	}
+	if len(endpoints) != 4 || len(notes) != 0 {
+		t.Fatalf("Expected 4 endpoints without notes, got %d and %v", len(endpoints), notes)
+	}
+	if endpoints[0].Service != "UserService" || endpoints[2].Service != "billing" {
+		t.Errorf("Unexpected services %q and %q", endpoints[0].Service, endpoints[2].Service)
+	}
+
+	flows := []FlowDefinition{{
+		WorkflowID: "wf",
+		Steps: []FlowStep{
+			{ID: "user", Call: "UserService.getUser"},
+			{ID: "account", Call: "billing.getUser"},
+		},
+	}}
+	compiled, err := NewFlowCompiler(endpoints, flows).Compile()
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	if got := compiled[0].Steps[0].Endpoint.Path; got != "/users/{id}" {
+		t.Errorf("Expected UserService.getUser to resolve to /users/{id}, got %s", got)
+	}
+	if got := compiled[0].Steps[1].Endpoint.Path; got != "/accounts/{id}" {
+		t.Errorf("Expected billing.getUser to resolve to /accounts/{id}, got %s", got)
+	}
+
+	flows[0].Steps = []FlowStep{{ID: "user", Call: "getUser"}}
+	_, err = NewFlowCompiler(endpoints, flows).Compile()
+	if err == nil || !strings.Contains(err.Error(), "qualify it as UserService.getUser, billing.getUser") {
+		t.Errorf("Expected an ambiguity error naming the qualified IDs, got %v", err)
+	}
+}
+
+func TestMergeServices_Collisions(t *testing.T) {
+	services := []Service{
+		{Name: "users", Spec: serviceSpec("Users", "users.yaml", "getUser GET /users/{id}", "listUsers GET /users")},
+		{Name: "users", Spec: serviceSpec("Users v2", "users-v2.yaml", "getUser GET /v2/users/{id}", "list GET /users")},
+	}
+
+	_, _, err := MergeServices(services, CollisionFail)
+	if err == nil {
+		t.Fatal("Expected collisions to fail")
+	}
+	for _, want := range []string{
+		"service 'users': operationId 'getUser' is defined by users.yaml and users-v2.yaml",
+		"service 'users': GET /users is defined by users.yaml and users-v2.yaml",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to contain %q, got %v", want, err)
+		}
+	}
+
+	endpoints, notes, err := MergeServices(services, CollisionKeepFirst)
+	if err != nil {
+		t.Fatalf("MergeServices failed: %v", err)
+	}
+	if len(endpoints) != 2 || len(notes) != 2 {
+		t.Errorf("Expected the first two endpoints and two notes, got %d and %v", len(endpoints), notes)
+	}
+
+	endpoints, notes, err = MergeServices(services, CollisionRename)
+	if err != nil {
+		t.Fatalf("MergeServices failed: %v", err)
+	}
+	var ids []string
+	for _, ep := range endpoints {
+		ids = append(ids, ep.ID)
+	}
+	if strings.Join(ids, ",") != "getUser,listUsers,getUser_2,list" {
+		t.Errorf("Unexpected IDs %v", ids)
+	}
+	if len(notes) != 1 || !strings.Contains(notes[0], "renaming the second to 'getUser_2'") {
+		t.Errorf("Unexpected notes %v", notes)
+	}
+
+	_, _, err = MergeServices([]Service{{Name: "user.service", Spec: serviceSpec("", "u.yaml")}}, "")
+	if err == nil || !strings.Contains(err.Error(), "invalid service name") {
+		t.Errorf("Expected an invalid service name error, got %v", err)
+	}
+	_, _, err = MergeServices(nil, "overwrite")
+	if err == nil {
+		t.Error("Expected an unknown policy to fail")
+	}
+}
@@ -1,16 +1,49 @@
 package flowcompiler
 
+import "fmt"
+
 // Endpoint represents a parsed API endpoint from OpenAPI/Swagger.
 type Endpoint struct {
 	ID          string
 	Path        string
 	Method      string
 	Summary     string
+	Description string
+	BaseURL     string
+	// Source is the file or URL of the spec the endpoint was loaded from.
+	Source string
+	// Service is the namespace qualifying ID, as in "UserService.getUser".
+	Service     string
 	Parameters  []Parameter
+	RequestBody *RequestBody
 	Responses   map[string]Response
+	// Definitions holds the named schemas referenced as "#/$defs/<name>".
+	Definitions map[string]interface{}
+	// Security lists the alternative ways to authenticate the call; each
+	// alternative needs all of its schemes. An empty alternative means the
+	// operation may be called anonymously.
+	Security [][]SecurityScheme
 	// ... other fields as needed
 }
 
+// SecurityScheme is a security scheme as required by an operation.
+type SecurityScheme struct {
+	// Name is the name of the scheme in the spec.
+	Name string
+	// Type is apiKey, http, oauth2, openIdConnect or mutualTLS; empty for a
+	// requirement naming a scheme the spec does not define.
+	Type string
+	// In and ParamName locate the key of an apiKey scheme.
+	In        string
+	ParamName string
+	// Scheme is the HTTP authentication scheme, e.g. basic or bearer.
+	Scheme string
+	// TokenURL is the token endpoint of an OAuth2 client-credentials flow.
+	TokenURL string
+	// Scopes are the OAuth2 scopes the operation requires.
+	Scopes []string
+}
+
 type Parameter struct {
 	Name     string
 	In       string
@@ -18,35 +51,180 @@ type Parameter struct {
 	Schema   interface{}
 }
 
+type RequestBody struct {
+	Required    bool
+	ContentType string
+	Schema      interface{}
+}
+
 type Response struct {
-	Code    string
-	Schema  interface{}
+	Code   string
+	Schema interface{}
 }
 
 // FlowDefinition represents a parsed Arazzo workflow.
 type FlowDefinition struct {
 	WorkflowID string
-	Steps      []FlowStep
-	// ... other workflow fields
+	// SourceFile is the Arazzo document the workflow was parsed from.
+	SourceFile string
+	// Location is the workflowId of the workflow in SourceFile.
+	Location    Location
+	Summary     string
+	Description string
+	// SourceDescriptions lists the API descriptions of the document the workflow belongs to.
+	SourceDescriptions []SourceDescription
+	// Inputs is the JSON Schema of the workflow inputs.
+	Inputs         map[string]interface{}
+	DependsOn      []string
+	Parameters     []StepParameter
+	Steps          []FlowStep
+	SuccessActions []Action
+	FailureActions []Action
+	// Outputs maps output names to runtime expressions.
+	Outputs    map[string]string
+	Components *Components
+}
+
+// Location is a position in a source document. Line and Column are 1-based
+// and zero when unknown.
+type Location struct {
+	File   string
+	Line   int
+	Column int
+}
+
+func (l Location) String() string {
+	switch {
+	case l.Line > 0:
+		return fmt.Sprintf("%s:%d:%d", l.File, l.Line, l.Column)
+	default:
+		return l.File
+	}
+}
+
+// SourceDescription names an API description that steps may reference.
+type SourceDescription struct {
+	Name string
+	URL  string
+	Type string
 }
 
 type FlowStep struct {
-	ID        string
-	Call      string
+	ID          string
+	Location    Location
+	Description string
+	// Call is the operationId of the step, as written in the document.
+	Call            string
+	OperationPath   string
+	WorkflowID      string
+	Parameters      []StepParameter
+	RequestBody     *StepRequestBody
+	SuccessCriteria []Criterion
+	OnSuccess       []Action
+	OnFailure       []Action
+	// Outputs maps output names to runtime expressions.
+	Outputs map[string]string
+	// DependsOn and Next add explicit edges to the step graph. A non-nil empty
+	// DependsOn marks a step without dependencies.
+	DependsOn []string
+	Next      []string
 	PreHook   string
 	PostHook  string
-	// ... other fields
+}
+
+// StepParameter is a parameter passed to a step's operation or workflow. When
+// Reference is set it points into the document components ("$components.parameters.x")
+// and Value, if any, overrides the referenced value.
+type StepParameter struct {
+	Name      string
+	In        string
+	Value     interface{}
+	Reference string
+}
+
+type StepRequestBody struct {
+	ContentType  string
+	Payload      interface{}
+	Replacements []PayloadReplacement
+}
+
+// PayloadReplacement sets the value at the JSON pointer Target of a step payload.
+type PayloadReplacement struct {
+	Target string
+	Value  interface{}
+}
+
+// Criterion is a success criterion or action criterion. Type is one of simple,
+// regex, jsonpath or xpath; Version qualifies jsonpath and xpath expressions.
+type Criterion struct {
+	Context   string
+	Condition string
+	Type      string
+	Version   string
+}
+
+// Action is a success or failure action. RetryAfter and RetryLimit only apply to
+// failure actions of type retry. When Reference is set the action is defined in
+// the document components ("$components.successActions.x").
+type Action struct {
+	Name       string
+	Type       string
+	WorkflowID string
+	StepID     string
+	RetryAfter *float64
+	RetryLimit *int
+	Criteria   []Criterion
+	Reference  string
+}
+
+// Components holds the reusable objects of an Arazzo document by name.
+type Components struct {
+	Inputs         map[string]interface{}
+	Parameters     map[string]StepParameter
+	SuccessActions map[string]Action
+	FailureActions map[string]Action
 }
 
 // CompiledFlow is the result of merging endpoints and workflows.
 type CompiledFlow struct {
 	WorkflowID string
+	// SourceFile is the Arazzo document the workflow was parsed from; hook
+	// files named by its steps are resolved against it.
+	SourceFile string
 	Steps      []CompiledStep
+	// Levels groups step IDs by topological level; the steps of a level only
+	// depend on earlier levels and may run concurrently.
+	Levels [][]string
+	// Inputs is the self-contained JSON Schema of the workflow inputs.
+	Inputs map[string]interface{}
+	// Outputs maps workflow output names to runtime expressions.
+	Outputs map[string]string
 }
 
 type CompiledStep struct {
-	StepID     string
-	Endpoint   *Endpoint
-	PreHook    string
-	PostHook   string
+	StepID string
+	// Endpoint is the operation the step calls; nil when it runs a nested workflow.
+	Endpoint *Endpoint
+	// WorkflowID is the nested workflow the step runs.
+	WorkflowID string
+	// DependsOn lists the steps that must finish before this one starts.
+	DependsOn []string
+	Level     int
+	// Parallel is set when other steps share the level of this step.
+	Parallel bool
+	// Parameters are the step and workflow parameters with component
+	// references resolved; values may be runtime expressions.
+	Parameters  []StepParameter
+	RequestBody *StepRequestBody
+	// Outputs maps output names to runtime expressions.
+	Outputs map[string]string
+	// SuccessCriteria must all hold for the step to succeed. Without criteria
+	// any status below 400 is a success.
+	SuccessCriteria []Criterion
+	// OnSuccess and OnFailure are the actions checked in order once the step
+	// succeeded or failed, workflow-level actions and components included.
+	OnSuccess []Action
+	OnFailure []Action
+	PreHook   string
+	PostHook  string
 }
@@ -0,0 +1,326 @@
+package linter
+
+import (
+	"MCPGen/core/diagnostics"
+	"MCPGen/core/flow-compiler"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// sourceDescriptionsPrefix starts references qualified with a source
+// description.
+const sourceDescriptionsPrefix = "$sourceDescriptions."
+
+// unusedSources reports the source descriptions of doc no workflow uses. A
+// source is used when an expression or reference names it. An unqualified
+// operationId searches every OpenAPI source, so it uses them all.
+func unusedSources(doc *document) diagnostics.List {
+	if len(doc.flows) == 0 {
+		return nil
+	}
+	var refs []string
+	unqualified := false
+	for i := range doc.flows {
+		flow := &doc.flows[i]
+		refs = append(refs, flowStrings(flow)...)
+		for _, step := range flow.Steps {
+			if step.Call != "" && !strings.HasPrefix(step.Call, sourceDescriptionsPrefix) {
+				unqualified = true
+			}
+		}
+	}
+
+	var out diagnostics.List
+	for i, sd := range doc.flows[0].SourceDescriptions {
+		if unqualified && (sd.Type == "" || sd.Type == "openapi") {
+			continue
+		}
+		if referenced(refs, sourceDescriptionsPrefix+sd.Name) {
+			continue
+		}
+		pointer := diagnostics.Pointer("sourceDescriptions", strconv.Itoa(i))
+		out = append(out, diagnostics.Diagnostic{
+			Rule:    RuleUnusedSource,
+			File:    doc.file,
+			Pointer: pointer,
+			Message: fmt.Sprintf("source description '%s' is not used by any step", sd.Name),
+		}.At(diagnostics.Lookup(doc.root, pointer)))
+	}
+	return out
+}
+
+// unusedOutputs reports the step outputs of flow that no expression of the
+// workflow reads.
+func unusedOutputs(doc *document, flow *flowcompiler.FlowDefinition) diagnostics.List {
+	exprs := flowStrings(flow)
+	var out diagnostics.List
+	for _, step := range flow.Steps {
+		prefix := "$steps." + step.ID + ".outputs."
+		names := make([]string, 0, len(step.Outputs))
+		for name := range step.Outputs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if readsOutput(exprs, prefix, name) {
+				continue
+			}
+			pointer, node := stepNode(doc.root, flow.WorkflowID, step.ID)
+			key, _ := entry(diagnostics.Lookup(doc.root, pointer+"/outputs"), name)
+			if key == nil {
+				key = node
+			}
+			out = append(out, diagnostics.Diagnostic{
+				Rule:    RuleUnusedOutput,
+				File:    doc.file,
+				Pointer: pointer + diagnostics.Pointer("outputs", name),
+				Message: fmt.Sprintf("output '%s' of step '%s' in workflow '%s' is never read", name, step.ID, flow.WorkflowID),
+			}.At(key))
+		}
+	}
+	return out
+}
+
+// unreachableSteps reports the steps of flow that never run. Levels run in
+// order, so a level is reachable when it is the first, when the level before
+// can complete without its actions ending the workflow or jumping elsewhere,
+// or when a goto action of a reachable level names one of its steps. Only
+// actions up to the first one without criteria can be taken; a failure that
+// no action handles ends the workflow.
+func unreachableSteps(doc *document, flow *flowcompiler.CompiledFlow) diagnostics.List {
+	if len(flow.Levels) == 0 {
+		return nil
+	}
+	levelOf := map[string]int{}
+	for i, level := range flow.Levels {
+		for _, id := range level {
+			levelOf[id] = i
+		}
+	}
+	steps := map[string]*flowcompiler.CompiledStep{}
+	for i := range flow.Steps {
+		steps[flow.Steps[i].StepID] = &flow.Steps[i]
+	}
+
+	reachable := make([]bool, len(flow.Levels))
+	reachable[0] = true
+	queue := []int{0}
+	visit := func(level int) {
+		if !reachable[level] {
+			reachable[level] = true
+			queue = append(queue, level)
+		}
+	}
+	for len(queue) > 0 {
+		level := queue[0]
+		queue = queue[1:]
+		continues := true
+		for _, id := range flow.Levels[level] {
+			step := steps[id]
+			if step == nil {
+				continue
+			}
+			success, fallsThrough := possibleActions(step.OnSuccess)
+			failure, _ := possibleActions(step.OnFailure)
+			for _, a := range append(success, failure...) {
+				if target, ok := levelOf[a.StepID]; ok && a.Type == "goto" && a.WorkflowID == "" {
+					visit(target)
+				}
+			}
+			// The actions of later steps are only applied when this one
+			// lets the level complete.
+			if !fallsThrough {
+				continues = false
+				break
+			}
+		}
+		if continues && level+1 < len(flow.Levels) {
+			visit(level + 1)
+		}
+	}
+
+	var out diagnostics.List
+	for i, level := range flow.Levels {
+		if reachable[i] {
+			continue
+		}
+		for _, id := range level {
+			pointer, node := stepNode(doc.root, flow.WorkflowID, id)
+			out = append(out, diagnostics.Diagnostic{
+				Rule:    RuleUnreachableStep,
+				File:    doc.file,
+				Pointer: pointer,
+				Message: fmt.Sprintf("step '%s' in workflow '%s' is never run", id, flow.WorkflowID),
+			}.At(node))
+		}
+	}
+	return out
+}
+
+// possibleActions returns the actions of a list that may be taken, and
+// whether the list may also take none, or a retry, and let the workflow go on.
+func possibleActions(actions []flowcompiler.Action) ([]flowcompiler.Action, bool) {
+	for i, a := range actions {
+		if len(a.Criteria) == 0 {
+			return actions[:i+1], a.Type == "retry"
+		}
+	}
+	return actions, true
+}
+
+// readsOutput reports whether an expression reads output name of the step
+// whose outputs are at prefix, "$steps.<id>.outputs.".
+func readsOutput(exprs []string, prefix, name string) bool {
+	for _, expr := range exprs {
+		for rest := expr; ; {
+			i := strings.Index(rest, prefix)
+			if i < 0 {
+				break
+			}
+			rest = rest[i+len(prefix):]
+			if strings.HasPrefix(rest, name) && !isNameChar(rest[len(name):]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// referenced reports whether an expression names the source description at
+// prefix, "$sourceDescriptions.<name>".
+func referenced(exprs []string, prefix string) bool {
+	for _, expr := range exprs {
+		for rest := expr; ; {
+			i := strings.Index(rest, prefix)
+			if i < 0 {
+				break
+			}
+			rest = rest[i+len(prefix):]
+			if !isNameChar(rest) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isNameChar reports whether s starts with a character of a step, output or
+// source description name.
+func isNameChar(s string) bool {
+	if s == "" {
+		return false
+	}
+	c := s[0]
+	return c == '_' || c == '-' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// flowStrings returns the references and runtime expressions of flow and of
+// the components of its document.
+func flowStrings(flow *flowcompiler.FlowDefinition) []string {
+	var out []string
+	out = parameterStrings(out, flow.Parameters)
+	out = actionStrings(out, flow.SuccessActions)
+	out = actionStrings(out, flow.FailureActions)
+	for _, expr := range flow.Outputs {
+		out = append(out, expr)
+	}
+	for _, step := range flow.Steps {
+		out = append(out, step.Call, step.OperationPath, step.WorkflowID)
+		out = parameterStrings(out, step.Parameters)
+		if step.RequestBody != nil {
+			out = collectStrings(out, step.RequestBody.Payload)
+			for _, r := range step.RequestBody.Replacements {
+				out = collectStrings(out, r.Value)
+			}
+		}
+		out = criteriaStrings(out, step.SuccessCriteria)
+		out = actionStrings(out, step.OnSuccess)
+		out = actionStrings(out, step.OnFailure)
+		for _, expr := range step.Outputs {
+			out = append(out, expr)
+		}
+	}
+	if c := flow.Components; c != nil {
+		for _, p := range c.Parameters {
+			out = collectStrings(out, p.Value)
+		}
+		for _, a := range c.SuccessActions {
+			out = actionStrings(out, []flowcompiler.Action{a})
+		}
+		for _, a := range c.FailureActions {
+			out = actionStrings(out, []flowcompiler.Action{a})
+		}
+	}
+	return out
+}
+
+func parameterStrings(out []string, params []flowcompiler.StepParameter) []string {
+	for _, p := range params {
+		out = collectStrings(out, p.Value)
+	}
+	return out
+}
+
+func actionStrings(out []string, actions []flowcompiler.Action) []string {
+	for _, a := range actions {
+		out = append(out, a.WorkflowID)
+		out = criteriaStrings(out, a.Criteria)
+	}
+	return out
+}
+
+func criteriaStrings(out []string, criteria []flowcompiler.Criterion) []string {
+	for _, c := range criteria {
+		out = append(out, c.Context, c.Condition)
+	}
+	return out
+}
+
+// collectStrings appends every string found in a decoded YAML value.
+func collectStrings(out []string, value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		out = append(out, v)
+	case []interface{}:
+		for _, item := range v {
+			out = collectStrings(out, item)
+		}
+	case map[string]interface{}:
+		for _, item := range v {
+			out = collectStrings(out, item)
+		}
+	}
+	return out
+}
+
+// stepNode returns the JSON pointer and node of step id of workflow in the
+// Arazzo document root, or the pointer of the workflow and its node when the
+// step is not found.
+func stepNode(root *yaml.Node, workflow, id string) (string, *yaml.Node) {
+	if root == nil {
+		return "", nil
+	}
+	workflows := diagnostics.Lookup(root, "/workflows")
+	if workflows == nil || workflows.Kind != yaml.SequenceNode {
+		return "", nil
+	}
+	for i, wf := range workflows.Content {
+		if _, wid := entry(wf, "workflowId"); wid == nil || wid.Value != workflow {
+			continue
+		}
+		pointer := diagnostics.Pointer("workflows", strconv.Itoa(i))
+		if _, steps := entry(wf, "steps"); steps != nil && steps.Kind == yaml.SequenceNode {
+			for j, step := range steps.Content {
+				if _, sid := entry(step, "stepId"); sid != nil && sid.Value == id {
+					return pointer + diagnostics.Pointer("steps", strconv.Itoa(j)), step
+				}
+			}
+		}
+		return pointer, wf
+	}
+	return "", nil
+}
@@ -0,0 +1,209 @@
+package linter
+
+import (
+	"MCPGen/core/arazzo-parser"
+	"MCPGen/core/diagnostics"
+	"MCPGen/core/flow-compiler"
+	"MCPGen/core/open-api-loader"
+	"errors"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Linter checks specs and Arazzo documents for what makes a poor MCP server:
+// problems that fail generation, reported as errors, and the configurable
+// Rules.
+type Linter struct {
+	// Loader loads the specs; a zero SpecLoader when nil.
+	Loader *open_api_loader.SpecLoader
+	// Config tunes the rules; every rule has its default severity when nil.
+	Config *Config
+	// Collisions is the policy operations sharing an operationId or route are
+	// merged with; CollisionFail when empty.
+	Collisions flowcompiler.CollisionPolicy
+}
+
+// Spec is a spec to lint and the service its operations belong to.
+type Spec struct {
+	// Service is derived from the spec by flowcompiler.ServiceName when empty.
+	Service string
+	// Path is a file, URL, directory or glob, or "-" for stdin.
+	Path string
+}
+
+// document is an Arazzo document that parsed.
+type document struct {
+	file  string
+	root  *yaml.Node
+	flows []flowcompiler.FlowDefinition
+}
+
+// Lint checks every spec and Arazzo document and returns the diagnostics
+// found, sorted. Workflows are only compiled, and checked for unreachable
+// steps, when all documents load.
+func (l *Linter) Lint(specs []Spec, arazzo []string) diagnostics.List {
+	loader := l.Loader
+	if loader == nil {
+		loader = open_api_loader.NewSpecLoader()
+	}
+	var (
+		out      diagnostics.List
+		services []flowcompiler.Service
+		failed   bool
+	)
+	for _, s := range specs {
+		paths, err := open_api_loader.ExpandSpecs(s.Path)
+		if err != nil {
+			out = append(out, diagnostics.FromError(s.Path, err)...)
+			failed = true
+			continue
+		}
+		for _, path := range paths {
+			spec, source, err := loader.LoadSpecSource(path)
+			var root yaml.Node
+			if source != nil && yaml.Unmarshal(source, &root) == nil {
+				out = append(out, l.filter(l.specRules(path, &root))...)
+			}
+			if err != nil {
+				out = append(out, diagnostics.FromError(path, err)...)
+				failed = true
+				continue
+			}
+			services = append(services, flowcompiler.Service{Name: s.Service, Spec: spec})
+		}
+	}
+
+	var endpoints []flowcompiler.Endpoint
+	if !failed {
+		policy := l.Collisions
+		if policy == "" {
+			policy = flowcompiler.CollisionFail
+		}
+		var notes []string
+		var err error
+		endpoints, notes, err = flowcompiler.MergeServices(services, policy)
+		if err != nil {
+			out = append(out, diagnostics.Diagnostic{Severity: diagnostics.SeverityError, Rule: RuleOperationCollision, Message: err.Error()})
+			failed = true
+		}
+		for _, note := range notes {
+			out = append(out, diagnostics.Diagnostic{Severity: diagnostics.SeverityWarning, Rule: RuleOperationCollision, Message: note})
+		}
+	}
+
+	var docs []document
+	for _, path := range arazzo {
+		doc, diags := readDocument(path)
+		out = append(out, diags...)
+		if doc == nil {
+			failed = true
+			continue
+		}
+		docs = append(docs, *doc)
+		out = append(out, l.filter(unusedSources(doc))...)
+		for i := range doc.flows {
+			out = append(out, l.filter(unusedOutputs(doc, &doc.flows[i]))...)
+		}
+	}
+
+	if !failed && len(docs) > 0 {
+		out = append(out, l.compile(endpoints, docs)...)
+	}
+	out.Sort()
+	return out
+}
+
+// readDocument validates and parses the Arazzo document at path. It returns
+// nil and the reasons when the document is not valid.
+func readDocument(path string) (*document, diagnostics.List) {
+	diags, err := arazzo_parser.Diagnose(path)
+	if err != nil {
+		return nil, diagnostics.FromError(path, err)
+	}
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	flows, err := arazzo_parser.ParseFlows(path)
+	if err != nil {
+		return nil, append(diags, diagnostics.FromError(path, err)...)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, append(diags, diagnostics.FromError(path, err)...)
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, append(diags, diagnostics.FromError(path, err)...)
+	}
+	return &document{file: path, root: &root, flows: flows}, diags
+}
+
+// compile resolves the workflows of docs against endpoints. Unresolved
+// references are reported where the step is; otherwise the compiled
+// workflows are checked for unreachable steps.
+func (l *Linter) compile(endpoints []flowcompiler.Endpoint, docs []document) diagnostics.List {
+	var flows []flowcompiler.FlowDefinition
+	for _, doc := range docs {
+		flows = append(flows, doc.flows...)
+	}
+	compiled, err := flowcompiler.NewFlowCompiler(endpoints, flows).Compile()
+	if err != nil {
+		return compileDiagnostics(err, docs)
+	}
+
+	// Compile keeps the order of the workflows.
+	var out diagnostics.List
+	i := 0
+	for d := range docs {
+		for range docs[d].flows {
+			out = append(out, l.filter(unreachableSteps(&docs[d], compiled[i]))...)
+			i++
+		}
+	}
+	return out
+}
+
+// compileDiagnostics reports the errors of Compile, located at the step they
+// are about when they are a *flowcompiler.ReferenceError.
+func compileDiagnostics(err error, docs []document) diagnostics.List {
+	errs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
+	var out diagnostics.List
+	for _, e := range errs {
+		d := diagnostics.Diagnostic{Severity: diagnostics.SeverityError, Rule: RuleFlowReference, Message: e.Error()}
+		var ref *flowcompiler.ReferenceError
+		if errors.As(e, &ref) {
+			d.File, d.Line, d.Column = ref.Location.File, ref.Location.Line, ref.Location.Column
+			d.Message = fmt.Sprintf("workflow '%s': %s", ref.WorkflowID, ref.Message)
+			if ref.StepID != "" {
+				d.Message = fmt.Sprintf("step '%s' in workflow '%s': %s", ref.StepID, ref.WorkflowID, ref.Message)
+			}
+			for _, doc := range docs {
+				if doc.file == d.File && d.Line > 0 {
+					d.Pointer = diagnostics.Locate(doc.root, d.Line, d.Column)
+				}
+			}
+		}
+		out = append(out, d)
+	}
+	return out
+}
+
+// filter gives each diagnostic of a configurable rule its configured
+// severity and drops those of disabled rules.
+func (l *Linter) filter(diags diagnostics.List) diagnostics.List {
+	var out diagnostics.List
+	for _, d := range diags {
+		severity := l.Config.severity(d.Rule)
+		if severity == Off {
+			continue
+		}
+		d.Severity = severity
+		out = append(out, d)
+	}
+	return out
+}
@@ -0,0 +1,129 @@
+package linter
+
+import (
+	"MCPGen/core/diagnostics"
+	"MCPGen/core/flow-compiler"
+	"MCPGen/core/open-api-loader"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func byRule(diags diagnostics.List) map[string]diagnostics.List {
+	out := map[string]diagnostics.List{}
+	for _, d := range diags {
+		out[d.Rule] = append(out[d.Rule], d)
+	}
+	return out
+}
+
+func TestLint_ReportsEveryRule(t *testing.T) {
+	l := &Linter{}
+	diags := byRule(l.Lint([]Spec{{Path: "testdata/users.yaml"}}, []string{"testdata/workflows.arazzo.yaml"}))
+
+	require.Len(t, diags[RuleMissingOperationID], 1)
+	assert.Equal(t, diagnostics.Diagnostic{
+		Severity: diagnostics.SeverityWarning,
+		Rule:     RuleMissingOperationID,
+		File:     "testdata/users.yaml",
+		Line:     18,
+		Column:   5,
+		Pointer:  "/paths/~1users/post",
+		Message:  "operation POST /users has no operationId; its tool is named post_users",
+	}, diags[RuleMissingOperationID][0])
+
+	require.Len(t, diags[RuleUndocumentedResponse], 2)
+	assert.Equal(t, "operation DELETE /users/{id} documents no success response", diags[RuleUndocumentedResponse][0].Message)
+	assert.Equal(t, "/paths/~1users~1{id}/delete/responses/404", diags[RuleUndocumentedResponse][1].Pointer)
+	assert.Equal(t, "response 404 of operation DELETE /users/{id} has no description", diags[RuleUndocumentedResponse][1].Message)
+
+	require.Len(t, diags[RuleUntypedSchema], 1)
+	assert.Equal(t, "/components/schemas/User/properties/metadata", diags[RuleUntypedSchema][0].Pointer)
+
+	require.Len(t, diags[RuleUnusedSource], 1)
+	assert.Equal(t, "/sourceDescriptions/1", diags[RuleUnusedSource][0].Pointer)
+	assert.Equal(t, 9, diags[RuleUnusedSource][0].Line)
+	assert.Contains(t, diags[RuleUnusedSource][0].Message, "'billing'")
+
+	require.Len(t, diags[RuleUnusedOutput], 1)
+	assert.Equal(t, diagnostics.SeverityInfo, diags[RuleUnusedOutput][0].Severity)
+	assert.Equal(t, "/workflows/0/steps/0/outputs/etag", diags[RuleUnusedOutput][0].Pointer)
+
+	require.Len(t, diags[RuleUnreachableStep], 1)
+	assert.Equal(t, "/workflows/0/steps/1", diags[RuleUnreachableStep][0].Pointer)
+	assert.Equal(t, "step 'remove' in workflow 'replace-user' is never run", diags[RuleUnreachableStep][0].Message)
+}
+
+func TestLint_Config(t *testing.T) {
+	config, err := LoadConfig("testdata/lint.yaml")
+	require.NoError(t, err)
+
+	l := &Linter{Config: config}
+	diags := l.Lint([]Spec{{Path: "testdata/users.yaml"}}, nil)
+	rules := byRule(diags)
+	assert.Empty(t, rules[RuleMissingOperationID])
+	require.Len(t, rules[RuleUntypedSchema], 1)
+	assert.Equal(t, diagnostics.SeverityError, rules[RuleUntypedSchema][0].Severity)
+	assert.True(t, diags.HasErrors())
+}
+
+func TestLint_OperationIDOverride(t *testing.T) {
+	loader := open_api_loader.NewSpecLoader()
+	loader.OperationIDs = map[string]string{"POST /users": "createUser"}
+	l := &Linter{Loader: loader}
+	assert.Empty(t, byRule(l.Lint([]Spec{{Path: "testdata/users.yaml"}}, nil))[RuleMissingOperationID])
+}
+
+func TestLoadConfig_Invalid(t *testing.T) {
+	_, err := LoadConfig("testdata/unknown-rule.yaml")
+	assert.ErrorContains(t, err, "unknown lint rule 'no-such-rule'")
+
+	path := filepath.Join(t.TempDir(), "lint.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("rules:\n  unused-output: fatal\n"), 0o644))
+	_, err = LoadConfig(path)
+	assert.ErrorContains(t, err, "unsupported severity 'fatal'")
+}
+
+func TestLint_UnresolvedStep(t *testing.T) {
+	l := &Linter{}
+	diags := l.Lint([]Spec{{Path: "testdata/users.yaml"}}, []string{"../../cmd/mcpgen/testdata/unresolved.arazzo.yaml"})
+	rules := byRule(diags)
+	require.Len(t, rules[RuleFlowReference], 1)
+	d := rules[RuleFlowReference][0]
+	assert.Equal(t, diagnostics.SeverityError, d.Severity)
+	assert.Equal(t, "/workflows/0/steps/0/operationId", d.Pointer)
+	assert.Equal(t, "step 'missing' in workflow 'broken': operationId 'deletePet' not found", d.Message)
+}
+
+func TestLint_InvalidDocuments(t *testing.T) {
+	l := &Linter{}
+	diags := l.Lint([]Spec{{Path: "../../cmd/mcpgen/testdata/diagnostics/broken.yaml"}}, []string{"../../cmd/mcpgen/testdata/diagnostics/invalid.arazzo.yaml"})
+	rules := byRule(diags)
+	assert.NotEmpty(t, rules[open_api_loader.RuleSchemaType])
+	assert.NotEmpty(t, rules["arazzo-validation"])
+	assert.Empty(t, rules[RuleFlowReference])
+	assert.True(t, diags.HasErrors())
+}
+
+func TestUnreachableSteps_GotoAndCriteria(t *testing.T) {
+	retry := 1
+	flow := &flowcompiler.CompiledFlow{
+		WorkflowID: "wf",
+		Levels:     [][]string{{"a"}, {"b"}, {"c"}, {"d"}},
+		Steps: []flowcompiler.CompiledStep{
+			// a may end the workflow, but only when its criteria hold.
+			{StepID: "a", OnSuccess: []flowcompiler.Action{{Type: "end", Criteria: []flowcompiler.Criterion{{Condition: "$statusCode == 204"}}}}},
+			// b always jumps to d, skipping c.
+			{StepID: "b", OnSuccess: []flowcompiler.Action{{Type: "goto", StepID: "d"}}},
+			{StepID: "c"},
+			// d retries on failure, which never falls through.
+			{StepID: "d", OnFailure: []flowcompiler.Action{{Type: "retry", RetryLimit: &retry}}},
+		},
+	}
+	diags := unreachableSteps(&document{file: "wf.arazzo.yaml"}, flow)
+	require.Len(t, diags, 1)
+	assert.Equal(t, "step 'c' in workflow 'wf' is never run", diags[0].Message)
+}
@@ -0,0 +1,112 @@
+package linter
+
+import (
+	"MCPGen/core/diagnostics"
+	"fmt"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Off is the severity of a disabled rule.
+const Off diagnostics.Severity = "off"
+
+// IDs of the lint rules.
+const (
+	RuleMissingOperationID   = "missing-operation-id"
+	RuleUndocumentedResponse = "undocumented-response"
+	RuleUntypedSchema        = "untyped-schema"
+	RuleUnusedSource         = "unused-source-description"
+	RuleUnreachableStep      = "unreachable-step"
+	RuleUnusedOutput         = "unused-output"
+	RuleFlowReference        = "flow-reference"
+	RuleOperationCollision   = "operation-collision"
+)
+
+// Rule is a lint check and the severity it reports with unless configured
+// otherwise.
+type Rule struct {
+	ID          string
+	Description string
+	Severity    diagnostics.Severity
+}
+
+// Rules lists the configurable rules. Documents that do not load, Arazzo
+// validation errors, unresolved step references and operation collisions are
+// always errors, since no server can be generated from them.
+var Rules = []Rule{
+	{RuleMissingOperationID, "operation without an operationId; its tool is named after its method and path", diagnostics.SeverityWarning},
+	{RuleUndocumentedResponse, "operation without a success response, or response without a description", diagnostics.SeverityWarning},
+	{RuleUntypedSchema, "schema without a type, reference, composition or enum", diagnostics.SeverityWarning},
+	{RuleUnusedSource, "Arazzo source description no step uses", diagnostics.SeverityWarning},
+	{RuleUnreachableStep, "Arazzo step that never runs, because the steps before it always end the workflow or jump past it", diagnostics.SeverityWarning},
+	{RuleUnusedOutput, "Arazzo step output no expression of its workflow reads", diagnostics.SeverityInfo},
+}
+
+// Config tunes the rules. It is read from YAML or JSON:
+//
+//	rules:
+//	  missing-operation-id: off
+//	  untyped-schema: error
+type Config struct {
+	// Rules maps rule IDs to off, info, warning or error.
+	Rules map[string]diagnostics.Severity `yaml:"rules"`
+}
+
+// LoadConfig reads the config file at path.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read file: %w", err)
+	}
+	var c Config
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse lint config %s: %w", path, err)
+	}
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &c, nil
+}
+
+func (c *Config) validate() error {
+	ids := make([]string, 0, len(c.Rules))
+	for id := range c.Rules {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if _, ok := ruleByID(id); !ok {
+			return fmt.Errorf("unknown lint rule '%s'", id)
+		}
+		switch c.Rules[id] {
+		case Off, diagnostics.SeverityInfo, diagnostics.SeverityWarning, diagnostics.SeverityError:
+		default:
+			return fmt.Errorf("rule %s: unsupported severity '%s', expected off, info, warning or error", id, c.Rules[id])
+		}
+	}
+	return nil
+}
+
+// severity returns the configured severity of rule.
+func (c *Config) severity(rule string) diagnostics.Severity {
+	if c != nil {
+		if s, ok := c.Rules[rule]; ok {
+			return s
+		}
+	}
+	if r, ok := ruleByID(rule); ok {
+		return r.Severity
+	}
+	return diagnostics.SeverityError
+}
+
+func ruleByID(id string) (Rule, bool) {
+	for _, r := range Rules {
+		if r.ID == id {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
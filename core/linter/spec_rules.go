@@ -0,0 +1,142 @@
+package linter
+
+import (
+	"MCPGen/core/diagnostics"
+	"MCPGen/core/open-api-loader"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// methods are the keys of a path item naming an operation.
+var methods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// typeKeys are the keywords that tell what a schema accepts.
+var typeKeys = []string{
+	"type", "$ref", "allOf", "anyOf", "oneOf", "not", "enum", "const",
+	"properties", "items", "additionalProperties",
+}
+
+// specRules checks the operations and schemas of a spec as written in file.
+func (l *Linter) specRules(file string, root *yaml.Node) diagnostics.List {
+	doc := diagnostics.Lookup(root, "")
+	if doc == nil || doc.Kind != yaml.MappingNode {
+		return nil
+	}
+	var out diagnostics.List
+	_, version := entry(doc, "openapi")
+	optionalResponses := version != nil && strings.HasPrefix(version.Value, "3.1")
+
+	_, paths := entry(doc, "paths")
+	if paths != nil && paths.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(paths.Content); i += 2 {
+			path := paths.Content[i].Value
+			for _, method := range methods {
+				key, op := entry(paths.Content[i+1], method)
+				if key == nil {
+					continue
+				}
+				pointer := diagnostics.Pointer("paths", path, method)
+				route := strings.ToUpper(method) + " " + path
+				if _, id := entry(op, "operationId"); (id == nil || id.Value == "") && !l.overridden(route) {
+					out = append(out, diagnostics.Diagnostic{
+						Rule:    RuleMissingOperationID,
+						File:    file,
+						Pointer: pointer,
+						Message: fmt.Sprintf("operation %s has no operationId; its tool is named %s", route, open_api_loader.SynthesizeOperationID(method, path)),
+					}.At(key))
+				}
+				out = append(out, responseDiagnostics(file, pointer, route, key, op, optionalResponses)...)
+			}
+		}
+	}
+
+	open_api_loader.WalkSchemas(doc, func(schema *yaml.Node, pointer string) {
+		for _, k := range typeKeys {
+			if key, _ := entry(schema, k); key != nil {
+				return
+			}
+		}
+		out = append(out, diagnostics.Diagnostic{
+			Rule:    RuleUntypedSchema,
+			File:    file,
+			Pointer: pointer,
+			Message: "schema has no type, so its tool accepts or returns anything",
+		}.At(schema))
+	})
+	return out
+}
+
+// responseDiagnostics reports an operation that documents no success
+// response, and responses without a description. An operation without
+// responses is only reported where the loader accepts it, in OpenAPI 3.1.
+func responseDiagnostics(file, pointer, route string, key, op *yaml.Node, optional bool) diagnostics.List {
+	responsesKey, responses := entry(op, "responses")
+	if responses == nil || responses.Kind != yaml.MappingNode {
+		if responses == nil && !optional {
+			return nil
+		}
+		return diagnostics.List{diagnostics.Diagnostic{
+			Rule:    RuleUndocumentedResponse,
+			File:    file,
+			Pointer: pointer,
+			Message: fmt.Sprintf("operation %s documents no responses", route),
+		}.At(key)}
+	}
+
+	var out diagnostics.List
+	success := false
+	for i := 0; i+1 < len(responses.Content); i += 2 {
+		code, response := responses.Content[i].Value, responses.Content[i+1]
+		if strings.HasPrefix(code, "x-") {
+			continue
+		}
+		if code == "default" || strings.HasPrefix(code, "2") {
+			success = true
+		}
+		if ref, _ := entry(response, "$ref"); ref != nil {
+			continue
+		}
+		if _, description := entry(response, "description"); description == nil || strings.TrimSpace(description.Value) == "" {
+			out = append(out, diagnostics.Diagnostic{
+				Rule:    RuleUndocumentedResponse,
+				File:    file,
+				Pointer: pointer + diagnostics.Pointer("responses", code),
+				Message: fmt.Sprintf("response %s of operation %s has no description", code, route),
+			}.At(responses.Content[i]))
+		}
+	}
+	if !success {
+		out = append(out, diagnostics.Diagnostic{
+			Rule:    RuleUndocumentedResponse,
+			File:    file,
+			Pointer: pointer + "/responses",
+			Message: fmt.Sprintf("operation %s documents no success response", route),
+		}.At(responsesKey))
+	}
+	return out
+}
+
+// overridden reports whether the loader gives the operation route an
+// operationId.
+func (l *Linter) overridden(route string) bool {
+	if l.Loader == nil {
+		return false
+	}
+	_, ok := l.Loader.OperationIDs[route]
+	return ok
+}
+
+// entry returns the key and value nodes of key in node.
+func entry(node *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i], node.Content[i+1]
+		}
+	}
+	return nil, nil
+}
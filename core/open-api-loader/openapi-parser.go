@@ -1,21 +1,30 @@
 package open_api_loader
 
 import (
+	"MCPGen/core/diagnostics"
+	"MCPGen/core/utils"
 	"fmt"
 	"github.com/pb33f/libopenapi"
+	"github.com/pb33f/libopenapi/datamodel/high/base"
 	"os"
 )
 
+// ParseOpenAPISpecsFile summarizes the OpenAPI 3.x document at filePath. The
+// error of a document that cannot be used carries a *diagnostics.Error.
 func ParseOpenAPISpecsFile(filePath string) (summary string, err error) {
 	defer func() {
 		if r := recover(); r != nil {
-			err = fmt.Errorf("cannot create v3 model")
+			d := panicDiagnostic(r)
+			d.File = filePath
+			err = fmt.Errorf("cannot create v3 model: %w", diagnostics.List{d}.Err())
 			summary = ""
 		}
 	}()
-	openApiSpecs, err := os.ReadFile(filePath)
+	// References to other files and URLs are bundled in first; BundleSpec
+	// reports a missing file as "cannot read file".
+	openApiSpecs, err := NewSpecLoader().BundleSpec(filePath)
 	if err != nil {
-		return "", fmt.Errorf("cannot read file: %w", err)
+		return "", err
 	}
 
 	document, err := libopenapi.NewDocument(openApiSpecs)
@@ -23,13 +32,20 @@ func ParseOpenAPISpecsFile(filePath string) (summary string, err error) {
 		return "", fmt.Errorf("cannot create document: %w", err)
 	}
 
-	v3Model, errors := document.BuildV3Model()
-	if len(errors) > 0 {
-		return "", fmt.Errorf("cannot create v3 model: %v", errors)
+	v3Model, errs := document.BuildV3Model()
+	if err := specDiagnostics(document, errs).Err(); err != nil {
+		source, _ := os.ReadFile(filePath)
+		locateDiagnostics(err, filePath, source, openApiSpecs)
+		return "", fmt.Errorf("cannot create v3 model: %w", err)
 	}
 
-	paths := v3Model.Model.Paths.PathItems.Len()
-	schemas := v3Model.Model.Components.Schemas.Len()
+	var paths, schemas int
+	if v3Model.Model.Paths != nil {
+		paths = v3Model.Model.Paths.PathItems.Len()
+	}
+	if v3Model.Model.Components != nil && v3Model.Model.Components.Schemas != nil {
+		schemas = v3Model.Model.Components.Schemas.Len()
+	}
 
 	summary = fmt.Sprintf(
 		"There are %d paths and %d schemas in the document\nOpenAPI version: %s\nOpenAPI info: %s\nOpenAPI description: %s\nOpenAPI contact: %s\nOpenAPI license: %s\n",
@@ -37,9 +53,9 @@ func ParseOpenAPISpecsFile(filePath string) (summary string, err error) {
 		schemas,
 		v3Model.Model.Info.Version,
 		v3Model.Model.Info.Title,
-		v3Model.Model.Info.Description,
-		v3Model.Model.Info.Contact.Name,
-		v3Model.Model.Info.License.Name,
+		utils.SafeStr(v3Model.Model.Info.Description),
+		utils.SafeStrPtr(v3Model.Model.Info.Contact, func(c *base.Contact) string { return c.Name }),
+		utils.SafeStrPtr(v3Model.Model.Info.License, func(l *base.License) string { return l.Name }),
 	)
 
 	return summary, nil
@@ -39,4 +39,11 @@ func TestParseOpenAPISpecsFile_ModelErrors(t *testing.T) {
 	_, err := ParseOpenAPISpecsFile("testdata/model_error_openapi.yaml")
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "cannot create v3 model")
+	diags := diagnosticsOf(t, err)
+	if assert.Len(t, diags, 1) {
+		assert.Equal(t, RuleMissingResponses, diags[0].Rule)
+		assert.Equal(t, "/paths/~1badpath/get", diags[0].Pointer)
+		assert.Equal(t, "testdata/model_error_openapi.yaml", diags[0].File)
+		assert.Equal(t, 10, diags[0].Line)
+	}
 }
@@ -0,0 +1,143 @@
+package open_api_loader
+
+import (
+	"MCPGen/core/openapi-loader"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// maxOperationIDLength keeps synthesized IDs within the tool name limit of
+// common MCP clients.
+const maxOperationIDLength = 64
+
+// operationIDPattern matches names that are both Go identifiers and MCP tool
+// names.
+var operationIDPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// LoadOperationIDs reads a YAML or JSON file mapping "METHOD path" to the
+// operationId given to that operation, e.g. "GET /users/{id}: fetchUser".
+func LoadOperationIDs(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read file: %w", err)
+	}
+	var raw map[string]string
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse operationId overrides %s: %w", path, err)
+	}
+	overrides := make(map[string]string, len(raw))
+	for key, name := range raw {
+		route, err := operationKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if !operationIDPattern.MatchString(name) {
+			return nil, fmt.Errorf("%s: operationId '%s' for %s is not a valid identifier", path, name, key)
+		}
+		overrides[route] = name
+	}
+	return overrides, nil
+}
+
+// operationKey normalizes "get /users/{id}" to "GET /users/{id}".
+func operationKey(key string) (string, error) {
+	fields := strings.Fields(key)
+	if len(fields) != 2 || !strings.HasPrefix(fields[1], "/") {
+		return "", fmt.Errorf("operation '%s' must have the form 'METHOD /path'", key)
+	}
+	return strings.ToUpper(fields[0]) + " " + fields[1], nil
+}
+
+// assignOperationIDs applies the OperationIDs overrides, then names every
+// operation still lacking an operationId after its method and path. Names are
+// unique within the spec: a clash with another operation gets a _2, _3, ...
+// suffix.
+func (l *SpecLoader) assignOperationIDs(spec *openapiloader.UnifiedAPISpec) {
+	used := map[string]bool{}
+	for i := range spec.Endpoints {
+		ep := &spec.Endpoints[i]
+		if name, ok := l.OperationIDs[ep.Method+" "+ep.Path]; ok {
+			ep.Operation = name
+		}
+		if ep.Operation != "" {
+			used[ep.Operation] = true
+		}
+	}
+	for i := range spec.Endpoints {
+		ep := &spec.Endpoints[i]
+		if ep.Operation != "" {
+			continue
+		}
+		base := SynthesizeOperationID(ep.Method, ep.Path)
+		name := base
+		for n := 2; used[name]; n++ {
+			suffix := "_" + strconv.Itoa(n)
+			name = truncateID(base, maxOperationIDLength-len(suffix)) + suffix
+		}
+		used[name] = true
+		ep.Operation = name
+	}
+}
+
+// SynthesizeOperationID names an operation after its method and path, the
+// way it reads: GET /users/{id} becomes get_users_by_id, POST
+// /orgs/{orgId}/members becomes post_orgs_by_org_id_members. The result is a
+// valid Go identifier and MCP tool name of at most 64 characters.
+func SynthesizeOperationID(method, path string) string {
+	parts := []string{strings.ToLower(method)}
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "" {
+			continue
+		}
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			segment = "by_" + snakeCase(segment[1:len(segment)-1])
+		} else {
+			segment = snakeCase(segment)
+		}
+		if segment != "" {
+			parts = append(parts, segment)
+		}
+	}
+	id := strings.Join(parts, "_")
+	if id == "" || unicode.IsDigit(rune(id[0])) {
+		id = "op_" + id
+	}
+	return truncateID(id, maxOperationIDLength)
+}
+
+// snakeCase lowercases s, splitting camelCase words and acronyms
+// ("HTTPServer" becomes http_server) and replacing every run of characters
+// outside [a-z0-9] with a single underscore.
+func snakeCase(s string) string {
+	var b strings.Builder
+	runes := []rune(s)
+	for i, r := range runes {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			if b.Len() > 0 && !strings.HasSuffix(b.String(), "_") {
+				b.WriteByte('_')
+			}
+			continue
+		}
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return strings.Trim(b.String(), "_")
+}
+
+func truncateID(id string, max int) string {
+	if len(id) <= max {
+		return id
+	}
+	return strings.TrimRight(id[:max], "_")
+}
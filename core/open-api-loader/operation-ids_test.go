@@ -0,0 +1,92 @@
+package open_api_loader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSynthesizeOperationID(t *testing.T) {
+	tests := map[string][2]string{
+		"get_users_by_id":             {"GET", "/users/{id}"},
+		"post_orgs_by_org_id_members": {"POST", "/orgs/{orgId}/members"},
+		"get_v1_pet_store_items_json": {"get", "/v1/pet-store/items.json"},
+		"delete":                      {"DELETE", "/"},
+		"get_api_http_server_status":  {"GET", "/api/HTTPServer/status"},
+	}
+	for want, route := range tests {
+		assert.Equal(t, want, SynthesizeOperationID(route[0], route[1]), route)
+	}
+
+	long := SynthesizeOperationID("GET", "/a-very-long-resource-name/{identifierOfTheResource}/another-long-sub-resource")
+	assert.LessOrEqual(t, len(long), maxOperationIDLength)
+	assert.Regexp(t, operationIDPattern, long)
+}
+
+func TestSpecLoader_SynthesizesOperationIDs(t *testing.T) {
+	spec := []byte(`openapi: 3.0.3
+info: {title: Users, version: "1"}
+paths:
+  /users/{id}:
+    get:
+      responses: {'200': {description: OK}}
+    delete:
+      responses: {'204': {description: Deleted}}
+  /users/by/{id}:
+    get:
+      responses: {'200': {description: OK}}
+  /users:
+    get:
+      operationId: get_users_by_id
+      responses: {'200': {description: OK}}
+    post:
+      responses: {'201': {description: Created}}
+`)
+	loaded, err := NewSpecLoader().LoadSpecBytes(spec)
+	require.NoError(t, err)
+	var ids []string
+	for _, ep := range loaded.Endpoints {
+		ids = append(ids, ep.Method+" "+ep.Path+" "+ep.Operation)
+	}
+	assert.ElementsMatch(t, []string{
+		"GET /users/{id} get_users_by_id_2",
+		"DELETE /users/{id} delete_users_by_id",
+		"GET /users/by/{id} get_users_by_by_id",
+		"GET /users get_users_by_id",
+		"POST /users post_users",
+	}, ids, "synthesized IDs never clash with declared ones")
+
+	file := filepath.Join(t.TempDir(), "operation-ids.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("get /users/{id}: fetchUser\nGET /users: listUsers\n"), 0o644))
+	overrides, err := LoadOperationIDs(file)
+	require.NoError(t, err)
+	loaded, err = (&SpecLoader{OperationIDs: overrides}).LoadSpecBytes(spec)
+	require.NoError(t, err)
+	ids = nil
+	for _, ep := range loaded.Endpoints {
+		ids = append(ids, ep.Operation)
+	}
+	assert.ElementsMatch(t, []string{"fetchUser", "delete_users_by_id", "get_users_by_by_id", "listUsers", "post_users"}, ids)
+}
+
+func TestLoadOperationIDs_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	for content, want := range map[string]string{
+		"/users: listUsers\n":         "must have the form 'METHOD /path'",
+		"GET /users: list-users\n":    "is not a valid identifier",
+		"GET /users: [listUsers]\n":   "failed to parse",
+		"GET /users/{id}: 1stUser\n":  "is not a valid identifier",
+		"GET users/{id}: fetchUser\n": "must have the form",
+	} {
+		file := filepath.Join(dir, "ids.yaml")
+		require.NoError(t, os.WriteFile(file, []byte(content), 0o644))
+		_, err := LoadOperationIDs(file)
+		require.Error(t, err, content)
+		assert.Contains(t, err.Error(), want)
+	}
+	_, err := LoadOperationIDs(filepath.Join(dir, "missing.yaml"))
+	assert.Error(t, err)
+}
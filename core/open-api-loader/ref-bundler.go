@@ -0,0 +1,489 @@
+package open_api_loader
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// schemaKeys are the keywords below which a reference names a schema. Such
+// references are hoisted into the named schemas of the root document; other
+// external references (parameters, responses, path items, ...) are inlined.
+var schemaKeys = map[string]bool{
+	"schema": true, "schemas": true, "definitions": true, "properties": true,
+	"patternProperties": true, "additionalProperties": true, "items": true,
+	"allOf": true, "anyOf": true, "oneOf": true, "not": true,
+}
+
+// refBundler rewrites the external references of a root document: schemas
+// from other files or URLs become named schemas of the root document, any
+// other referenced object is copied in place.
+type refBundler struct {
+	loader *SpecLoader
+	root   string
+	// schemasPath is the location of the named schemas: components/schemas
+	// for OpenAPI 3.x, definitions for Swagger 2.0.
+	schemasPath []string
+	schemas     *yaml.Node
+	docs        map[string]*yaml.Node
+	// hoisted maps "location#fragment" to the name of its hoisted schema.
+	hoisted map[string]string
+	names   map[string]bool
+	// inlining holds the references being copied, to detect cycles.
+	inlining []string
+}
+
+// bundle returns data with every external reference resolved; location is
+// the file path or URL relative references are resolved against. Documents
+// without external references are returned unchanged.
+func (l *SpecLoader) bundle(data []byte, location string) ([]byte, error) {
+	if !bytes.Contains(data, []byte("$ref")) {
+		return data, nil
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		// Left to the parser to report.
+		return data, nil
+	}
+	b := &refBundler{
+		loader:  l,
+		root:    location,
+		docs:    map[string]*yaml.Node{location: doc.Content[0]},
+		hoisted: map[string]string{},
+		names:   map[string]bool{},
+	}
+	changed, err := b.run(doc.Content[0])
+	if err != nil || !changed {
+		return data, err
+	}
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to encode bundled spec: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+func (b *refBundler) run(root *yaml.Node) (bool, error) {
+	b.schemasPath = []string{"components", "schemas"}
+	if mappingValue(root, "swagger") != nil {
+		b.schemasPath = []string{"definitions"}
+	}
+	b.schemas = root
+	for _, key := range b.schemasPath {
+		next := mappingValue(b.schemas, key)
+		if next == nil {
+			next = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			b.schemas.Content = append(b.schemas.Content, scalarNode(key), next)
+		}
+		b.schemas = next
+	}
+	initialSchemas := len(b.schemas.Content)
+
+	// Named schemas that only point at another file take the name they are
+	// declared under, so "components/schemas/Pet: {$ref: Pet.yaml}" keeps Pet.
+	direct := map[*yaml.Node]bool{}
+	for i := 0; i+1 < len(b.schemas.Content); i += 2 {
+		name, value := b.schemas.Content[i].Value, b.schemas.Content[i+1]
+		b.names[name] = true
+		if ref := refValue(value); ref != "" && !strings.HasPrefix(ref, "#") && len(value.Content) == 2 {
+			key, err := b.target(b.root, ref)
+			if err != nil {
+				return false, err
+			}
+			if _, ok := b.hoisted[key]; !ok {
+				b.hoisted[key] = name
+				direct[value] = true
+			}
+		}
+	}
+
+	changed := false
+	for i := 0; i+1 < len(b.schemas.Content); i += 2 {
+		value := b.schemas.Content[i+1]
+		if !direct[value] {
+			continue
+		}
+		resolved, err := b.resolveSchema(b.root, refValue(value))
+		if err != nil {
+			return false, err
+		}
+		b.schemas.Content[i+1] = resolved
+		changed = true
+	}
+	walked, err := b.walk(root, b.root, false)
+	if err != nil {
+		return false, err
+	}
+	if err := b.checkAliasCycles(); err != nil {
+		return false, err
+	}
+	return changed || walked || len(b.schemas.Content) != initialSchemas, nil
+}
+
+// walk rewrites the references below node, which belongs to the document at
+// location. It reports whether anything was changed.
+func (b *refBundler) walk(node *yaml.Node, location string, schema bool) (bool, error) {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		changed := false
+		for _, child := range node.Content {
+			c, err := b.walk(child, location, schema)
+			if err != nil {
+				return false, err
+			}
+			changed = changed || c
+		}
+		return changed, nil
+	case yaml.MappingNode:
+	default:
+		return false, nil
+	}
+
+	if ref := refValue(node); ref != "" {
+		if location == b.root && strings.HasPrefix(ref, "#") {
+			return false, nil
+		}
+		key, err := b.target(location, ref)
+		if err != nil {
+			return false, err
+		}
+		if docLocation, fragment, _ := strings.Cut(key, "#"); docLocation == b.root {
+			setRef(node, "#"+fragment)
+			return true, nil
+		}
+		if schema {
+			name, err := b.hoist(location, ref)
+			if err != nil {
+				return false, err
+			}
+			setRef(node, "#/"+strings.Join(b.schemasPath, "/")+"/"+escapeToken(name))
+			return true, nil
+		}
+		resolved, err := b.inline(location, ref)
+		if err != nil {
+			return false, err
+		}
+		*node = *resolved
+		return true, nil
+	}
+
+	changed := false
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		c, err := b.walk(node.Content[i+1], location, schema || schemaKeys[node.Content[i].Value])
+		if err != nil {
+			return false, err
+		}
+		changed = changed || c
+	}
+	return changed, nil
+}
+
+// hoist adds the schema ref points to as a named schema of the root document
+// and returns its name. A schema is hoisted once; recursive schemas end up
+// referring to their own name.
+func (b *refBundler) hoist(location, ref string) (string, error) {
+	key, err := b.target(location, ref)
+	if err != nil {
+		return "", err
+	}
+	if name, ok := b.hoisted[key]; ok {
+		return name, nil
+	}
+	docLocation, fragment, _ := strings.Cut(key, "#")
+	name := b.uniqueName(docLocation, fragment)
+	b.hoisted[key] = name
+
+	placeholder := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	b.schemas.Content = append(b.schemas.Content, scalarNode(name), placeholder)
+	resolved, err := b.resolveSchema(location, ref)
+	if err != nil {
+		return "", err
+	}
+	*placeholder = *resolved
+	return name, nil
+}
+
+// resolveSchema returns a copy of the schema ref points to, with its own
+// references rewritten.
+func (b *refBundler) resolveSchema(location, ref string) (*yaml.Node, error) {
+	key, err := b.target(location, ref)
+	if err != nil {
+		return nil, err
+	}
+	docLocation, fragment, _ := strings.Cut(key, "#")
+	node, err := b.lookup(docLocation, fragment)
+	if err != nil {
+		return nil, fmt.Errorf("$ref %s: %w", ref, err)
+	}
+	out := copyNode(node)
+	if _, err := b.walk(out, docLocation, true); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// inline returns a copy of the object ref points to, with its own references
+// rewritten. A reference reached again while it is being copied is circular.
+func (b *refBundler) inline(location, ref string) (*yaml.Node, error) {
+	key, err := b.target(location, ref)
+	if err != nil {
+		return nil, err
+	}
+	for i, k := range b.inlining {
+		if k == key {
+			return nil, fmt.Errorf("circular $ref: %s", strings.Join(append(b.inlining[i:], key), " -> "))
+		}
+	}
+	b.inlining = append(b.inlining, key)
+	defer func() { b.inlining = b.inlining[:len(b.inlining)-1] }()
+
+	docLocation, fragment, _ := strings.Cut(key, "#")
+	node, err := b.lookup(docLocation, fragment)
+	if err != nil {
+		return nil, fmt.Errorf("$ref %s: %w", ref, err)
+	}
+	out := copyNode(node)
+	if _, err := b.walk(out, docLocation, false); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// checkAliasCycles reports named schemas that are only references to each
+// other in a loop, which no value can satisfy.
+func (b *refBundler) checkAliasCycles() error {
+	prefix := "#/" + strings.Join(b.schemasPath, "/") + "/"
+	for i := 0; i < len(b.schemas.Content); i += 2 {
+		start := b.schemas.Content[i].Value
+		chain := []string{start}
+		for current := b.schemas.Content[i+1]; ; {
+			ref := refValue(current)
+			if ref == "" || len(current.Content) != 2 || !strings.HasPrefix(ref, prefix) {
+				break
+			}
+			name := unescapeToken(strings.TrimPrefix(ref, prefix))
+			chain = append(chain, name)
+			if name == start {
+				return fmt.Errorf("circular $ref: %s", strings.Join(chain, " -> "))
+			}
+			if len(chain) > len(b.schemas.Content)/2+1 {
+				break
+			}
+			if current = mappingValue(b.schemas, name); current == nil {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// target resolves ref relative to the document at location into an absolute
+// "location#fragment" key.
+func (b *refBundler) target(location, ref string) (string, error) {
+	docRef, fragment, _ := strings.Cut(ref, "#")
+	if docRef == "" {
+		return location + "#" + fragment, nil
+	}
+	resolved, err := resolveLocation(location, docRef)
+	if err != nil {
+		return "", fmt.Errorf("$ref %s: %w", ref, err)
+	}
+	return resolved + "#" + fragment, nil
+}
+
+// lookup returns the node at the JSON pointer fragment of a document.
+func (b *refBundler) lookup(location, fragment string) (*yaml.Node, error) {
+	doc, ok := b.docs[location]
+	if !ok {
+		data, err := b.loader.readRef(location, b.root)
+		if err != nil {
+			return nil, err
+		}
+		var parsed yaml.Node
+		if err := yaml.Unmarshal(data, &parsed); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", location, err)
+		}
+		if len(parsed.Content) == 0 {
+			return nil, fmt.Errorf("%s is empty", location)
+		}
+		doc = parsed.Content[0]
+		b.docs[location] = doc
+	}
+	node := doc
+	if fragment == "" || fragment == "/" {
+		return node, nil
+	}
+	for _, token := range strings.Split(strings.TrimPrefix(fragment, "/"), "/") {
+		token = unescapeToken(token)
+		switch node.Kind {
+		case yaml.MappingNode:
+			node = mappingValue(node, token)
+		case yaml.SequenceNode:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(node.Content) {
+				node = nil
+			} else {
+				node = node.Content[i]
+			}
+		default:
+			node = nil
+		}
+		if node == nil {
+			return nil, fmt.Errorf("%s has nothing at #%s", location, fragment)
+		}
+	}
+	return node, nil
+}
+
+// uniqueName derives the name of a hoisted schema from the last token of its
+// fragment, or the file name without extension for whole documents.
+func (b *refBundler) uniqueName(location, fragment string) string {
+	base := strings.TrimSuffix(path.Base(filepath.ToSlash(location)), path.Ext(location))
+	name := base
+	if fragment != "" && fragment != "/" {
+		name = unescapeToken(fragment[strings.LastIndex(fragment, "/")+1:])
+	}
+	if b.names[name] && name != base {
+		name = base + "_" + name
+	}
+	candidate := name
+	for i := 2; b.names[candidate]; i++ {
+		candidate = name + "_" + strconv.Itoa(i)
+	}
+	b.names[candidate] = true
+	return candidate
+}
+
+// readRef returns the document at an absolute file path or URL. Remote
+// documents are fetched from the AllowedRemoteRefs prefixes, or from the host
+// of a root document loaded from a URL, which also gets the loader's Headers.
+func (l *SpecLoader) readRef(location, root string) ([]byte, error) {
+	if !isRemote(location) {
+		data, err := os.ReadFile(location)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read file: %w", err)
+		}
+		return data, nil
+	}
+	sameHost := isRemote(root) && sameOrigin(location, root)
+	allowed := sameHost
+	for _, prefix := range l.AllowedRemoteRefs {
+		if underPrefix(location, prefix) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, fmt.Errorf("remote reference %s is not allowed", location)
+	}
+	return l.fetch(location, sameHost)
+}
+
+func isRemote(location string) bool {
+	return strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")
+}
+
+// underPrefix reports whether location is on the scheme and host of the
+// AllowedRemoteRefs entry prefix and below its path. The path must match up to
+// a segment boundary: /schemas allows /schemas/pet.yaml but not /schemas-old.
+func underPrefix(location, prefix string) bool {
+	loc, err := url.Parse(location)
+	if err != nil {
+		return false
+	}
+	allowed, err := url.Parse(prefix)
+	if err != nil || !strings.EqualFold(loc.Scheme, allowed.Scheme) || !strings.EqualFold(loc.Host, allowed.Host) {
+		return false
+	}
+	dir := allowed.Path
+	if dir == "" || strings.HasSuffix(dir, "/") {
+		return strings.HasPrefix(loc.Path, dir)
+	}
+	return loc.Path == dir || strings.HasPrefix(loc.Path, dir+"/")
+}
+
+func sameOrigin(a, b string) bool {
+	ua, errA := url.Parse(a)
+	ub, errB := url.Parse(b)
+	return errA == nil && errB == nil && ua.Scheme == ub.Scheme && ua.Host == ub.Host
+}
+
+// resolveLocation resolves ref against the file path or URL of the document
+// holding it.
+func resolveLocation(base, ref string) (string, error) {
+	if isRemote(ref) {
+		return ref, nil
+	}
+	if isRemote(base) {
+		u, err := url.Parse(base)
+		if err != nil {
+			return "", err
+		}
+		r, err := url.Parse(ref)
+		if err != nil {
+			return "", err
+		}
+		return u.ResolveReference(r).String(), nil
+	}
+	if filepath.IsAbs(ref) {
+		return filepath.Clean(ref), nil
+	}
+	return filepath.Join(filepath.Dir(base), filepath.FromSlash(ref)), nil
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func refValue(node *yaml.Node) string {
+	if v := mappingValue(node, "$ref"); v != nil && v.Kind == yaml.ScalarNode {
+		return v.Value
+	}
+	return ""
+}
+
+// setRef replaces node by a reference, dropping its sibling keys.
+func setRef(node *yaml.Node, ref string) {
+	*node = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{scalarNode("$ref"), scalarNode(ref)}}
+}
+
+func scalarNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+func copyNode(node *yaml.Node) *yaml.Node {
+	out := *node
+	out.Content = make([]*yaml.Node, len(node.Content))
+	for i, child := range node.Content {
+		out.Content[i] = copyNode(child)
+	}
+	if node.Alias != nil {
+		out.Alias = copyNode(node.Alias)
+	}
+	return &out
+}
+
+func escapeToken(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+func unescapeToken(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+}
@@ -0,0 +1,145 @@
+package open_api_loader
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpecLoader_BundlesFileReferences(t *testing.T) {
+	spec, err := NewSpecLoader().LoadSpec("testdata/multifile/openapi.yaml")
+	require.NoError(t, err)
+	require.Len(t, spec.Endpoints, 2)
+
+	get := spec.Endpoints[0]
+	require.Len(t, get.Parameters, 1)
+	assert.Equal(t, "petId", get.Parameters[0].Name)
+	assert.True(t, get.Parameters[0].Required)
+	assert.Equal(t, map[string]interface{}{"$ref": "#/components/schemas/Id"}, get.Parameters[0].Schema)
+	assert.Equal(t, map[string]interface{}{"$ref": "#/components/schemas/Pet"}, get.Responses["200"].Schema)
+
+	put := spec.Endpoints[1]
+	assert.Equal(t, map[string]interface{}{"$ref": "#/components/schemas/NewPet"}, put.RequestBody.Schema)
+	assert.Equal(t, map[string]interface{}{"$ref": "#/components/schemas/Category"}, put.Responses["200"].Schema)
+
+	assert.ElementsMatch(t, []string{"Pet", "Id", "Category", "NewPet", "Owner"}, keys(spec.Schemas))
+	category := spec.Schemas["Category"].(map[string]interface{})["properties"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"$ref": "#/components/schemas/Category"}, category["parent"])
+	pet := spec.Schemas["Pet"].(map[string]interface{})
+	assert.Equal(t, []interface{}{"id", "name"}, pet["required"])
+}
+
+func TestSpecLoader_BundleSpecBytesUsesBaseDir(t *testing.T) {
+	swagger := []byte(`swagger: "2.0"
+info: {title: Split, version: "1"}
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      responses:
+        '200':
+          description: Pets
+          schema:
+            type: array
+            items:
+              $ref: components/schemas/Pet.yaml
+`)
+	loader := &SpecLoader{BaseDir: "testdata/multifile"}
+	spec, err := loader.LoadSpecBytes(swagger)
+	require.NoError(t, err)
+	items := spec.Endpoints[0].Responses["200"].Schema.(map[string]interface{})["items"]
+	assert.Equal(t, map[string]interface{}{"$ref": "#/definitions/Pet"}, items)
+	assert.ElementsMatch(t, []string{"Pet", "Id", "Category"}, keys(spec.Schemas))
+
+	_, err = NewSpecLoader().LoadSpecBytes(swagger)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot read file")
+}
+
+func TestSpecLoader_CircularReference(t *testing.T) {
+	_, err := NewSpecLoader().LoadSpec("testdata/circular/openapi.yaml")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "circular $ref")
+	assert.Contains(t, err.Error(), "paths.yaml#/a")
+}
+
+func TestSpecLoader_RemoteReferences(t *testing.T) {
+	var fetches int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		_, _ = w.Write([]byte("Pet:\n  type: object\n  properties:\n    tag:\n      $ref: '#/Tag'\nTag:\n  type: string\n"))
+	}))
+	defer srv.Close()
+	spec := []byte(`openapi: 3.0.3
+info: {title: Remote, version: "1"}
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      responses:
+        '200':
+          description: Pets
+          content:
+            application/json:
+              schema:
+                $ref: ` + srv.URL + `/shared.yaml#/Pet
+`)
+
+	_, err := NewSpecLoader().LoadSpecBytes(spec)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is not allowed")
+
+	cache := t.TempDir()
+	loader := &SpecLoader{AllowedRemoteRefs: []string{srv.URL + "/"}, CacheDir: cache}
+	loaded, err := loader.LoadSpecBytes(spec)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"Pet", "Tag"}, keys(loaded.Schemas))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches), "a document is fetched once")
+	cached, err := os.ReadDir(cache)
+	require.NoError(t, err)
+	assert.Len(t, cached, 1)
+
+	offline := &SpecLoader{AllowedRemoteRefs: loader.AllowedRemoteRefs, CacheDir: cache, Offline: true}
+	_, err = offline.LoadSpecBytes(spec)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches), "offline loads are served from the cache")
+
+	offline.CacheDir = filepath.Join(t.TempDir(), "empty")
+	_, err = offline.LoadSpecBytes(spec)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not cached")
+}
+
+func TestUnderPrefix(t *testing.T) {
+	tests := []struct {
+		location, prefix string
+		want             bool
+	}{
+		{"https://schemas.example.com/pet.yaml", "https://schemas.example.com", true},
+		{"https://Schemas.Example.com/pet.yaml", "https://schemas.example.com/", true},
+		{"https://schemas.example.com.attacker.net/pet.yaml", "https://schemas.example.com", false},
+		{"https://schemas.example.com:8443/pet.yaml", "https://schemas.example.com", false},
+		{"http://schemas.example.com/pet.yaml", "https://schemas.example.com", false},
+		{"https://schemas.example.com/v1/pet.yaml", "https://schemas.example.com/v1", true},
+		{"https://schemas.example.com/v1", "https://schemas.example.com/v1", true},
+		{"https://schemas.example.com/v10/pet.yaml", "https://schemas.example.com/v1", false},
+		{"https://schemas.example.com/v2/pet.yaml", "https://schemas.example.com/v1/", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, underPrefix(tt.location, tt.prefix), "%s under %s", tt.location, tt.prefix)
+	}
+}
+
+func keys(m map[string]interface{}) []string {
+	var out []string
+	for k := range m {
+		out = append(out, k)
+	}
+	return out
+}
@@ -0,0 +1,274 @@
+package open_api_loader
+
+import (
+	"MCPGen/core/diagnostics"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/pb33f/libopenapi"
+	"github.com/pb33f/libopenapi/index"
+	specutils "github.com/pb33f/libopenapi/utils"
+	"gopkg.in/yaml.v3"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Rules of the diagnostics reported for specs.
+const (
+	RuleModel            = "openapi-model"
+	RuleMissingPaths     = "missing-paths"
+	RuleMissingResponses = "missing-responses"
+	RuleSchemaType       = "schema-type"
+	RuleInternal         = "internal-error"
+)
+
+// httpMethods are the keys of a path item naming an operation.
+var httpMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// lineColumn finds the position libopenapi puts in some error messages.
+var lineColumn = regexp.MustCompile(`line (\d+), col(?:umn)? (\d+)`)
+
+// subschemaKeys are the keywords of a schema whose value is a schema, or a
+// list or map of schemas.
+var subschemaKeys = map[string]bool{
+	"properties": true, "patternProperties": true, "items": true, "additionalProperties": true,
+	"allOf": true, "anyOf": true, "oneOf": true, "prefixItems": true, "not": true,
+	"if": true, "then": true, "else": true, "contains": true, "$defs": true,
+}
+
+// specDiagnostics returns the errors libopenapi reported building the model
+// of document together with the problems checkDocument finds in it.
+func specDiagnostics(document libopenapi.Document, errs []error) diagnostics.List {
+	info := document.GetSpecInfo()
+	out := modelDiagnostics(errs, info.RootNode)
+	return append(out, checkDocument(info.RootNode, info.SpecType == specutils.OpenApi2, info.Version)...)
+}
+
+// modelDiagnostics turns the errors of BuildV2Model or BuildV3Model into
+// diagnostics, locating them where libopenapi tells where they happened.
+func modelDiagnostics(errs []error, root *yaml.Node) diagnostics.List {
+	var out diagnostics.List
+	for _, err := range errs {
+		for _, e := range specutils.UnwrapErrors(err) {
+			d := diagnostics.Diagnostic{Severity: diagnostics.SeverityError, Rule: RuleModel, Message: e.Error()}
+			var indexErr *index.IndexingError
+			var resolveErr *index.ResolvingError
+			switch {
+			case errors.As(e, &indexErr) && indexErr.Node != nil:
+				d = d.At(indexErr.Node)
+			case errors.As(e, &resolveErr) && resolveErr.Node != nil:
+				d = d.At(resolveErr.Node)
+			default:
+				if m := lineColumn.FindStringSubmatch(e.Error()); m != nil {
+					d.Line, _ = strconv.Atoi(m[1])
+					d.Column, _ = strconv.Atoi(m[2])
+				}
+			}
+			if d.Line > 0 {
+				d.Pointer = diagnostics.Locate(root, d.Line, d.Column)
+			}
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// panicDiagnostic reports a panic of libopenapi while building a model.
+func panicDiagnostic(r interface{}) diagnostics.Diagnostic {
+	return diagnostics.Diagnostic{
+		Severity: diagnostics.SeverityError,
+		Rule:     RuleInternal,
+		Message:  fmt.Sprintf("internal error while building the model: %v", r),
+	}
+}
+
+// checkDocument reports what libopenapi accepts but no tool can use: a
+// document without paths, operations without responses and schemas of an
+// unknown type. OpenAPI 3.1 makes paths and responses optional.
+func checkDocument(root *yaml.Node, swagger bool, version string) diagnostics.List {
+	doc := diagnostics.Lookup(root, "")
+	if doc == nil || doc.Kind != yaml.MappingNode {
+		return nil
+	}
+	var out diagnostics.List
+	required := !strings.HasPrefix(version, "3.1")
+
+	paths := mappingValue(doc, "paths")
+	if paths == nil && required {
+		out = append(out, diagnostics.Diagnostic{
+			Severity: diagnostics.SeverityError,
+			Rule:     RuleMissingPaths,
+			Message:  "the document has no paths",
+		}.At(doc))
+	}
+	if paths != nil && paths.Kind == yaml.MappingNode && required {
+		for i := 0; i+1 < len(paths.Content); i += 2 {
+			for _, method := range httpMethods {
+				key, op := mappingEntry(paths.Content[i+1], method)
+				if key == nil || mappingValue(op, "responses") != nil {
+					continue
+				}
+				out = append(out, diagnostics.Diagnostic{
+					Severity: diagnostics.SeverityError,
+					Rule:     RuleMissingResponses,
+					Pointer:  diagnostics.Pointer("paths", paths.Content[i].Value, method),
+					Message:  fmt.Sprintf("operation %s %s has no responses", strings.ToUpper(method), paths.Content[i].Value),
+				}.At(key))
+			}
+		}
+	}
+
+	types := []string{"array", "boolean", "integer", "number", "object", "string"}
+	if swagger {
+		types = append(types, "file")
+	} else if !required {
+		types = append(types, "null")
+	}
+	sort.Strings(types)
+	WalkSchemas(doc, func(schema *yaml.Node, pointer string) {
+		if t := mappingValue(schema, "type"); t != nil {
+			out = append(out, checkType(t, pointer+"/type", types)...)
+		}
+	})
+	return out
+}
+
+func checkType(node *yaml.Node, pointer string, types []string) diagnostics.List {
+	values := []*yaml.Node{node}
+	if node.Kind == yaml.SequenceNode {
+		values = node.Content
+	}
+	var out diagnostics.List
+	for _, v := range values {
+		if v.Kind == yaml.ScalarNode && contains(types, v.Value) {
+			continue
+		}
+		out = append(out, diagnostics.Diagnostic{
+			Severity: diagnostics.SeverityError,
+			Rule:     RuleSchemaType,
+			Pointer:  pointer,
+			Message:  fmt.Sprintf("schema type '%s' is not one of %s", v.Value, strings.Join(types, ", ")),
+		}.At(v))
+	}
+	return out
+}
+
+// WalkSchemas calls fn with every schema of the Swagger 2.0 or OpenAPI 3.x
+// document root and its JSON pointer: the named schemas of definitions and
+// components, the values of "schema" keys, and their subschemas. A reference
+// is passed to fn but not followed; examples and extensions are skipped.
+func WalkSchemas(root *yaml.Node, fn func(schema *yaml.Node, pointer string)) {
+	if doc := diagnostics.Lookup(root, ""); doc != nil {
+		w := schemaWalker{fn: fn}
+		w.walk(doc, "")
+	}
+}
+
+type schemaWalker struct {
+	fn func(schema *yaml.Node, pointer string)
+}
+
+// walk finds the schemas below node.
+func (w *schemaWalker) walk(node *yaml.Node, pointer string) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i].Value, node.Content[i+1]
+			p := pointer + diagnostics.Pointer(key)
+			switch {
+			case key == "example" || key == "examples" || strings.HasPrefix(key, "x-"):
+			case key == "schema":
+				w.schema(value, p)
+			case p == "/definitions" || p == "/components/schemas":
+				w.schemaMap(value, p)
+			default:
+				w.walk(value, p)
+			}
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			w.walk(item, pointer+diagnostics.Pointer(strconv.Itoa(i)))
+		}
+	}
+}
+
+func (w *schemaWalker) schemaMap(node *yaml.Node, pointer string) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		w.schema(node.Content[i+1], pointer+diagnostics.Pointer(node.Content[i].Value))
+	}
+}
+
+func (w *schemaWalker) schema(node *yaml.Node, pointer string) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	w.fn(node, pointer)
+	if mappingValue(node, "$ref") != nil {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i].Value, node.Content[i+1]
+		p := pointer + diagnostics.Pointer(key)
+		switch {
+		case key == "properties" || key == "patternProperties" || key == "$defs" || key == "definitions":
+			w.schemaMap(value, p)
+		case subschemaKeys[key] && value.Kind == yaml.SequenceNode:
+			for j, item := range value.Content {
+				w.schema(item, p+diagnostics.Pointer(strconv.Itoa(j)))
+			}
+		case subschemaKeys[key]:
+			w.schema(value, p)
+		}
+	}
+}
+
+// locateDiagnostics sets the file of the diagnostics err carries and moves
+// their positions from the bundled document to source, the document as
+// read. Diagnostics in a document pulled in by a reference keep no position;
+// their pointer designates the bundled document.
+func locateDiagnostics(err error, file string, source, bundled []byte) {
+	var de *diagnostics.Error
+	if !errors.As(err, &de) {
+		return
+	}
+	var root yaml.Node
+	relocate := !bytes.Equal(source, bundled) && yaml.Unmarshal(source, &root) == nil
+	for i := range de.Diagnostics {
+		d := &de.Diagnostics[i]
+		d.File = file
+		if !relocate {
+			continue
+		}
+		d.Line, d.Column = 0, 0
+		if node := diagnostics.Lookup(&root, d.Pointer); node != nil && d.Pointer != "" {
+			d.Line, d.Column = node.Line, node.Column
+		}
+	}
+}
+
+// mappingEntry returns the key and value nodes of key in node.
+func mappingEntry(node *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i], node.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package open_api_loader
+
+import (
+	"MCPGen/core/diagnostics"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpecLoader_ReportsAllDiagnostics(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "broken.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`openapi: 3.0.3
+info: {title: Broken, version: "1"}
+paths:
+  /users:
+    get:
+      summary: no responses
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: objekt
+      responses:
+        '201':
+          description: Created
+          content:
+            application/json:
+              schema: {$ref: '#/components/schemas/User'}
+components:
+  schemas:
+    Name:
+      type: object
+      properties:
+        first: {type: text}
+        tags:
+          type: array
+          items: {type: str}
+      example: {type: not-checked}
+`), 0o644))
+
+	_, err := NewSpecLoader().LoadSpec(file)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot create v3 model")
+	diags := diagnosticsOf(t, err)
+
+	var got []string
+	for _, d := range diags {
+		assert.Equal(t, file, d.File)
+		assert.Equal(t, diagnostics.SeverityError, d.Severity)
+		got = append(got, d.Rule+" "+d.Pointer)
+	}
+	assert.ElementsMatch(t, []string{
+		RuleModel + " /paths/~1users/post/responses/201/content/application~1json/schema",
+		RuleMissingResponses + " /paths/~1users/get",
+		RuleSchemaType + " /paths/~1users/post/requestBody/content/application~1json/schema/type",
+		RuleSchemaType + " /components/schemas/Name/properties/first/type",
+		RuleSchemaType + " /components/schemas/Name/properties/tags/items/type",
+	}, got)
+	for _, d := range diags {
+		if d.Rule == RuleSchemaType && d.Pointer == "/components/schemas/Name/properties/first/type" {
+			assert.Equal(t, 24, d.Line)
+			assert.Equal(t, 23, d.Column)
+		}
+	}
+}
+
+func TestSpecLoader_DiagnosticsOfBundledSpec(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "pet.yaml"), []byte("type: object\n"), 0o644))
+	file := filepath.Join(dir, "openapi.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`openapi: 3.0.3
+info: {title: Split, version: "1"}
+
+# The bundled document is re-encoded, so its lines differ from these.
+paths:
+  /pets:
+    get:
+      responses:
+        '200':
+          description: OK
+          content:
+            application/json:
+              schema: {$ref: pet.yaml}
+  /owners:
+    get:
+      responses:
+        '200':
+          description: OK
+          content:
+            application/json:
+              schema: {type: person}
+`), 0o644))
+
+	_, err := NewSpecLoader().LoadSpec(file)
+	require.Error(t, err)
+	diags := diagnosticsOf(t, err)
+	require.Len(t, diags, 1)
+	assert.Equal(t, "/paths/~1owners/get/responses/200/content/application~1json/schema/type", diags[0].Pointer)
+	assert.Equal(t, 21, diags[0].Line, "positions refer to the file as written")
+	assert.Equal(t, 30, diags[0].Column)
+}
+
+func TestSpecLoader_OpenAPI31AllowsMissingPaths(t *testing.T) {
+	_, err := NewSpecLoader().LoadSpecBytes([]byte(`openapi: 3.1.0
+info: {title: Webhooks only, version: "1"}
+components:
+  schemas:
+    Maybe: {type: [string, "null"]}
+`))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,552 @@
+package open_api_loader
+
+import (
+	"MCPGen/core/diagnostics"
+	"MCPGen/core/openapi-loader"
+	"MCPGen/core/utils"
+	"fmt"
+	"github.com/pb33f/libopenapi"
+	"github.com/pb33f/libopenapi/datamodel/high/base"
+	v2 "github.com/pb33f/libopenapi/datamodel/high/v2"
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+	"github.com/pb33f/libopenapi/orderedmap"
+	specutils "github.com/pb33f/libopenapi/utils"
+	"gopkg.in/yaml.v3"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SpecLoader loads Swagger 2.0 and OpenAPI 3.x documents into a UnifiedAPISpec.
+// References to other files and URLs are bundled into the document first.
+type SpecLoader struct {
+	// BaseDir resolves the relative references of specs loaded with
+	// LoadSpecBytes or from stdin; other specs resolve them against their own
+	// directory or URL.
+	BaseDir string
+	// AllowedRemoteRefs lists the URL prefixes remote references may be
+	// fetched from: the scheme and host must match exactly and the path
+	// segment by segment. References of a spec loaded from a URL may also
+	// point to the same host.
+	AllowedRemoteRefs []string
+	// Headers are sent when fetching a spec URL and the references on its
+	// host, e.g. Authorization.
+	Headers http.Header
+	// CacheDir keeps a copy of every fetched document. Cached documents are
+	// revalidated with their ETag, so unchanged ones are not downloaded again.
+	CacheDir string
+	// Offline only reads remote documents from CacheDir.
+	Offline bool
+	// Timeout bounds every fetch; 30s when zero. Ignored when HTTPClient is set.
+	Timeout time.Duration
+	// HTTPClient fetches remote documents.
+	HTTPClient *http.Client
+	// Stdin is read for the spec "-"; os.Stdin when nil.
+	Stdin io.Reader
+	// OperationIDs overrides the operationId of operations, keyed by
+	// "METHOD path" as in "GET /users/{id}". Operations without an operationId
+	// and without an override get one derived from their method and path.
+	OperationIDs map[string]string
+}
+
+// defaultTimeout bounds fetches of remote documents when Timeout is not set.
+const defaultTimeout = 30 * time.Second
+
+var _ openapiloader.OpenAPILoader = (*SpecLoader)(nil)
+
+// NewSpecLoader creates a new instance of SpecLoader.
+func NewSpecLoader() *SpecLoader {
+	return &SpecLoader{}
+}
+
+// LoadSpec reads the spec at path, detects its version and normalizes it.
+// path is a file, an http(s) URL, or "-" for stdin. The error of a document
+// that cannot be used carries a *diagnostics.Error locating its problems.
+func (l *SpecLoader) LoadSpec(path string) (*openapiloader.UnifiedAPISpec, error) {
+	spec, _, err := l.LoadSpecSource(path)
+	return spec, err
+}
+
+// LoadSpecSource is LoadSpec that also returns the document as read, before
+// its references were bundled. The source is returned with the error of a
+// document that was read but cannot be used.
+func (l *SpecLoader) LoadSpecSource(path string) (*openapiloader.UnifiedAPISpec, []byte, error) {
+	source, location, err := l.readSpec(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := l.bundle(source, location)
+	if err != nil {
+		return nil, source, err
+	}
+	spec, err := l.loadBundled(data)
+	if err != nil {
+		locateDiagnostics(err, path, source, data)
+		return nil, source, err
+	}
+	spec.Source = path
+	return spec, source, nil
+}
+
+// LoadSpecBytes normalizes an in-memory Swagger 2.0 or OpenAPI 3.x document.
+// Relative references are resolved against BaseDir.
+func (l *SpecLoader) LoadSpecBytes(data []byte) (*openapiloader.UnifiedAPISpec, error) {
+	data, err := l.BundleSpecBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	return l.loadBundled(data)
+}
+
+// BundleSpec returns the spec at path with every reference to another file or
+// URL resolved: referenced schemas are added to the named schemas of the
+// document, any other referenced object is copied in place. Circular
+// references between schemas are kept as references to the named schema.
+func (l *SpecLoader) BundleSpec(path string) ([]byte, error) {
+	data, location, err := l.readSpec(path)
+	if err != nil {
+		return nil, err
+	}
+	return l.bundle(data, location)
+}
+
+// BundleSpecBytes is BundleSpec for an in-memory document, whose relative
+// references are resolved against BaseDir.
+func (l *SpecLoader) BundleSpecBytes(data []byte) ([]byte, error) {
+	location, err := l.baseLocation()
+	if err != nil {
+		return nil, err
+	}
+	return l.bundle(data, location)
+}
+
+func (l *SpecLoader) loadBundled(data []byte) (spec *openapiloader.UnifiedAPISpec, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("cannot build model: %w", diagnostics.List{panicDiagnostic(r)}.Err())
+			spec = nil
+		}
+	}()
+
+	document, err := libopenapi.NewDocument(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create document: %w", err)
+	}
+
+	switch document.GetSpecInfo().SpecType {
+	case specutils.OpenApi2:
+		model, errs := document.BuildV2Model()
+		if err := specDiagnostics(document, errs).Err(); err != nil {
+			return nil, fmt.Errorf("cannot create v2 model: %w", err)
+		}
+		spec = convertSwagger(&model.Model)
+	case specutils.OpenApi3:
+		model, errs := document.BuildV3Model()
+		if err := specDiagnostics(document, errs).Err(); err != nil {
+			return nil, fmt.Errorf("cannot create v3 model: %w", err)
+		}
+		spec = convertOpenAPI(&model.Model)
+	default:
+		return nil, fmt.Errorf("spec type not supported: %s", document.GetSpecInfo().SpecType)
+	}
+	l.assignOperationIDs(spec)
+	return spec, nil
+}
+
+func convertSwagger(doc *v2.Swagger) *openapiloader.UnifiedAPISpec {
+	spec := &openapiloader.UnifiedAPISpec{
+		Version:  doc.Swagger,
+		Schemas:  map[string]interface{}{},
+		Security: map[string]openapiloader.SecurityScheme{},
+	}
+	if doc.Info != nil {
+		spec.Title = doc.Info.Title
+	}
+	if doc.Host != "" {
+		schemes := doc.Schemes
+		if len(schemes) == 0 {
+			schemes = []string{"https"}
+		}
+		for _, scheme := range schemes {
+			spec.Servers = append(spec.Servers, scheme+"://"+doc.Host+doc.BasePath)
+		}
+	} else if doc.BasePath != "" {
+		spec.Servers = append(spec.Servers, doc.BasePath)
+	}
+
+	if doc.Definitions != nil {
+		for name, schema := range doc.Definitions.Definitions.FromOldest() {
+			spec.Schemas[name] = schemaToMap(schema)
+		}
+	}
+	if doc.SecurityDefinitions != nil {
+		for name, scheme := range doc.SecurityDefinitions.Definitions.FromOldest() {
+			spec.Security[name] = swaggerSecurityScheme(scheme)
+		}
+	}
+
+	if doc.Paths == nil {
+		return spec
+	}
+	for path, item := range doc.Paths.PathItems.FromOldest() {
+		operations := []struct {
+			method string
+			op     *v2.Operation
+		}{
+			{"GET", item.Get}, {"PUT", item.Put}, {"POST", item.Post}, {"DELETE", item.Delete},
+			{"OPTIONS", item.Options}, {"HEAD", item.Head}, {"PATCH", item.Patch},
+		}
+		for _, o := range operations {
+			if o.op == nil {
+				continue
+			}
+			ep := openapiloader.APIEndpoint{
+				Path:        path,
+				Method:      o.method,
+				Operation:   o.op.OperationId,
+				Summary:     o.op.Summary,
+				Description: o.op.Description,
+				Tags:        o.op.Tags,
+				Responses:   map[string]openapiloader.Response{},
+				Security:    securityRequirements(o.op.Security, doc.Security),
+			}
+			consumes := o.op.Consumes
+			if len(consumes) == 0 {
+				consumes = doc.Consumes
+			}
+			for _, p := range mergeSwaggerParameters(item.Parameters, o.op.Parameters) {
+				if p.In == "body" {
+					ep.RequestBody = &openapiloader.RequestBody{
+						Required:    p.Required != nil && *p.Required,
+						ContentType: preferredContentType(consumes),
+						Schema:      schemaToMap(p.Schema),
+					}
+					continue
+				}
+				ep.Parameters = append(ep.Parameters, openapiloader.Parameter{
+					Name:     p.Name,
+					In:       p.In,
+					Required: p.Required != nil && *p.Required,
+					Schema:   swaggerParameterSchema(p),
+				})
+			}
+			if o.op.Responses != nil {
+				for code, resp := range o.op.Responses.Codes.FromOldest() {
+					ep.Responses[code] = openapiloader.Response{Code: code, Description: resp.Description, Schema: schemaToMap(resp.Schema)}
+				}
+				if resp := o.op.Responses.Default; resp != nil {
+					ep.Responses["default"] = openapiloader.Response{Code: "default", Description: resp.Description, Schema: schemaToMap(resp.Schema)}
+				}
+			}
+			spec.Endpoints = append(spec.Endpoints, ep)
+		}
+	}
+	return spec
+}
+
+func convertOpenAPI(doc *v3.Document) *openapiloader.UnifiedAPISpec {
+	spec := &openapiloader.UnifiedAPISpec{
+		Version:  doc.Version,
+		Schemas:  map[string]interface{}{},
+		Security: map[string]openapiloader.SecurityScheme{},
+	}
+	if doc.Info != nil {
+		spec.Title = doc.Info.Title
+	}
+	for _, server := range doc.Servers {
+		spec.Servers = append(spec.Servers, server.URL)
+	}
+
+	if doc.Components != nil {
+		for name, schema := range doc.Components.Schemas.FromOldest() {
+			spec.Schemas[name] = schemaToMap(schema)
+		}
+		for name, scheme := range doc.Components.SecuritySchemes.FromOldest() {
+			spec.Security[name] = openAPISecurityScheme(scheme)
+		}
+	}
+
+	if doc.Paths == nil {
+		return spec
+	}
+	for path, item := range doc.Paths.PathItems.FromOldest() {
+		operations := []struct {
+			method string
+			op     *v3.Operation
+		}{
+			{"GET", item.Get}, {"PUT", item.Put}, {"POST", item.Post}, {"DELETE", item.Delete},
+			{"OPTIONS", item.Options}, {"HEAD", item.Head}, {"PATCH", item.Patch}, {"TRACE", item.Trace},
+		}
+		for _, o := range operations {
+			if o.op == nil {
+				continue
+			}
+			ep := openapiloader.APIEndpoint{
+				Path:        path,
+				Method:      o.method,
+				Operation:   o.op.OperationId,
+				Summary:     o.op.Summary,
+				Description: o.op.Description,
+				Tags:        o.op.Tags,
+				Responses:   map[string]openapiloader.Response{},
+				Security:    securityRequirements(o.op.Security, doc.Security),
+			}
+			for _, p := range mergeOpenAPIParameters(item.Parameters, o.op.Parameters) {
+				param := openapiloader.Parameter{
+					Name:     p.Name,
+					In:       p.In,
+					Required: p.Required != nil && *p.Required,
+					Schema:   schemaToMap(p.Schema),
+				}
+				if param.Schema == nil {
+					_, param.Schema = mediaTypeSchema(p.Content)
+				}
+				ep.Parameters = append(ep.Parameters, param)
+			}
+			if rb := o.op.RequestBody; rb != nil {
+				contentType, schema := mediaTypeSchema(rb.Content)
+				ep.RequestBody = &openapiloader.RequestBody{
+					Required:    rb.Required != nil && *rb.Required,
+					ContentType: contentType,
+					Schema:      schema,
+				}
+			}
+			if o.op.Responses != nil {
+				for code, resp := range o.op.Responses.Codes.FromOldest() {
+					_, schema := mediaTypeSchema(resp.Content)
+					ep.Responses[code] = openapiloader.Response{Code: code, Description: resp.Description, Schema: schema}
+				}
+				if resp := o.op.Responses.Default; resp != nil {
+					_, schema := mediaTypeSchema(resp.Content)
+					ep.Responses["default"] = openapiloader.Response{Code: "default", Description: resp.Description, Schema: schema}
+				}
+			}
+			spec.Endpoints = append(spec.Endpoints, ep)
+		}
+	}
+	return spec
+}
+
+// mergeSwaggerParameters applies operation-level parameters on top of the
+// path-level ones; a parameter is identified by its name and location.
+func mergeSwaggerParameters(pathParams, opParams []*v2.Parameter) []*v2.Parameter {
+	merged := append([]*v2.Parameter{}, pathParams...)
+	for _, p := range opParams {
+		replaced := false
+		for i, existing := range merged {
+			if existing.Name == p.Name && existing.In == p.In {
+				merged[i] = p
+				replaced = true
+			}
+		}
+		if !replaced {
+			merged = append(merged, p)
+		}
+	}
+	return merged
+}
+
+// mergeOpenAPIParameters is the OpenAPI 3.x counterpart of mergeSwaggerParameters.
+func mergeOpenAPIParameters(pathParams, opParams []*v3.Parameter) []*v3.Parameter {
+	merged := append([]*v3.Parameter{}, pathParams...)
+	for _, p := range opParams {
+		replaced := false
+		for i, existing := range merged {
+			if existing.Name == p.Name && existing.In == p.In {
+				merged[i] = p
+				replaced = true
+			}
+		}
+		if !replaced {
+			merged = append(merged, p)
+		}
+	}
+	return merged
+}
+
+// swaggerParameterSchema builds a JSON schema for a non-body Swagger 2.0
+// parameter, whose type information lives on the parameter itself.
+func swaggerParameterSchema(p *v2.Parameter) interface{} {
+	if p.Schema != nil {
+		return schemaToMap(p.Schema)
+	}
+	schema := map[string]interface{}{}
+	if p.Type != "" {
+		schema["type"] = p.Type
+	}
+	if p.Format != "" {
+		schema["format"] = p.Format
+	}
+	if p.Pattern != "" {
+		schema["pattern"] = p.Pattern
+	}
+	if len(p.Enum) > 0 {
+		var values []interface{}
+		for _, node := range p.Enum {
+			var v interface{}
+			if node.Decode(&v) == nil {
+				values = append(values, v)
+			}
+		}
+		schema["enum"] = values
+	}
+	if p.Items != nil {
+		schema["items"] = swaggerItemsSchema(p.Items)
+	}
+	return schema
+}
+
+func swaggerItemsSchema(items *v2.Items) map[string]interface{} {
+	schema := map[string]interface{}{}
+	if items.Type != "" {
+		schema["type"] = items.Type
+	}
+	if items.Format != "" {
+		schema["format"] = items.Format
+	}
+	if items.Items != nil {
+		schema["items"] = swaggerItemsSchema(items.Items)
+	}
+	return schema
+}
+
+// mediaTypeSchema picks the JSON media type when present, otherwise the first
+// declared one, and returns its content type and rendered schema.
+func mediaTypeSchema(content *orderedmap.Map[string, *v3.MediaType]) (string, interface{}) {
+	var (
+		contentType string
+		media       *v3.MediaType
+	)
+	for ct, mt := range content.FromOldest() {
+		if media == nil || isJSONContentType(ct) && !isJSONContentType(contentType) {
+			contentType, media = ct, mt
+		}
+	}
+	if media == nil {
+		return "", nil
+	}
+	return contentType, schemaToMap(media.Schema)
+}
+
+func preferredContentType(types []string) string {
+	for _, ct := range types {
+		if isJSONContentType(ct) {
+			return ct
+		}
+	}
+	if len(types) > 0 {
+		return types[0]
+	}
+	return "application/json"
+}
+
+func isJSONContentType(ct string) bool {
+	return strings.HasPrefix(ct, "application/json") || strings.HasSuffix(strings.SplitN(ct, ";", 2)[0], "+json")
+}
+
+// securityRequirements returns the operation's requirements, falling back to
+// the document-wide ones when the operation does not declare its own.
+func securityRequirements(opSecurity, docSecurity []*base.SecurityRequirement) []map[string][]string {
+	reqs := opSecurity
+	if reqs == nil {
+		reqs = docSecurity
+	}
+	var out []map[string][]string
+	for _, req := range reqs {
+		if req == nil {
+			continue
+		}
+		entry := map[string][]string{}
+		for name, scopes := range req.Requirements.FromOldest() {
+			entry[name] = utils.SafeSlice(scopes)
+		}
+		out = append(out, entry)
+	}
+	return out
+}
+
+// swaggerSecurityScheme converts a Swagger 2.0 security definition.
+func swaggerSecurityScheme(s *v2.SecurityScheme) openapiloader.SecurityScheme {
+	scheme := openapiloader.SecurityScheme{Type: s.Type, Description: s.Description, Name: s.Name, In: s.In}
+	switch s.Type {
+	case "basic":
+		scheme.Type, scheme.Scheme = "http", "basic"
+	case "oauth2":
+		if s.Flow == "application" {
+			scheme.ClientCredentials = &openapiloader.OAuthFlow{TokenURL: s.TokenUrl, Scopes: map[string]string{}}
+			if s.Scopes != nil {
+				for name, desc := range s.Scopes.Values.FromOldest() {
+					scheme.ClientCredentials.Scopes[name] = desc
+				}
+			}
+		}
+	}
+	return scheme
+}
+
+// openAPISecurityScheme converts an OpenAPI 3.x security scheme.
+func openAPISecurityScheme(s *v3.SecurityScheme) openapiloader.SecurityScheme {
+	scheme := openapiloader.SecurityScheme{
+		Type:         s.Type,
+		Description:  s.Description,
+		Name:         s.Name,
+		In:           s.In,
+		Scheme:       strings.ToLower(s.Scheme),
+		BearerFormat: s.BearerFormat,
+	}
+	if s.Flows != nil && s.Flows.ClientCredentials != nil {
+		flow := s.Flows.ClientCredentials
+		scheme.ClientCredentials = &openapiloader.OAuthFlow{TokenURL: flow.TokenUrl, RefreshURL: flow.RefreshUrl, Scopes: map[string]string{}}
+		for name, desc := range flow.Scopes.FromOldest() {
+			scheme.ClientCredentials.Scopes[name] = desc
+		}
+	}
+	return scheme
+}
+
+// schemaToMap renders a schema into plain maps and slices. References are kept
+// as {"$ref": "..."} so that recursive schemas stay finite.
+func schemaToMap(schema *base.SchemaProxy) interface{} {
+	if schema == nil {
+		return nil
+	}
+	return renderToMap(schema)
+}
+
+func renderToMap(v interface{}) interface{} {
+	out, err := yaml.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var decoded interface{}
+	if err := yaml.Unmarshal(out, &decoded); err != nil {
+		return nil
+	}
+	return normalizeYAML(decoded)
+}
+
+// normalizeYAML converts map[interface{}]interface{} values produced by the YAML
+// decoder into map[string]interface{} so the result is JSON-compatible.
+func normalizeYAML(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, val := range t {
+			t[k] = normalizeYAML(val)
+		}
+		return t
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			m[fmt.Sprint(k)] = normalizeYAML(val)
+		}
+		return m
+	case []interface{}:
+		for i, val := range t {
+			t[i] = normalizeYAML(val)
+		}
+		return t
+	default:
+		return v
+	}
+}
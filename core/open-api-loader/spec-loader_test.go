@@ -0,0 +1,140 @@
+package open_api_loader
+
+import (
+	"MCPGen/core/openapi-loader"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSpecLoader_LoadOpenAPI(t *testing.T) {
+	spec, err := NewSpecLoader().LoadSpec("testdata/petstore_openapi.yaml")
+	require.NoError(t, err)
+
+	assert.Equal(t, "3.0.3", spec.Version)
+	assert.Equal(t, "Petstore", spec.Title)
+	assert.Equal(t, []string{"https://petstore.example.com/v1"}, spec.Servers)
+	require.Len(t, spec.Endpoints, 2)
+
+	get := spec.Endpoints[0]
+	assert.Equal(t, "getPet", get.Operation)
+	assert.Equal(t, "GET", get.Method)
+	assert.Equal(t, "/pets/{petId}", get.Path)
+	assert.Equal(t, []string{"pets"}, get.Tags)
+	require.Len(t, get.Parameters, 2)
+	assert.Equal(t, "petId", get.Parameters[0].Name)
+	assert.True(t, get.Parameters[0].Required)
+	assert.Equal(t, map[string]interface{}{"type": "string"}, get.Parameters[0].Schema)
+	assert.Equal(t, "verbose", get.Parameters[1].Name)
+	assert.Equal(t, map[string]interface{}{"$ref": "#/components/schemas/Pet"}, get.Responses["200"].Schema)
+	assert.Contains(t, get.Responses, "default")
+	assert.Equal(t, []map[string][]string{{"api_key": {}}}, get.Security)
+
+	put := spec.Endpoints[1]
+	assert.Equal(t, "updatePet", put.Operation)
+	require.NotNil(t, put.RequestBody)
+	assert.True(t, put.RequestBody.Required)
+	assert.Equal(t, "application/json", put.RequestBody.ContentType)
+	assert.Equal(t, []map[string][]string{{"bearer": {}}}, put.Security)
+
+	assert.Contains(t, spec.Schemas, "Pet")
+	require.Contains(t, spec.Security, "api_key")
+	assert.Equal(t, openapiloader.SecurityScheme{Type: "apiKey", Name: "X-API-Key", In: "header"}, spec.Security["api_key"])
+	assert.Equal(t, openapiloader.SecurityScheme{Type: "http", Scheme: "bearer"}, spec.Security["bearer"])
+}
+
+func TestSpecLoader_LoadSwagger(t *testing.T) {
+	spec, err := NewSpecLoader().LoadSpec("testdata/petstore_swagger.yaml")
+	require.NoError(t, err)
+
+	assert.Equal(t, "2.0", spec.Version)
+	assert.Equal(t, []string{"https://petstore.example.com/v1"}, spec.Servers)
+	require.Len(t, spec.Endpoints, 2)
+
+	create := spec.Endpoints[0]
+	assert.Equal(t, "createPet", create.Operation)
+	require.NotNil(t, create.RequestBody)
+	assert.Equal(t, "application/json", create.RequestBody.ContentType)
+	assert.Equal(t, map[string]interface{}{"$ref": "#/definitions/Pet"}, create.RequestBody.Schema)
+	require.Len(t, create.Parameters, 1)
+	assert.Equal(t, "header", create.Parameters[0].In)
+
+	get := spec.Endpoints[1]
+	assert.Equal(t, map[string]interface{}{"type": "integer", "format": "int64"}, get.Parameters[0].Schema)
+	assert.Nil(t, get.Security)
+
+	assert.Contains(t, spec.Schemas, "Pet")
+	assert.Contains(t, spec.Security, "api_key")
+}
+
+func TestSpecLoader_SecuritySchemes(t *testing.T) {
+	openapi, err := NewSpecLoader().LoadSpecBytes([]byte(`openapi: 3.0.3
+info: {title: Secured, version: "1"}
+paths: {}
+components:
+  securitySchemes:
+    query_key: {type: apiKey, name: key, in: query}
+    session: {type: apiKey, name: SESSION, in: cookie}
+    basic: {type: http, scheme: Basic}
+    jwt: {type: http, scheme: bearer, bearerFormat: JWT}
+    oauth:
+      type: oauth2
+      flows:
+        clientCredentials:
+          tokenUrl: https://auth.example.com/token
+          scopes: {read: Read access}
+        authorizationCode:
+          authorizationUrl: https://auth.example.com/authorize
+          tokenUrl: https://auth.example.com/token
+          scopes: {}
+`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]openapiloader.SecurityScheme{
+		"query_key": {Type: "apiKey", Name: "key", In: "query"},
+		"session":   {Type: "apiKey", Name: "SESSION", In: "cookie"},
+		"basic":     {Type: "http", Scheme: "basic"},
+		"jwt":       {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+		"oauth": {Type: "oauth2", ClientCredentials: &openapiloader.OAuthFlow{
+			TokenURL: "https://auth.example.com/token",
+			Scopes:   map[string]string{"read": "Read access"},
+		}},
+	}, openapi.Security)
+
+	swagger, err := NewSpecLoader().LoadSpecBytes([]byte(`swagger: "2.0"
+info: {title: Secured, version: "1"}
+paths: {}
+securityDefinitions:
+  basic: {type: basic}
+  oauth:
+    type: oauth2
+    flow: application
+    tokenUrl: https://auth.example.com/token
+    scopes: {write: Write access}
+  implicit:
+    type: oauth2
+    flow: implicit
+    authorizationUrl: https://auth.example.com/authorize
+    scopes: {}
+`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]openapiloader.SecurityScheme{
+		"basic": {Type: "http", Scheme: "basic"},
+		"oauth": {Type: "oauth2", ClientCredentials: &openapiloader.OAuthFlow{
+			TokenURL: "https://auth.example.com/token",
+			Scopes:   map[string]string{"write": "Write access"},
+		}},
+		"implicit": {Type: "oauth2"},
+	}, swagger.Security)
+}
+
+func TestSpecLoader_FileNotFound(t *testing.T) {
+	_, err := NewSpecLoader().LoadSpec("testdata/missing.yaml")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot read file")
+}
+
+func TestSpecLoader_UnsupportedDocument(t *testing.T) {
+	_, err := NewSpecLoader().LoadSpecBytes([]byte("hello: world"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "spec type not supported")
+}
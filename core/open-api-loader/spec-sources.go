@@ -0,0 +1,271 @@
+package open_api_loader
+
+import (
+	"MCPGen/core/openapi-loader"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// specExtensions are the file extensions picked up from a spec directory.
+var specExtensions = map[string]bool{".yaml": true, ".yml": true, ".json": true}
+
+// LoadSpecs loads every spec pattern names. A file, URL or "-" is loaded as
+// LoadSpec does. A directory loads its .yaml, .yml and .json files and a glob
+// the files it matches, in name order; files that are not an OpenAPI or
+// Swagger document, such as the schemas of a split spec, are skipped, but a
+// .yaml, .yml or .json file that does not parse is an error.
+func (l *SpecLoader) LoadSpecs(pattern string) ([]*openapiloader.UnifiedAPISpec, error) {
+	paths, err := ExpandSpecs(pattern)
+	if err != nil {
+		return nil, err
+	}
+	specs := make([]*openapiloader.UnifiedAPISpec, 0, len(paths))
+	for _, path := range paths {
+		spec, err := l.LoadSpec(path)
+		if err != nil {
+			if len(paths) > 1 {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			return nil, err
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// ExpandSpecs returns the spec files of a directory or glob, and any other
+// pattern unchanged. It fails on a .yaml, .yml or .json file that does not
+// parse, rather than skip a spec with a syntax error.
+func ExpandSpecs(pattern string) ([]string, error) {
+	if pattern == "-" || isRemote(pattern) {
+		return []string{pattern}, nil
+	}
+	var candidates []string
+	if info, err := os.Stat(pattern); err == nil && info.IsDir() {
+		entries, err := os.ReadDir(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read directory: %w", err)
+		}
+		for _, e := range entries {
+			if !e.IsDir() && specExtensions[strings.ToLower(filepath.Ext(e.Name()))] {
+				candidates = append(candidates, filepath.Join(pattern, e.Name()))
+			}
+		}
+	} else if strings.ContainsAny(pattern, "*?[") {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %w", pattern, err)
+		}
+		for _, m := range matches {
+			if info, err := os.Stat(m); err == nil && !info.IsDir() {
+				candidates = append(candidates, m)
+			}
+		}
+	} else {
+		return []string{pattern}, nil
+	}
+
+	sort.Strings(candidates)
+	var paths []string
+	for _, c := range candidates {
+		ok, err := isSpecFile(c)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			paths = append(paths, c)
+		}
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no specs found in %s", pattern)
+	}
+	return paths, nil
+}
+
+// isSpecFile reports whether path holds an OpenAPI or Swagger document. Only
+// files with a spec extension that cannot be read or parsed are an error;
+// other files a glob matches are not specs.
+func isSpecFile(path string) (bool, error) {
+	spec := specExtensions[strings.ToLower(filepath.Ext(path))]
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if spec {
+			return false, fmt.Errorf("cannot read file %s: %w", path, err)
+		}
+		return false, nil
+	}
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		if spec {
+			return false, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		return false, nil
+	}
+	m, ok := doc.(map[string]interface{})
+	if !ok {
+		return false, nil
+	}
+	_, openapi := m["openapi"]
+	_, swagger := m["swagger"]
+	return openapi || swagger, nil
+}
+
+// readSpec returns the document at path and the location its relative
+// references are resolved against.
+func (l *SpecLoader) readSpec(path string) ([]byte, string, error) {
+	switch {
+	case path == "-":
+		stdin := l.Stdin
+		if stdin == nil {
+			stdin = os.Stdin
+		}
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return nil, "", fmt.Errorf("cannot read stdin: %w", err)
+		}
+		location, err := l.baseLocation()
+		return data, location, err
+	case isRemote(path):
+		data, err := l.fetch(path, true)
+		return data, path, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot read file: %w", err)
+	}
+	location, err := filepath.Abs(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot resolve path: %w", err)
+	}
+	return data, location, nil
+}
+
+// baseLocation places an in-memory document in BaseDir, so its relative
+// references resolve against it.
+func (l *SpecLoader) baseLocation() (string, error) {
+	base, err := filepath.Abs(l.BaseDir)
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve base directory: %w", err)
+	}
+	return filepath.Join(base, "openapi.yaml"), nil
+}
+
+// fetch downloads a remote document, sending Headers when authorized. With
+// CacheDir set the document is stored with its ETag and revalidated on the
+// next fetch; Offline only reads the cache.
+func (l *SpecLoader) fetch(location string, authorized bool) ([]byte, error) {
+	var cached, etag []byte
+	var cacheFile string
+	if l.CacheDir != "" {
+		sum := sha256.Sum256([]byte(location))
+		cacheFile = filepath.Join(l.CacheDir, hex.EncodeToString(sum[:]))
+		if data, err := os.ReadFile(cacheFile); err == nil {
+			cached = data
+			etag, _ = os.ReadFile(cacheFile + ".etag")
+		}
+	}
+	if l.Offline {
+		if cached == nil {
+			return nil, fmt.Errorf("%s is not cached and the loader is offline", location)
+		}
+		return cached, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, location, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", location, err)
+	}
+	if authorized {
+		for name, values := range l.Headers {
+			req.Header[name] = values
+		}
+	}
+	if cached != nil && len(etag) > 0 {
+		req.Header.Set("If-None-Match", string(etag))
+	}
+	client := l.HTTPClient
+	if client == nil {
+		timeout := l.Timeout
+		if timeout == 0 {
+			timeout = defaultTimeout
+		}
+		client = &http.Client{Timeout: timeout}
+	}
+	if authorized && len(l.Headers) > 0 {
+		client = l.withoutHeadersOffOrigin(client)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", location, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		return cached, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: %s", location, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", location, err)
+	}
+	if cacheFile != "" {
+		if err := l.store(cacheFile, data, resp.Header.Get("ETag")); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// withoutHeadersOffOrigin returns a copy of client that drops Headers from
+// redirects leaving the scheme and host of the first request. Go only strips
+// Authorization and cookies itself, not API keys in custom headers.
+func (l *SpecLoader) withoutHeadersOffOrigin(client *http.Client) *http.Client {
+	c := *client
+	next := client.CheckRedirect
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		origin := via[0].URL
+		if req.URL.Scheme != origin.Scheme || !strings.EqualFold(req.URL.Host, origin.Host) {
+			for name := range l.Headers {
+				req.Header.Del(name)
+			}
+		}
+		if next != nil {
+			return next(req, via)
+		}
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+	return &c
+}
+
+func (l *SpecLoader) store(cacheFile string, data []byte, etag string) error {
+	if err := os.MkdirAll(l.CacheDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	if err := os.WriteFile(cacheFile, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write cache: %w", err)
+	}
+	if etag == "" {
+		if err := os.Remove(cacheFile + ".etag"); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to write cache: %w", err)
+		}
+		return nil
+	}
+	if err := os.WriteFile(cacheFile+".etag", []byte(etag), 0o644); err != nil {
+		return fmt.Errorf("failed to write cache: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package open_api_loader
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpecLoader_LoadURL(t *testing.T) {
+	spec, err := os.ReadFile("testdata/multifile/openapi.yaml")
+	require.NoError(t, err)
+	files := map[string]string{"/api/openapi.yaml": string(spec)}
+	for _, name := range []string{"parameters/petId.yaml", "schemas/Pet.yaml", "schemas/NewPet.yaml", "schemas/common.yaml"} {
+		data, err := os.ReadFile("testdata/multifile/components/" + name)
+		require.NoError(t, err)
+		files["/api/components/"+name] = string(data)
+	}
+
+	var downloads, notModified int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		etag := `"` + r.URL.Path + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		downloads++
+		w.Header().Set("ETag", etag)
+		_, _ = w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	_, err = NewSpecLoader().LoadSpec(srv.URL + "/api/openapi.yaml")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "401")
+
+	loader := &SpecLoader{Headers: http.Header{"Authorization": {"Bearer secret"}}, CacheDir: t.TempDir()}
+	loaded, err := loader.LoadSpec(srv.URL + "/api/openapi.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, srv.URL+"/api/openapi.yaml", loaded.Source)
+	assert.Len(t, loaded.Endpoints, 2)
+	assert.Contains(t, loaded.Schemas, "Category")
+	assert.Equal(t, 5, downloads, "the spec and its relative references are fetched from the same host")
+
+	_, err = loader.LoadSpec(srv.URL + "/api/openapi.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, 5, downloads)
+	assert.Equal(t, 5, notModified, "cached documents are revalidated with their ETag")
+}
+
+func TestSpecLoader_HeadersStayOnOrigin(t *testing.T) {
+	spec, err := os.ReadFile("testdata/petstore_openapi.yaml")
+	require.NoError(t, err)
+	var keys []string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, "other:"+r.Header.Get("X-API-Key"))
+		_, _ = w.Write(spec)
+	}))
+	defer other.Close()
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, "origin:"+r.Header.Get("X-API-Key"))
+		switch r.URL.Path {
+		case "/moved.yaml":
+			http.Redirect(w, r, "/openapi.yaml", http.StatusFound)
+		case "/elsewhere.yaml":
+			http.Redirect(w, r, other.URL+"/openapi.yaml", http.StatusFound)
+		default:
+			_, _ = w.Write(spec)
+		}
+	}))
+	defer origin.Close()
+
+	loader := &SpecLoader{Headers: http.Header{"X-Api-Key": {"secret"}}}
+	_, err = loader.LoadSpec(origin.URL + "/moved.yaml")
+	require.NoError(t, err)
+	_, err = loader.LoadSpec(origin.URL + "/elsewhere.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"origin:secret", "origin:secret", "origin:secret", "other:"}, keys)
+}
+
+func TestSpecLoader_LoadURLTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	_, err := (&SpecLoader{Timeout: 20 * time.Millisecond}).LoadSpec(srv.URL + "/openapi.yaml")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to fetch")
+}
+
+func TestSpecLoader_LoadStdin(t *testing.T) {
+	data, err := os.ReadFile("testdata/multifile/openapi.yaml")
+	require.NoError(t, err)
+	loader := &SpecLoader{BaseDir: "testdata/multifile", Stdin: strings.NewReader(string(data))}
+	spec, err := loader.LoadSpec("-")
+	require.NoError(t, err)
+	assert.Equal(t, "-", spec.Source)
+	assert.Len(t, spec.Endpoints, 2)
+}
+
+func TestSpecLoader_LoadSpecsFromDirectoryAndGlob(t *testing.T) {
+	specs, err := NewSpecLoader().LoadSpecs("testdata/multifile")
+	require.NoError(t, err)
+	require.Len(t, specs, 1, "schema files of a split spec are skipped")
+	assert.Equal(t, "Split Petstore", specs[0].Title)
+
+	specs, err = NewSpecLoader().LoadSpecs("testdata/petstore_*.yaml")
+	require.NoError(t, err)
+	require.Len(t, specs, 2)
+	assert.Equal(t, "testdata/petstore_openapi.yaml", specs[0].Source)
+	assert.Equal(t, "testdata/petstore_swagger.yaml", specs[1].Source)
+
+	specs, err = NewSpecLoader().LoadSpecs("testdata/petstore_openapi.yaml")
+	require.NoError(t, err)
+	assert.Len(t, specs, 1)
+
+	_, err = NewSpecLoader().LoadSpecs("testdata/multifile/components/schemas")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no specs found")
+}
+
+func TestSpecLoader_LoadSpecsReportsBrokenFiles(t *testing.T) {
+	dir := t.TempDir()
+	spec, err := os.ReadFile("testdata/petstore_openapi.yaml")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "petstore.yaml"), spec, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("openapi: [\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "orders.json"), []byte(`{"openapi": "3.0.0",`), 0o644))
+
+	for _, pattern := range []string{dir, filepath.Join(dir, "*")} {
+		_, err := NewSpecLoader().LoadSpecs(pattern)
+		require.Error(t, err, pattern)
+		assert.Contains(t, err.Error(), "failed to parse "+filepath.Join(dir, "orders.json"))
+	}
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "orders.json")))
+	specs, err := NewSpecLoader().LoadSpecs(filepath.Join(dir, "*"))
+	require.NoError(t, err)
+	assert.Len(t, specs, 1, "files without a spec extension are skipped")
+}
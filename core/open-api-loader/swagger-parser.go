@@ -1,6 +1,7 @@
 package open_api_loader
 
 import (
+	"MCPGen/core/diagnostics"
 	"MCPGen/core/utils"
 	"fmt"
 	"github.com/pb33f/libopenapi"
@@ -8,10 +9,14 @@ import (
 	"os"
 )
 
+// ParseSwaggerFile summarizes the Swagger 2.0 document at filePath. The
+// error of a document that cannot be used carries a *diagnostics.Error.
 func ParseSwaggerFile(filePath string) (summary string, err error) {
 	defer func() {
 		if r := recover(); r != nil {
-			err = fmt.Errorf("cannot create v2 model")
+			d := panicDiagnostic(r)
+			d.File = filePath
+			err = fmt.Errorf("cannot create v2 model: %w", diagnostics.List{d}.Err())
 			summary = ""
 		}
 	}()
@@ -26,13 +31,19 @@ func ParseSwaggerFile(filePath string) (summary string, err error) {
 		return "", fmt.Errorf("cannot create new document: %w", err)
 	}
 
-	v2Model, errors := document.BuildV2Model()
-	if len(errors) > 0 {
-		return "", fmt.Errorf("cannot create v2 model from document: %d errors reported", len(errors))
+	v2Model, errs := document.BuildV2Model()
+	if err := specDiagnostics(document, errs).Err(); err != nil {
+		locateDiagnostics(err, filePath, swaggerSpec, swaggerSpec)
+		return "", fmt.Errorf("cannot create v2 model from document: %w", err)
 	}
 
-	paths := v2Model.Model.Paths.PathItems.Len()
-	schemas := v2Model.Model.Definitions.Definitions.Len()
+	var paths, schemas int
+	if v2Model.Model.Paths != nil {
+		paths = v2Model.Model.Paths.PathItems.Len()
+	}
+	if v2Model.Model.Definitions != nil {
+		schemas = v2Model.Model.Definitions.Definitions.Len()
+	}
 
 	summary = fmt.Sprintf(
 		"There are %d paths and %d schemas in the document\nSwagger version: %s\nSwagger info: %s\n",
@@ -1,11 +1,22 @@
 package open_api_loader
 
 import (
+	"MCPGen/core/diagnostics"
+	"errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"os"
 	"testing"
 )
 
+// diagnosticsOf returns the diagnostics err carries.
+func diagnosticsOf(t *testing.T, err error) diagnostics.List {
+	t.Helper()
+	var de *diagnostics.Error
+	require.True(t, errors.As(err, &de), "error carries no diagnostics: %v", err)
+	return de.Diagnostics
+}
+
 func TestParseSwaggerFile_ValidFile(t *testing.T) {
 	summary, err := ParseSwaggerFile("testdata/valid_swagger.yaml")
 	assert.NoError(t, err)
@@ -38,14 +49,23 @@ func TestParseSwaggerFile_ModelErrors(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "cannot create v2 model")
 	assert.Empty(t, summary)
+	assert.Equal(t, diagnostics.List{{
+		Severity: diagnostics.SeverityError,
+		Rule:     RuleSchemaType,
+		File:     "testdata/model_error_swagger.yaml",
+		Line:     17,
+		Column:   15,
+		Pointer:  "/definitions/InvalidModel/properties/name/type",
+		Message:  "schema type 'not_a_valid_type' is not one of array, boolean, file, integer, number, object, string",
+	}}, diagnosticsOf(t, err))
 }
 
-func TestParseSwaggerFile_MissingFieldsPanic(t *testing.T) {
+func TestParseSwaggerFile_MissingOptionalFields(t *testing.T) {
 	// This file is valid Swagger but has nil-able fields like Contact or License
 	summary, err := ParseSwaggerFile("testdata/missing_fields_swagger.yaml")
-	assert.Error(t, err)
-	assert.Equal(t, "cannot create v2 model", err.Error())
-	assert.Empty(t, summary)
+	assert.NoError(t, err)
+	assert.Contains(t, summary, "Swagger contact: (not specified)")
+	assert.Contains(t, summary, "Swagger license: (not specified)")
 }
 
 func TestParseSwaggerFile_EmptyFile(t *testing.T) {
@@ -96,19 +116,14 @@ paths:
 }
 
 func TestParseSwaggerFile_MissingPaths(t *testing.T) {
-	tmpFile := "testdata/no_paths.yaml"
-	_ = os.WriteFile(tmpFile, []byte(`
-swagger: "2.0"
-info:
-  title: No Paths
-  version: "1.0.0"
-`), 0644)
-	defer os.Remove(tmpFile)
-
-	summary, err := ParseSwaggerFile(tmpFile)
+	summary, err := ParseSwaggerFile("testdata/no_paths.yaml")
 	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "cannot create v3 model")
+	assert.Contains(t, err.Error(), "cannot create v2 model")
 	assert.Empty(t, summary)
+	diags := diagnosticsOf(t, err)
+	require.Len(t, diags, 1)
+	assert.Equal(t, RuleMissingPaths, diags[0].Rule)
+	assert.Equal(t, 2, diags[0].Line)
 }
 
 func TestParseSwaggerFile_MalformedPaths(t *testing.T) {
@@ -126,6 +141,7 @@ paths:
 
 	summary, err := ParseSwaggerFile(tmpFile)
 	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "cannot create v3 model")
+	assert.Contains(t, err.Error(), "cannot create v2 model")
+	assert.Contains(t, err.Error(), tmpFile+":8:5: error: operation GET /broken has no responses (at /paths/~1broken/get)")
 	assert.Empty(t, summary)
 }
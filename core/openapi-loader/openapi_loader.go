@@ -7,17 +7,55 @@ type OpenAPILoader interface {
 
 // UnifiedAPISpec is a normalized representation of an OpenAPI spec for downstream modules.
 type UnifiedAPISpec struct {
-	Version    string
-	Endpoints  []APIEndpoint
-	Schemas    map[string]interface{}
-	Security   map[string]interface{}
+	Version string
+	Title   string
+	// Source is the file or URL the spec was loaded from; empty for in-memory specs.
+	Source    string
+	Servers   []string
+	Endpoints []APIEndpoint
+	Schemas   map[string]interface{}
+	// Security holds the security schemes of the document by name.
+	Security map[string]SecurityScheme
+}
+
+// SecurityScheme is an entry of Swagger 2.0 securityDefinitions or OpenAPI 3.x
+// components.securitySchemes, in OpenAPI 3.x terms: a Swagger "basic" scheme
+// becomes Type "http" with Scheme "basic", and an "application" OAuth2 flow a
+// client-credentials flow.
+type SecurityScheme struct {
+	// Type is apiKey, http, oauth2, openIdConnect or mutualTLS.
+	Type        string
+	Description string
+	// Name and In locate the key of an apiKey scheme: In is header, query or
+	// cookie.
+	Name string
+	In   string
+	// Scheme is the lowercased HTTP authentication scheme, e.g. basic or bearer.
+	Scheme       string
+	BearerFormat string
+	// ClientCredentials is the OAuth2 client-credentials flow, if the scheme
+	// has one.
+	ClientCredentials *OAuthFlow
+}
+
+// OAuthFlow describes an OAuth2 flow and the scopes it grants.
+type OAuthFlow struct {
+	TokenURL   string
+	RefreshURL string
+	Scopes     map[string]string
 }
 
 type APIEndpoint struct {
-	Path       string
-	Method     string
-	Operation  string
-	Parameters []Parameter
+	Path        string
+	Method      string
+	Operation   string
+	Summary     string
+	Description string
+	Tags        []string
+	Parameters  []Parameter
+	RequestBody *RequestBody
+	Responses   map[string]Response
+	Security    []map[string][]string
 }
 
 type Parameter struct {
@@ -26,3 +64,18 @@ type Parameter struct {
 	Required bool
 	Schema   interface{}
 }
+
+// RequestBody describes the payload accepted by an operation. Swagger 2.0 body
+// parameters are normalized into this shape as well.
+type RequestBody struct {
+	Required    bool
+	ContentType string
+	Schema      interface{}
+}
+
+// Response describes a single documented response of an operation.
+type Response struct {
+	Code        string
+	Description string
+	Schema      interface{}
+}
@@ -0,0 +1,263 @@
+package pluginmanager
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RequestIDHeader carries the ID of a request, from the client if it sent
+// one, and back in the response.
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the ID RequestID gave the request of ctx, or
+// "" outside of it. The tool calls of an MCP request share its context.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestID gives every request an ID: the X-Request-ID it was sent with, or
+// a random one. The ID is returned in X-Request-ID and stored in the context
+// of the request.
+func RequestID() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(RequestIDHeader)
+			if id == "" || len(id) > 128 {
+				id = newRequestID()
+			}
+			w.Header().Set(RequestIDHeader, id)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+		})
+	}
+}
+
+func newRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// LogRequests logs the method, path, status, size and duration of every
+// request to logger, with its ID when RequestID runs before it.
+func LogRequests(logger *log.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w}
+			defer func() {
+				status := rec.status
+				if status == 0 {
+					status = http.StatusOK
+				}
+				logger.Printf("%s%s %s %d %dB %s", requestPrefix(r.Context()), r.Method, r.URL.Path, status, rec.size, time.Since(start).Round(time.Microsecond))
+			}()
+			next.ServeHTTP(rec, r)
+		})
+	}
+}
+
+// RecoverPanics answers a request whose handler panics with 500 and logs the
+// panic to logger, rather than dropping the connection.
+func RecoverPanics(logger *log.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := &statusRecorder{ResponseWriter: w}
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+				logger.Printf("%spanic serving %s %s: %v\n%s", requestPrefix(r.Context()), r.Method, r.URL.Path, v, debug.Stack())
+				if rec.status == 0 {
+					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				}
+			}()
+			next.ServeHTTP(rec, r)
+		})
+	}
+}
+
+// CORSOptions configures CORS.
+type CORSOptions struct {
+	// AllowedOrigins lists the origins allowed to call the server; "*"
+	// allows any, without credentials.
+	AllowedOrigins []string
+	// AllowedMethods defaults to GET, POST, DELETE and OPTIONS.
+	AllowedMethods []string
+	// AllowedHeaders defaults to the headers MCP clients send.
+	AllowedHeaders []string
+	// ExposedHeaders defaults to the headers MCP clients read.
+	ExposedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response.
+	MaxAge time.Duration
+}
+
+// CORS lets browsers call the server from the allowed origins. Preflight
+// requests from them are answered with 204; requests from other origins are
+// passed on without CORS headers, so browsers reject their responses. An
+// origin only allowed by "*" is answered with "*" and never with
+// credentials, so that any site cannot make credentialed calls.
+func CORS(opts CORSOptions) Middleware {
+	if len(opts.AllowedMethods) == 0 {
+		opts.AllowedMethods = []string{http.MethodGet, http.MethodPost, http.MethodDelete, http.MethodOptions}
+	}
+	if len(opts.AllowedHeaders) == 0 {
+		opts.AllowedHeaders = []string{"Content-Type", "Authorization", "X-API-Key", "Mcp-Session-Id", "Mcp-Protocol-Version", "Last-Event-ID", RequestIDHeader}
+	}
+	if len(opts.ExposedHeaders) == 0 {
+		opts.ExposedHeaders = []string{"Mcp-Session-Id", RequestIDHeader}
+	}
+	methods := strings.Join(opts.AllowedMethods, ", ")
+	headers := strings.Join(opts.AllowedHeaders, ", ")
+	exposed := strings.Join(opts.ExposedHeaders, ", ")
+	// allowed returns the Access-Control-Allow-Origin of origin, or "" when
+	// it is not allowed.
+	allowed := func(origin string) string {
+		wildcard := false
+		for _, o := range opts.AllowedOrigins {
+			if strings.EqualFold(o, origin) {
+				return origin
+			}
+			wildcard = wildcard || o == "*"
+		}
+		if wildcard {
+			return "*"
+		}
+		return ""
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			allow := ""
+			if origin != "" {
+				allow = allowed(origin)
+			}
+			if allow == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			h := w.Header()
+			h.Add("Vary", "Origin")
+			h.Set("Access-Control-Allow-Origin", allow)
+			if opts.AllowCredentials && allow != "*" {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				h.Set("Access-Control-Allow-Methods", methods)
+				h.Set("Access-Control-Allow-Headers", headers)
+				if opts.MaxAge > 0 {
+					h.Set("Access-Control-Max-Age", strconv.Itoa(int(opts.MaxAge.Seconds())))
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			h.Set("Access-Control-Expose-Headers", exposed)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// LimitBody rejects request bodies larger than maxBytes with 413. A body
+// without Content-Length is cut off at the limit, failing its handler's read.
+func LimitBody(maxBytes int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > maxBytes {
+				http.Error(w, fmt.Sprintf("request body exceeds %d bytes", maxBytes), http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// LogToolCalls logs the name, outcome and duration of every tool call to
+// logger.
+func LogToolCalls(logger *log.Logger) ToolMiddleware {
+	return func(next ToolHandler) ToolHandler {
+		return func(ctx context.Context, call *ToolCall) (any, error) {
+			start := time.Now()
+			out, err := next(ctx, call)
+			outcome := "ok"
+			if err != nil {
+				outcome = "error: " + err.Error()
+			}
+			logger.Printf("%stool %s: %s %s", requestPrefix(ctx), call.Name, outcome, time.Since(start).Round(time.Microsecond))
+			return out, err
+		}
+	}
+}
+
+// RecoverToolPanics turns a panic of a tool call into its error.
+func RecoverToolPanics() ToolMiddleware {
+	return func(next ToolHandler) ToolHandler {
+		return func(ctx context.Context, call *ToolCall) (out any, err error) {
+			defer func() {
+				if v := recover(); v != nil {
+					out, err = nil, fmt.Errorf("panic: %v", v)
+				}
+			}()
+			return next(ctx, call)
+		}
+	}
+}
+
+func requestPrefix(ctx context.Context) string {
+	if id := RequestIDFromContext(ctx); id != "" {
+		return "[" + id + "] "
+	}
+	return ""
+}
+
+// statusRecorder records the status and size of a response. It passes
+// flushes on, so event streams still reach the client as they are written.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.size += n
+	return n, err
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,52 @@
+// Package pluginmanager runs the pre- and post-hooks of workflow steps. Its
+// sources are copied into every generated server, so every file but this one
+// must compile as part of package main, and all but the WebAssembly hook only
+// depend on the standard library.
+package pluginmanager
+
+import (
+	"embed"
+	"regexp"
+	"strings"
+)
+
+//go:embed *.go
+var sourceFS embed.FS
+
+var packageClause = regexp.MustCompile(`(?m)^package pluginmanager$`)
+
+// wasmFiles holds the WebAssembly hook, which depends on wazero.
+var wasmFiles = map[string]bool{"wasm_hook.go": true}
+
+// Sources returns the files of this package rewritten into package main,
+// keyed by file name. Tests, this file and the WebAssembly hook are left out.
+func Sources() (map[string][]byte, error) {
+	return sources(func(name string) bool { return !wasmFiles[name] })
+}
+
+// WASMSources returns the files of the WebAssembly hook, rewritten like
+// Sources. A module including them must require wazero.
+func WASMSources() (map[string][]byte, error) {
+	return sources(func(name string) bool { return wasmFiles[name] })
+}
+
+func sources(include func(name string) bool) (map[string][]byte, error) {
+	entries, err := sourceFS.ReadDir(".")
+	if err != nil {
+		return nil, err
+	}
+	files := map[string][]byte{}
+	for _, e := range entries {
+		name := e.Name()
+		if name == "embed.go" || strings.HasSuffix(name, "_test.go") || !include(name) {
+			continue
+		}
+		src, err := sourceFS.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		src = packageClause.ReplaceAll(src, []byte("// Code generated by mcpgen. DO NOT EDIT.\n\npackage main"))
+		files[name] = src
+	}
+	return files, nil
+}
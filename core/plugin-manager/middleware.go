@@ -0,0 +1,106 @@
+package pluginmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// Middleware wraps the HTTP handler of the server.
+type Middleware func(next http.Handler) http.Handler
+
+// ToolCall is an MCP tools/call request.
+type ToolCall struct {
+	Name      string
+	Arguments map[string]any
+}
+
+// ToolHandler runs a tool call and returns its result, or the error the
+// client is told about in an isError result.
+type ToolHandler func(ctx context.Context, call *ToolCall) (any, error)
+
+// ToolMiddleware wraps the handler of tools/call requests.
+type ToolMiddleware func(next ToolHandler) ToolHandler
+
+type middlewareEntry struct {
+	name     string
+	priority int
+	seq      int
+	http     Middleware
+	tool     ToolMiddleware
+}
+
+// InjectMiddleware adds mw to the HTTP middleware of the server under name,
+// which must be unique. Middleware with the lowest priority runs first, and
+// sees the request before and the response after all others.
+func (pm *PluginManager) InjectMiddleware(name string, priority int, mw Middleware) error {
+	if mw == nil {
+		return fmt.Errorf("middleware %s is nil", name)
+	}
+	return pm.inject(name, middlewareEntry{priority: priority, http: mw})
+}
+
+// InjectToolMiddleware adds mw to the middleware run around every tools/call
+// request under name, ordered like InjectMiddleware.
+func (pm *PluginManager) InjectToolMiddleware(name string, priority int, mw ToolMiddleware) error {
+	if mw == nil {
+		return fmt.Errorf("tool middleware %s is nil", name)
+	}
+	return pm.inject(name, middlewareEntry{priority: priority, tool: mw})
+}
+
+func (pm *PluginManager) inject(name string, e middlewareEntry) error {
+	if name == "" {
+		return errors.New("middleware name must not be empty")
+	}
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	for _, other := range pm.middleware {
+		if other.name == name && (other.http == nil) == (e.http == nil) {
+			return fmt.Errorf("middleware %s is already injected", name)
+		}
+	}
+	pm.seq++
+	e.name, e.seq = name, pm.seq
+	pm.middleware = append(pm.middleware, e)
+	return nil
+}
+
+// WrapHandler returns h wrapped in the HTTP middleware injected so far.
+func (pm *PluginManager) WrapHandler(h http.Handler) http.Handler {
+	entries := pm.chain(true)
+	for i := len(entries) - 1; i >= 0; i-- {
+		h = entries[i].http(h)
+	}
+	return h
+}
+
+// WrapToolHandler returns h wrapped in the tool middleware injected so far.
+func (pm *PluginManager) WrapToolHandler(h ToolHandler) ToolHandler {
+	entries := pm.chain(false)
+	for i := len(entries) - 1; i >= 0; i-- {
+		h = entries[i].tool(h)
+	}
+	return h
+}
+
+// chain returns the HTTP or tool middleware, outermost first.
+func (pm *PluginManager) chain(wantHTTP bool) []middlewareEntry {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	var out []middlewareEntry
+	for _, e := range pm.middleware {
+		if (e.http != nil) == wantHTTP {
+			out = append(out, e)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].priority != out[j].priority {
+			return out[i].priority < out[j].priority
+		}
+		return out[i].seq < out[j].seq
+	})
+	return out
+}
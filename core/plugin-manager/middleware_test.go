@@ -0,0 +1,193 @@
+package pluginmanager
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func tag(order *[]string, name string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*order = append(*order, name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestWrapHandler_Order(t *testing.T) {
+	pm := NewPluginManager()
+	var order []string
+	mustRegister(t, pm.InjectMiddleware("late", 10, tag(&order, "late")))
+	mustRegister(t, pm.InjectMiddleware("first", 0, tag(&order, "first")))
+	mustRegister(t, pm.InjectMiddleware("second", 0, tag(&order, "second")))
+
+	h := pm.WrapHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "handler")
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if want := []string{"first", "second", "late", "handler"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("middleware ran in order %v, want %v", order, want)
+	}
+
+	if err := pm.InjectMiddleware("first", 0, tag(&order, "again")); err == nil {
+		t.Error("expected an error for a duplicate name")
+	}
+	if err := pm.InjectMiddleware("", 0, tag(&order, "")); err == nil {
+		t.Error("expected an error for an empty name")
+	}
+	if err := pm.InjectToolMiddleware("nil", 0, nil); err == nil {
+		t.Error("expected an error for nil middleware")
+	}
+}
+
+func TestWrapToolHandler(t *testing.T) {
+	pm := NewPluginManager()
+	var logs bytes.Buffer
+	// The same name may be used once for HTTP and once for tool middleware.
+	mustRegister(t, pm.InjectMiddleware("logging", 0, LogRequests(log.New(&logs, "", 0))))
+	mustRegister(t, pm.InjectToolMiddleware("logging", 0, LogToolCalls(log.New(&logs, "", 0))))
+	mustRegister(t, pm.InjectToolMiddleware("recovery", 1, RecoverToolPanics()))
+	mustRegister(t, pm.InjectToolMiddleware("rename", 2, func(next ToolHandler) ToolHandler {
+		return func(ctx context.Context, call *ToolCall) (any, error) {
+			call.Arguments["seen"] = true
+			return next(ctx, call)
+		}
+	}))
+
+	h := pm.WrapToolHandler(func(ctx context.Context, call *ToolCall) (any, error) {
+		if call.Name == "boom" {
+			panic("kaboom")
+		}
+		return call.Arguments, nil
+	})
+	out, err := h(context.Background(), &ToolCall{Name: "echo", Arguments: map[string]any{}})
+	if err != nil || !reflect.DeepEqual(out, map[string]any{"seen": true}) {
+		t.Errorf("unexpected result %v, %v", out, err)
+	}
+	_, err = h(context.Background(), &ToolCall{Name: "boom", Arguments: map[string]any{}})
+	if err == nil || err.Error() != "panic: kaboom" {
+		t.Errorf("expected the panic as an error, got %v", err)
+	}
+	if !strings.Contains(logs.String(), "tool echo: ok") || !strings.Contains(logs.String(), "tool boom: error: panic: kaboom") {
+		t.Errorf("unexpected logs:\n%s", logs.String())
+	}
+}
+
+func TestRequestIDAndLogging(t *testing.T) {
+	var logs bytes.Buffer
+	var seen string
+	h := RequestID()(LogRequests(log.New(&logs, "", 0))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestIDFromContext(r.Context())
+		w.WriteHeader(http.StatusTeapot)
+		io.WriteString(w, "short")
+	})))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+	req.Header.Set(RequestIDHeader, "abc")
+	h.ServeHTTP(rec, req)
+	if seen != "abc" || rec.Header().Get(RequestIDHeader) != "abc" {
+		t.Errorf("expected the client's request ID, got %q and %q", seen, rec.Header().Get(RequestIDHeader))
+	}
+	if !strings.HasPrefix(logs.String(), "[abc] POST /mcp 418 5B ") {
+		t.Errorf("unexpected log line %q", logs.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if len(seen) != 32 || rec.Header().Get(RequestIDHeader) != seen {
+		t.Errorf("expected a generated request ID, got %q", seen)
+	}
+}
+
+func TestRecoverPanics(t *testing.T) {
+	var logs bytes.Buffer
+	h := RecoverPanics(log.New(&logs, "", 0))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("kaboom")
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/run-task/x", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500, got %d", rec.Code)
+	}
+	if !strings.Contains(logs.String(), "panic serving GET /run-task/x: kaboom") {
+		t.Errorf("unexpected logs:\n%s", logs.String())
+	}
+}
+
+func TestCORS(t *testing.T) {
+	called := false
+	h := CORS(CORSOptions{AllowedOrigins: []string{"https://app.example.com"}, AllowCredentials: true})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodOptions, "/mcp", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent || called {
+		t.Errorf("expected the preflight to be answered, got %d (handler called: %v)", rec.Code, called)
+	}
+	if rec.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" || rec.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Errorf("unexpected preflight headers %v", rec.Header())
+	}
+	if !strings.Contains(rec.Header().Get("Access-Control-Allow-Headers"), "Mcp-Session-Id") {
+		t.Errorf("expected the MCP headers to be allowed, got %q", rec.Header().Get("Access-Control-Allow-Headers"))
+	}
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/mcp", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	h.ServeHTTP(rec, req)
+	if !called || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("expected other origins to get no CORS headers, got %v", rec.Header())
+	}
+
+	h = CORS(CORSOptions{AllowedOrigins: []string{"https://app.example.com", "*"}, AllowCredentials: true})(http.NotFoundHandler())
+	for _, tt := range []struct{ origin, allow, credentials string }{
+		{"https://app.example.com", "https://app.example.com", "true"},
+		{"https://evil.example.com", "*", ""},
+	} {
+		rec = httptest.NewRecorder()
+		req = httptest.NewRequest(http.MethodGet, "/mcp", nil)
+		req.Header.Set("Origin", tt.origin)
+		h.ServeHTTP(rec, req)
+		if rec.Header().Get("Access-Control-Allow-Origin") != tt.allow || rec.Header().Get("Access-Control-Allow-Credentials") != tt.credentials {
+			t.Errorf("expected %s to be allowed as %q with credentials %q, got %v", tt.origin, tt.allow, tt.credentials, rec.Header())
+		}
+	}
+}
+
+func TestLimitBody(t *testing.T) {
+	var readErr error
+	h := LimitBody(4)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, readErr = io.ReadAll(r.Body)
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("too long")))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413, got %d", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/", io.NopCloser(strings.NewReader("too long")))
+	req.ContentLength = -1
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if readErr == nil {
+		t.Error("expected reading past the limit to fail")
+	}
+
+	readErr = nil
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader("ok")))
+	if readErr != nil {
+		t.Errorf("a small body must be read: %v", readErr)
+	}
+}
@@ -1,8 +1,189 @@
 package pluginmanager
 
-// PluginManager defines methods for managing hooks and middleware injection.
-type PluginManager interface {
-	RegisterPreHook(stepID string, hookFunc interface{}) error
-	RegisterPostHook(stepID string, hookFunc interface{}) error
-	InjectMiddleware(middlewareFunc interface{}) error
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// AllSteps is the name of the hooks every step runs, in addition to those it
+// names.
+const AllSteps = "*"
+
+// ErrAbort, wrapped in the error of a hook, fails the workflow at once: the
+// failure actions of the step are not applied.
+var ErrAbort = errors.New("workflow aborted")
+
+// StepContext is what hooks see of a step. Pre-hooks may change Inputs, the
+// arguments the step is called with.
+type StepContext struct {
+	FlowID string
+	StepID string
+	Inputs map[string]any
+	// Response short-circuits the step when a pre-hook sets it: the remaining
+	// pre-hooks and the call are skipped, and the post-hooks and success
+	// criteria see this response instead.
+	Response *StepResponse
+}
+
+// StepResponse is the response of the operation or nested workflow of a
+// step. Post-hooks may change it before the success criteria and outputs of
+// the step are evaluated.
+type StepResponse struct {
+	StatusCode int
+	Headers    http.Header
+	Body       any
+}
+
+// PreHook runs before a step calls its operation. Returning an error fails
+// the step.
+type PreHook func(ctx context.Context, sc *StepContext) error
+
+// PostHook runs once a step has a response. Returning an error fails the
+// step.
+type PostHook func(ctx context.Context, sc *StepContext, resp *StepResponse) error
+
+// HookError reports a hook that failed or panicked.
+type HookError struct {
+	// Stage is "pre-hook" or "post-hook".
+	Stage string
+	Name  string
+	Err   error
+}
+
+func (e *HookError) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.Stage, e.Name, e.Err)
+}
+
+func (e *HookError) Unwrap() error {
+	return e.Err
+}
+
+// PluginManager holds the hooks steps run, by name, and the middleware of the
+// server. Steps name their hooks with x-pre-hook and x-post-hook; hooks
+// registered under AllSteps run for every step. The hooks of a step run in
+// ascending order of priority, then in order of registration. It is safe for
+// concurrent use.
+type PluginManager struct {
+	mu         sync.RWMutex
+	hooks      map[string][]registration
+	middleware []middlewareEntry
+	seq        int
+}
+
+type registration struct {
+	name     string
+	priority int
+	seq      int
+	pre      PreHook
+	post     PostHook
+}
+
+// NewPluginManager returns a PluginManager without hooks.
+func NewPluginManager() *PluginManager {
+	return &PluginManager{hooks: map[string][]registration{}}
+}
+
+// RegisterPreHook adds hook to the pre-hooks named name.
+func (pm *PluginManager) RegisterPreHook(name string, priority int, hook PreHook) error {
+	if hook == nil {
+		return fmt.Errorf("pre-hook %s is nil", name)
+	}
+	return pm.register(name, registration{priority: priority, pre: hook})
+}
+
+// RegisterPostHook adds hook to the post-hooks named name.
+func (pm *PluginManager) RegisterPostHook(name string, priority int, hook PostHook) error {
+	if hook == nil {
+		return fmt.Errorf("post-hook %s is nil", name)
+	}
+	return pm.register(name, registration{priority: priority, post: hook})
+}
+
+func (pm *PluginManager) register(name string, r registration) error {
+	if name == "" {
+		return errors.New("hook name must not be empty")
+	}
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	pm.seq++
+	r.name, r.seq = name, pm.seq
+	pm.hooks[name] = append(pm.hooks[name], r)
+	return nil
+}
+
+// Registered reports whether hooks are registered under name.
+func (pm *PluginManager) Registered(name string) bool {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	return len(pm.hooks[name]) > 0
+}
+
+// RunPreHooks runs the pre-hooks of a step naming the hook name, which may be
+// empty, together with those of AllSteps. It stops at the first hook that
+// fails, and once a hook sets sc.Response.
+func (pm *PluginManager) RunPreHooks(ctx context.Context, name string, sc *StepContext) error {
+	for _, r := range pm.lookup(name, true) {
+		if err := runHook("pre-hook", r.name, func() error { return r.pre(ctx, sc) }); err != nil {
+			return err
+		}
+		if sc.Response != nil {
+			return nil
+		}
+	}
+	return nil
+}
+
+// RunPostHooks runs the post-hooks of a step naming the hook name, which may
+// be empty, together with those of AllSteps. It stops at the first hook that
+// fails.
+func (pm *PluginManager) RunPostHooks(ctx context.Context, name string, sc *StepContext, resp *StepResponse) error {
+	for _, r := range pm.lookup(name, false) {
+		if err := runHook("post-hook", r.name, func() error { return r.post(ctx, sc, resp) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lookup returns the pre- or post-hooks of name and AllSteps in the order
+// they run.
+func (pm *PluginManager) lookup(name string, pre bool) []registration {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	var out []registration
+	names := []string{AllSteps}
+	if name != "" && name != AllSteps {
+		names = append(names, name)
+	}
+	for _, n := range names {
+		for _, r := range pm.hooks[n] {
+			if (pre && r.pre != nil) || (!pre && r.post != nil) {
+				out = append(out, r)
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].priority != out[j].priority {
+			return out[i].priority < out[j].priority
+		}
+		return out[i].seq < out[j].seq
+	})
+	return out
+}
+
+// runHook runs a hook, turning its error or panic into a *HookError.
+func runHook(stage, name string, fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &HookError{Stage: stage, Name: name, Err: fmt.Errorf("panic: %v", r)}
+		}
+	}()
+	if err := fn(); err != nil {
+		return &HookError{Stage: stage, Name: name, Err: err}
+	}
+	return nil
 }
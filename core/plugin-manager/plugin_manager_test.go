@@ -0,0 +1,156 @@
+package pluginmanager
+
+import (
+	"context"
+	"errors"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRunPreHooks_OrderAndShortCircuit(t *testing.T) {
+	pm := NewPluginManager()
+	var order []string
+	record := func(name string) PreHook {
+		return func(ctx context.Context, sc *StepContext) error {
+			order = append(order, name)
+			return nil
+		}
+	}
+	mustRegister(t, pm.RegisterPreHook("validate", 10, record("validate/10")))
+	mustRegister(t, pm.RegisterPreHook(AllSteps, 0, record("all/0")))
+	mustRegister(t, pm.RegisterPreHook("validate", 0, record("validate/0")))
+	mustRegister(t, pm.RegisterPreHook("other", 0, record("other/0")))
+
+	if err := pm.RunPreHooks(context.Background(), "validate", &StepContext{}); err != nil {
+		t.Fatalf("RunPreHooks failed: %v", err)
+	}
+	if want := []string{"all/0", "validate/0", "validate/10"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("hooks ran in order %v, want %v", order, want)
+	}
+
+	order = nil
+	mustRegister(t, pm.RegisterPreHook("validate", 5, func(ctx context.Context, sc *StepContext) error {
+		sc.Response = &StepResponse{StatusCode: 304}
+		return nil
+	}))
+	sc := &StepContext{}
+	if err := pm.RunPreHooks(context.Background(), "validate", sc); err != nil {
+		t.Fatalf("RunPreHooks failed: %v", err)
+	}
+	if want := []string{"all/0", "validate/0"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("hooks ran in order %v, want %v", order, want)
+	}
+	if sc.Response == nil || sc.Response.StatusCode != 304 {
+		t.Errorf("expected the response set by the hook, got %+v", sc.Response)
+	}
+}
+
+func TestRunPreHooks_Errors(t *testing.T) {
+	pm := NewPluginManager()
+	ran := false
+	mustRegister(t, pm.RegisterPreHook("deny", 0, func(ctx context.Context, sc *StepContext) error {
+		return ErrAbort
+	}))
+	mustRegister(t, pm.RegisterPreHook("deny", 1, func(ctx context.Context, sc *StepContext) error {
+		ran = true
+		return nil
+	}))
+	err := pm.RunPreHooks(context.Background(), "deny", &StepContext{})
+	var he *HookError
+	if !errors.As(err, &he) || he.Stage != "pre-hook" || he.Name != "deny" {
+		t.Fatalf("expected a pre-hook HookError, got %v", err)
+	}
+	if !errors.Is(err, ErrAbort) {
+		t.Errorf("expected the error to wrap ErrAbort: %v", err)
+	}
+	if ran {
+		t.Error("hooks after a failed one must not run")
+	}
+
+	if err := pm.RunPreHooks(context.Background(), "missing", &StepContext{}); err != nil {
+		t.Errorf("a name without hooks must not fail: %v", err)
+	}
+}
+
+func TestRunPostHooks_MutatesResponseAndRecovers(t *testing.T) {
+	pm := NewPluginManager()
+	mustRegister(t, pm.RegisterPostHook("log", 0, func(ctx context.Context, sc *StepContext, resp *StepResponse) error {
+		resp.StatusCode = 200
+		resp.Body = sc.StepID
+		return nil
+	}))
+	// Pre-hooks are not run as post-hooks.
+	mustRegister(t, pm.RegisterPreHook("log", 0, func(ctx context.Context, sc *StepContext) error {
+		return errors.New("pre-hook run after the call")
+	}))
+	resp := &StepResponse{StatusCode: 500}
+	if err := pm.RunPostHooks(context.Background(), "log", &StepContext{StepID: "getUser"}, resp); err != nil {
+		t.Fatalf("RunPostHooks failed: %v", err)
+	}
+	if resp.StatusCode != 200 || resp.Body != "getUser" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+
+	mustRegister(t, pm.RegisterPostHook(AllSteps, 0, func(ctx context.Context, sc *StepContext, resp *StepResponse) error {
+		panic("boom")
+	}))
+	err := pm.RunPostHooks(context.Background(), "", &StepContext{}, resp)
+	if err == nil || err.Error() != "post-hook *: panic: boom" {
+		t.Errorf("expected the panic as an error, got %v", err)
+	}
+}
+
+func TestRegister_Invalid(t *testing.T) {
+	pm := NewPluginManager()
+	if err := pm.RegisterPreHook("", 0, func(ctx context.Context, sc *StepContext) error { return nil }); err == nil {
+		t.Error("expected an error for an empty name")
+	}
+	if err := pm.RegisterPostHook("log", 0, nil); err == nil {
+		t.Error("expected an error for a nil hook")
+	}
+	if pm.Registered("log") {
+		t.Error("a rejected hook must not be registered")
+	}
+}
+
+func TestSources_CompileAsPackageMain(t *testing.T) {
+	files, err := Sources()
+	if err != nil {
+		t.Fatalf("Sources failed: %v", err)
+	}
+	if _, ok := files["plugin_manager.go"]; !ok {
+		t.Error("plugin_manager.go must be emitted")
+	}
+	if _, ok := files["wasm_hook.go"]; ok {
+		t.Error("wasm_hook.go must only be emitted by WASMSources")
+	}
+	wasm, err := WASMSources()
+	if err != nil {
+		t.Fatalf("WASMSources failed: %v", err)
+	}
+	if len(wasm) != 1 || !strings.Contains(string(wasm["wasm_hook.go"]), "\npackage main\n") {
+		t.Errorf("expected wasm_hook.go in package main, got %d files", len(wasm))
+	}
+	for name, src := range files {
+		if name == "embed.go" || strings.HasSuffix(name, "_test.go") {
+			t.Errorf("%s must not be emitted", name)
+		}
+		f, err := parser.ParseFile(token.NewFileSet(), name, src, parser.PackageClauseOnly)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if f.Name.Name != "main" {
+			t.Errorf("%s: expected package main, got %s", name, f.Name.Name)
+		}
+	}
+}
+
+func mustRegister(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+// Command guest is a WebAssembly hook module used by the tests. Build it with
+//
+//	GOOS=wasip1 GOARCH=wasm go build -buildmode=c-shared -o hook.wasm .
+//
+// Its pre-hook rejects the id "0", answers the id "cached" in place of the
+// call, loops forever on "spin", allocates without bound on "hog" and
+// otherwise upper-cases the id. Its post-hook adds the step ID and version to
+// the body; -ldflags=-X=main.version=v2 builds another version.
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"unsafe"
+)
+
+func main() {}
+
+var version = "v1"
+
+// buffers keeps the memory handed to the host alive.
+var buffers [][]byte
+
+//go:wasmexport alloc
+func alloc(size uint32) uint32 {
+	buf := make([]byte, size)
+	buffers = append(buffers, buf)
+	return uint32(uintptr(unsafe.Pointer(unsafe.SliceData(buf))))
+}
+
+type response struct {
+	StatusCode int            `json:"statusCode"`
+	Body       map[string]any `json:"body"`
+}
+
+type stepContext struct {
+	FlowID   string         `json:"flowId"`
+	StepID   string         `json:"stepId"`
+	Inputs   map[string]any `json:"inputs"`
+	Response *response      `json:"response,omitempty"`
+}
+
+type result struct {
+	Inputs   map[string]any `json:"inputs,omitempty"`
+	Response *response      `json:"response,omitempty"`
+	Error    string         `json:"error,omitempty"`
+	Abort    bool           `json:"abort,omitempty"`
+}
+
+//go:wasmexport pre_hook
+func preHook(ptr, size uint32) uint64 {
+	sc := read(ptr, size)
+	id, _ := sc.Inputs["id"].(string)
+	switch id {
+	case "0":
+		return write(result{Error: "invalid user id", Abort: true})
+	case "cached":
+		return write(result{Response: &response{StatusCode: 200, Body: map[string]any{"id": "cached"}}})
+	case "spin":
+		for {
+		}
+	case "hog":
+		var hog [][]byte
+		for {
+			hog = append(hog, make([]byte, 1<<20))
+		}
+	}
+	sc.Inputs["id"] = strings.ToUpper(id)
+	return write(result{Inputs: sc.Inputs})
+}
+
+//go:wasmexport post_hook
+func postHook(ptr, size uint32) uint64 {
+	sc := read(ptr, size)
+	resp := sc.Response
+	if resp.Body == nil {
+		resp.Body = map[string]any{}
+	}
+	resp.Body["hookedBy"] = sc.StepID
+	resp.Body["version"] = version
+	return write(result{Response: resp})
+}
+
+func read(ptr, size uint32) stepContext {
+	var sc stepContext
+	data := unsafe.Slice((*byte)(unsafe.Pointer(uintptr(ptr))), size)
+	if err := json.Unmarshal(data, &sc); err != nil {
+		panic(err)
+	}
+	return sc
+}
+
+// write returns the JSON of r as a pointer in the upper and a length in the
+// lower 32 bits.
+func write(r result) uint64 {
+	data, err := json.Marshal(r)
+	if err != nil {
+		panic(err)
+	}
+	buffers = append(buffers, data)
+	return uint64(uintptr(unsafe.Pointer(unsafe.SliceData(data))))<<32 | uint64(len(data))
+}
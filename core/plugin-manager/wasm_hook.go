@@ -0,0 +1,327 @@
+package pluginmanager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// The exports of a WebAssembly hook module. alloc(size i32) i32 reserves size
+// bytes of the module memory for the host to write the step context to. The
+// hook functions take the pointer and length of the context and return the
+// pointer of their result in the upper and its length in the lower 32 bits
+// of an i64; a zero length leaves the step unchanged.
+const (
+	WASMAllocExport    = "alloc"
+	WASMPreHookExport  = "pre_hook"
+	WASMPostHookExport = "post_hook"
+)
+
+const (
+	// DefaultWASMMemoryLimit is the memory a hook module may use unless
+	// WASMOptions says otherwise.
+	DefaultWASMMemoryLimit = 64 << 20
+	// DefaultWASMTimeout bounds a hook call unless WASMOptions says otherwise.
+	DefaultWASMTimeout = time.Second
+)
+
+const wasmPageSize = 64 << 10
+
+// WASMOptions sandboxes a WebAssembly hook module.
+type WASMOptions struct {
+	// MemoryLimit is the most memory, in bytes, a module instance may grow
+	// to; DefaultWASMMemoryLimit when zero.
+	MemoryLimit uint64
+	// Timeout bounds the instantiation of the module and the hook call;
+	// DefaultWASMTimeout when zero. Compiling the module, once per change of
+	// its file, is bounded by the context of the call alone.
+	Timeout time.Duration
+}
+
+// WASMHook runs the hooks a WebAssembly module exports, out of process in
+// the sense that the module only sees the step context it is given: it has
+// no access to the files, network or memory of the server. Each call runs in
+// a fresh instance of the module, so calls share no state and may run
+// concurrently. The module is compiled on first use and again whenever its
+// file changes, so replacing the file swaps the hook without a restart.
+//
+// The step context is passed as the JSON object
+//
+//	{"flowId": "...", "stepId": "...", "inputs": {...},
+//	 "response": {"statusCode": 200, "headers": {...}, "body": ...}}
+//
+// and the hook answers with
+//
+//	{"inputs": {...}, "response": {...}, "error": "...", "abort": false}
+//
+// where every member is optional. A pre-hook returning inputs replaces the
+// arguments of the step and one returning a response short-circuits it; a
+// post-hook returning a response replaces the response of the step. An error
+// fails the step, or the workflow when abort is set.
+type WASMHook struct {
+	path string
+	opts WASMOptions
+
+	mu      sync.Mutex
+	runtime wazero.Runtime
+	current *wasmModule
+	modTime time.Time
+	size    int64
+}
+
+// wasmModule is a compiled version of the module with the runtime it was
+// compiled in. A version replaced by a newer one is closed once the last
+// call using it returns.
+type wasmModule struct {
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+	calls    int
+	replaced bool
+}
+
+// NewWASMHook returns the hook of the WebAssembly module at path. The module
+// is read on first use.
+func NewWASMHook(path string, opts WASMOptions) *WASMHook {
+	if opts.MemoryLimit == 0 {
+		opts.MemoryLimit = DefaultWASMMemoryLimit
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = DefaultWASMTimeout
+	}
+	return &WASMHook{path: path, opts: opts}
+}
+
+// RegisterWASMHook loads hook and registers its pre_hook and post_hook
+// exports, whichever the module has, as the pre- and post-hooks named name.
+func (pm *PluginManager) RegisterWASMHook(name string, priority int, hook *WASMHook) error {
+	m, err := hook.load(context.Background())
+	if err != nil {
+		return err
+	}
+	exports := m.compiled.ExportedFunctions()
+	hook.release(m)
+	_, pre := exports[WASMPreHookExport]
+	_, post := exports[WASMPostHookExport]
+	if !pre && !post {
+		return fmt.Errorf("wasm module %s exports neither %s nor %s", hook.path, WASMPreHookExport, WASMPostHookExport)
+	}
+	if pre {
+		if err := pm.RegisterPreHook(name, priority, hook.PreHook()); err != nil {
+			return err
+		}
+	}
+	if post {
+		return pm.RegisterPostHook(name, priority, hook.PostHook())
+	}
+	return nil
+}
+
+// PreHook returns the pre_hook export of the module as a PreHook.
+func (h *WASMHook) PreHook() PreHook {
+	return func(ctx context.Context, sc *StepContext) error {
+		res, err := h.call(ctx, WASMPreHookExport, sc, sc.Response)
+		if err != nil || res == nil {
+			return err
+		}
+		if res.Inputs != nil {
+			sc.Inputs = res.Inputs
+		}
+		if res.Response != nil {
+			sc.Response = res.Response.stepResponse()
+		}
+		return res.err()
+	}
+}
+
+// PostHook returns the post_hook export of the module as a PostHook.
+func (h *WASMHook) PostHook() PostHook {
+	return func(ctx context.Context, sc *StepContext, resp *StepResponse) error {
+		res, err := h.call(ctx, WASMPostHookExport, sc, resp)
+		if err != nil || res == nil {
+			return err
+		}
+		if res.Response != nil {
+			*resp = *res.Response.stepResponse()
+		}
+		return res.err()
+	}
+}
+
+// Close releases the compiled module and its runtime.
+func (h *WASMHook) Close(ctx context.Context) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.runtime == nil {
+		return nil
+	}
+	err := h.runtime.Close(ctx)
+	h.runtime, h.current = nil, nil
+	return err
+}
+
+// load returns the compiled module, compiling it again when its file changed
+// since the last call. The module stays open until it is passed to release.
+func (h *WASMHook) load(ctx context.Context) (*wasmModule, error) {
+	info, err := os.Stat(h.path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load wasm module: %w", err)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.current != nil && info.ModTime().Equal(h.modTime) && info.Size() == h.size {
+		h.current.calls++
+		return h.current, nil
+	}
+	if h.runtime == nil {
+		config := wazero.NewRuntimeConfig().
+			WithMemoryLimitPages(uint32(h.opts.MemoryLimit / wasmPageSize)).
+			WithCloseOnContextDone(true)
+		h.runtime = wazero.NewRuntimeWithConfig(context.Background(), config)
+		// Modules built for WASI, as Go, TinyGo and Rust modules usually
+		// are, need its imports; they grant no access to the host.
+		if _, err := wasi_snapshot_preview1.Instantiate(context.Background(), h.runtime); err != nil {
+			return nil, fmt.Errorf("failed to instantiate WASI: %w", err)
+		}
+	}
+	src, err := os.ReadFile(h.path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load wasm module: %w", err)
+	}
+	compiled, err := h.runtime.CompileModule(ctx, src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile wasm module %s: %w", h.path, err)
+	}
+	if old := h.current; old != nil {
+		old.replaced = true
+		if old.calls == 0 {
+			_ = old.compiled.Close(ctx)
+		}
+	}
+	h.current = &wasmModule{runtime: h.runtime, compiled: compiled, calls: 1}
+	h.modTime, h.size = info.ModTime(), info.Size()
+	return h.current, nil
+}
+
+// release ends a call using m, closing m if it was replaced meanwhile.
+func (h *WASMHook) release(m *wasmModule) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	m.calls--
+	if m.replaced && m.calls == 0 {
+		_ = m.compiled.Close(context.Background())
+	}
+}
+
+// call runs export in a new instance of the module with the step context as
+// JSON, and decodes its result; a nil result leaves the step unchanged.
+func (h *WASMHook) call(ctx context.Context, export string, sc *StepContext, resp *StepResponse) (*wasmResult, error) {
+	m, err := h.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer h.release(m)
+	input, err := json.Marshal(wasmContext{FlowID: sc.FlowID, StepID: sc.StepID, Inputs: sc.Inputs, Response: newWASMResponse(resp)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode step context: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.opts.Timeout)
+	defer cancel()
+	// Reactor modules are initialized by _initialize; a _start function
+	// would run the module as a command and exit it.
+	config := wazero.NewModuleConfig().WithName("").WithStartFunctions("_initialize").WithStderr(os.Stderr)
+	mod, err := m.runtime.InstantiateModule(ctx, m.compiled, config)
+	if err != nil {
+		return nil, h.callError(ctx, "failed to instantiate wasm module", err)
+	}
+	defer mod.Close(context.Background())
+
+	alloc, fn := mod.ExportedFunction(WASMAllocExport), mod.ExportedFunction(export)
+	if alloc == nil || fn == nil || mod.Memory() == nil {
+		return nil, fmt.Errorf("wasm module %s must export memory, %s and %s", h.path, WASMAllocExport, export)
+	}
+	results, err := alloc.Call(ctx, uint64(len(input)))
+	if err != nil {
+		return nil, h.callError(ctx, "wasm allocation failed", err)
+	}
+	ptr := api.DecodeU32(results[0])
+	if !mod.Memory().Write(ptr, input) {
+		return nil, fmt.Errorf("wasm module %s allocated memory out of range", h.path)
+	}
+	results, err = fn.Call(ctx, uint64(ptr), uint64(len(input)))
+	if err != nil {
+		return nil, h.callError(ctx, "wasm hook failed", err)
+	}
+
+	outPtr, outLen := uint32(results[0]>>32), uint32(results[0])
+	if outLen == 0 {
+		return nil, nil
+	}
+	output, ok := mod.Memory().Read(outPtr, outLen)
+	if !ok {
+		return nil, fmt.Errorf("wasm module %s returned a result out of range", h.path)
+	}
+	var res wasmResult
+	if err := json.Unmarshal(output, &res); err != nil {
+		return nil, fmt.Errorf("cannot decode wasm hook result: %w", err)
+	}
+	return &res, nil
+}
+
+func (h *WASMHook) callError(ctx context.Context, msg string, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%s: timed out after %s", msg, h.opts.Timeout)
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+type wasmContext struct {
+	FlowID   string         `json:"flowId"`
+	StepID   string         `json:"stepId"`
+	Inputs   map[string]any `json:"inputs"`
+	Response *wasmResponse  `json:"response,omitempty"`
+}
+
+type wasmResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Headers    http.Header `json:"headers,omitempty"`
+	Body       any         `json:"body,omitempty"`
+}
+
+func newWASMResponse(resp *StepResponse) *wasmResponse {
+	if resp == nil {
+		return nil
+	}
+	return &wasmResponse{StatusCode: resp.StatusCode, Headers: resp.Headers, Body: resp.Body}
+}
+
+func (r *wasmResponse) stepResponse() *StepResponse {
+	return &StepResponse{StatusCode: r.StatusCode, Headers: r.Headers, Body: r.Body}
+}
+
+type wasmResult struct {
+	Inputs   map[string]any `json:"inputs"`
+	Response *wasmResponse  `json:"response"`
+	Error    string         `json:"error"`
+	Abort    bool           `json:"abort"`
+}
+
+func (r *wasmResult) err() error {
+	switch {
+	case r.Error == "":
+		return nil
+	case r.Abort:
+		return fmt.Errorf("%s: %w", r.Error, ErrAbort)
+	default:
+		return errors.New(r.Error)
+	}
+}
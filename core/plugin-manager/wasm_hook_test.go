@@ -0,0 +1,201 @@
+package pluginmanager
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var guests sync.Map
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	guests.Range(func(_, path any) bool {
+		os.RemoveAll(filepath.Dir(path.(string)))
+		return true
+	})
+	os.Exit(code)
+}
+
+// buildGuest builds testdata/guest as a WebAssembly module, once per version.
+func buildGuest(t *testing.T, version string) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("skipping build of the wasm guest in short mode")
+	}
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain not available")
+	}
+	if path, ok := guests.Load(version); ok {
+		return path.(string)
+	}
+	dir, err := os.MkdirTemp("", "wasm-guest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "hook.wasm")
+	cmd := exec.Command(goBin, "build", "-buildmode=c-shared", "-ldflags=-X=main.version="+version, "-o", path, ".")
+	cmd.Dir = "testdata/guest"
+	cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("building the guest failed: %v\n%s", err, out)
+	}
+	guests.Store(version, path)
+	return path
+}
+
+func TestWASMHook_PreAndPostHooks(t *testing.T) {
+	hook := NewWASMHook(buildGuest(t, "v1"), WASMOptions{})
+	defer hook.Close(context.Background())
+	pm := NewPluginManager()
+	mustRegister(t, pm.RegisterWASMHook("guest", 0, hook))
+	ctx := context.Background()
+
+	sc := &StepContext{FlowID: "wf", StepID: "lookup", Inputs: map[string]any{"id": "ada"}}
+	if err := pm.RunPreHooks(ctx, "guest", sc); err != nil {
+		t.Fatalf("RunPreHooks failed: %v", err)
+	}
+	if sc.Inputs["id"] != "ADA" || sc.Response != nil {
+		t.Errorf("expected the inputs rewritten by the module, got %+v", sc)
+	}
+
+	sc = &StepContext{Inputs: map[string]any{"id": "cached"}}
+	if err := pm.RunPreHooks(ctx, "guest", sc); err != nil {
+		t.Fatalf("RunPreHooks failed: %v", err)
+	}
+	if sc.Response == nil || sc.Response.StatusCode != 200 || !reflect.DeepEqual(sc.Response.Body, map[string]any{"id": "cached"}) {
+		t.Errorf("expected the response of the module, got %+v", sc.Response)
+	}
+
+	err := pm.RunPreHooks(ctx, "guest", &StepContext{Inputs: map[string]any{"id": "0"}})
+	if !errors.Is(err, ErrAbort) || !strings.Contains(err.Error(), "pre-hook guest: invalid user id") {
+		t.Errorf("expected the module to abort the workflow, got %v", err)
+	}
+
+	resp := &StepResponse{StatusCode: 200, Body: map[string]any{"id": "ada"}}
+	if err := pm.RunPostHooks(ctx, "guest", &StepContext{StepID: "lookup"}, resp); err != nil {
+		t.Fatalf("RunPostHooks failed: %v", err)
+	}
+	if want := map[string]any{"id": "ada", "hookedBy": "lookup", "version": "v1"}; !reflect.DeepEqual(resp.Body, want) {
+		t.Errorf("expected body %v, got %v", want, resp.Body)
+	}
+}
+
+func TestWASMHook_Limits(t *testing.T) {
+	hook := NewWASMHook(buildGuest(t, "v1"), WASMOptions{Timeout: 200 * time.Millisecond})
+	defer hook.Close(context.Background())
+	pre := hook.PreHook()
+	// Compiling the module is not bounded by Timeout, nor timed here.
+	m, err := hook.load(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook.release(m)
+
+	start := time.Now()
+	err = pre(context.Background(), &StepContext{Inputs: map[string]any{"id": "spin"}})
+	if err == nil || !strings.Contains(err.Error(), "timed out after 200ms") {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("the call was not stopped at its timeout: %s", elapsed)
+	}
+
+	err = pre(context.Background(), &StepContext{Inputs: map[string]any{"id": "hog"}})
+	if err == nil || !strings.Contains(err.Error(), "wasm hook failed") {
+		t.Errorf("expected the module to run out of memory, got %v", err)
+	}
+
+	// The module is instantiated for each call, so it still works.
+	sc := &StepContext{Inputs: map[string]any{"id": "ada"}}
+	if err := pre(context.Background(), sc); err != nil || sc.Inputs["id"] != "ADA" {
+		t.Errorf("expected a working module after failed calls, got %v, %v", err, sc.Inputs)
+	}
+}
+
+func TestWASMHook_HotSwap(t *testing.T) {
+	v1, v2 := buildGuest(t, "v1"), buildGuest(t, "v2")
+	path := filepath.Join(t.TempDir(), "hook.wasm")
+	install := func(src string, modTime time.Time) {
+		t.Helper()
+		data, err := os.ReadFile(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	version := func(post PostHook) (any, error) {
+		resp := &StepResponse{StatusCode: 200}
+		err := post(context.Background(), &StepContext{}, resp)
+		if err != nil {
+			return nil, err
+		}
+		return resp.Body.(map[string]any)["version"], nil
+	}
+
+	now := time.Now()
+	install(v1, now.Add(-time.Hour))
+	hook := NewWASMHook(path, WASMOptions{})
+	defer hook.Close(context.Background())
+	post := hook.PostHook()
+	if v, err := version(post); err != nil || v != "v1" {
+		t.Fatalf("expected v1, got %v, %v", v, err)
+	}
+
+	// A call still running keeps the module it started with.
+	spinning := make(chan error)
+	go func() {
+		spinning <- hook.PreHook()(context.Background(), &StepContext{Inputs: map[string]any{"id": "spin"}})
+	}()
+	time.Sleep(100 * time.Millisecond)
+	install(v2, now)
+	if v, err := version(post); err != nil || v != "v2" {
+		t.Errorf("expected the replaced module, got %v, %v", v, err)
+	}
+	if err := <-spinning; err == nil || !strings.Contains(err.Error(), "timed out after 1s") {
+		t.Errorf("expected the running call to time out in the old module, got %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("not wasm"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := version(post); err == nil || !strings.Contains(err.Error(), "failed to compile wasm module") {
+		t.Errorf("expected a compile error, got %v", err)
+	}
+}
+
+func TestRegisterWASMHook_Errors(t *testing.T) {
+	pm := NewPluginManager()
+	err := pm.RegisterWASMHook("guest", 0, NewWASMHook(filepath.Join(t.TempDir(), "missing.wasm"), WASMOptions{}))
+	if err == nil || !strings.Contains(err.Error(), "cannot load wasm module") {
+		t.Errorf("expected a load error, got %v", err)
+	}
+
+	// The smallest valid module: the magic number and version only.
+	empty := filepath.Join(t.TempDir(), "empty.wasm")
+	if err := os.WriteFile(empty, []byte("\x00asm\x01\x00\x00\x00"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	hook := NewWASMHook(empty, WASMOptions{})
+	defer hook.Close(context.Background())
+	err = pm.RegisterWASMHook("guest", 0, hook)
+	if err == nil || !strings.Contains(err.Error(), "exports neither pre_hook nor post_hook") {
+		t.Errorf("expected an export error, got %v", err)
+	}
+	if pm.Registered("guest") {
+		t.Error("a rejected module must not be registered")
+	}
+}